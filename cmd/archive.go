@@ -0,0 +1,175 @@
+package cmd
+
+import (
+	"bufio"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/sagikazarmark/labx/pkg/fsx"
+)
+
+const (
+	archiveFormatTarGz = "tar.gz"
+	archiveFormatZip   = "zip"
+
+	checksumsFile = "SHA256SUMS"
+)
+
+// archiveFormat returns the archive format (inferred from the file extension if not specified).
+func archiveFormat(path string, format string) (string, error) {
+	switch format {
+	case archiveFormatTarGz, archiveFormatZip:
+		return format, nil
+
+	case "":
+		switch {
+		case strings.HasSuffix(path, ".tar.gz"), strings.HasSuffix(path, ".tgz"):
+			return archiveFormatTarGz, nil
+		case strings.HasSuffix(path, ".zip"):
+			return archiveFormatZip, nil
+		}
+
+		return "", fmt.Errorf("cannot infer archive format from %s: use --format", path)
+
+	default:
+		return "", fmt.Errorf("unsupported archive format: %s", format)
+	}
+}
+
+// writeArchive calls generate with a filesystem writing an archive to path.
+//
+// The archive is written to a temporary file first and only replaces path if generation succeeds.
+func writeArchive(path string, format string, generate func(output fsx.WriteFS) error) (err error) {
+	file, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+"-*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			file.Close()
+			os.Remove(file.Name())
+		}
+	}()
+
+	switch format {
+	case archiveFormatTarGz:
+		gw := gzip.NewWriter(file)
+		archive := fsx.NewTarFS(gw)
+
+		err = generate(archive)
+		if err != nil {
+			return err
+		}
+
+		err = archive.Close()
+		if err != nil {
+			return err
+		}
+
+		err = gw.Close()
+		if err != nil {
+			return err
+		}
+
+	case archiveFormatZip:
+		archive := fsx.NewZipFS(file)
+
+		err = generate(archive)
+		if err != nil {
+			return err
+		}
+
+		err = archive.Close()
+		if err != nil {
+			return err
+		}
+
+	default:
+		return fmt.Errorf("unsupported archive format: %s", format)
+	}
+
+	err = file.Chmod(0o644)
+	if err != nil {
+		return err
+	}
+
+	err = file.Close()
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(file.Name(), path)
+}
+
+// updateChecksums records the SHA-256 checksum of a file in the SHA256SUMS file next to it
+// (in the format of sha256sum, keeping the checksums of other files).
+func updateChecksums(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+
+	_, err = io.Copy(hash, file)
+	if err != nil {
+		return err
+	}
+
+	checksumsPath := filepath.Join(filepath.Dir(path), checksumsFile)
+
+	checksums, err := readChecksums(checksumsPath)
+	if err != nil {
+		return fmt.Errorf("read %s: %w", checksumsFile, err)
+	}
+
+	checksums[filepath.Base(path)] = hex.EncodeToString(hash.Sum(nil))
+
+	var b strings.Builder
+
+	for _, name := range slices.Sorted(maps.Keys(checksums)) {
+		fmt.Fprintf(&b, "%s  %s\n", checksums[name], name)
+	}
+
+	return os.WriteFile(checksumsPath, []byte(b.String()), 0o644)
+}
+
+// readChecksums reads a SHA256SUMS file (a missing file has no checksums).
+func readChecksums(path string) (map[string]string, error) {
+	checksums := map[string]string{}
+
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return checksums, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			continue
+		}
+
+		sum, name, ok := strings.Cut(line, "  ")
+		if !ok {
+			return nil, fmt.Errorf("invalid line: %q", line)
+		}
+
+		checksums[name] = sum
+	}
+
+	return checksums, scanner.Err()
+}
@@ -0,0 +1,18 @@
+package cmd
+
+// ExitCodeChanges is the exit code of commands that detected changes (e.g. generate --dry-run).
+const ExitCodeChanges = 2
+
+// ExitError is an error that exits the process with a specific code.
+type ExitError struct {
+	Code int
+	Err  error
+}
+
+func (e *ExitError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ExitError) Unwrap() error {
+	return e.Err
+}
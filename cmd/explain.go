@@ -0,0 +1,123 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/sagikazarmark/labx/labx"
+)
+
+type explainOptions struct {
+	path           string
+	channel        string
+	templateDirs   []string
+	dataDirs       []string
+	libraryDirs    []string
+	playgroundDirs []string
+	remoteBase     bool
+	envFile        string
+}
+
+func NewExplainCommand() *cobra.Command {
+	var opts explainOptions
+
+	cmd := &cobra.Command{
+		Use:   "explain",
+		Short: "Show which base playground each playground field comes from",
+		Long: `Resolve the inheritance chain of a playground (following base) and print
+the layer (or layers, for merged fields) each field of the generated playground comes from.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runExplain(cmd, &opts)
+		},
+	}
+
+	flags := cmd.Flags()
+
+	flags.StringVar(
+		&opts.path,
+		"path",
+		".",
+		`Path to load manifest from`,
+	)
+
+	flags.StringVar(
+		&opts.channel,
+		"channel",
+		"dev",
+		`Which channel to use`,
+	)
+
+	flags.StringSliceVar(
+		&opts.templateDirs,
+		"template-dir",
+		[]string{},
+		`Global template directories (can be specified multiple times)`,
+	)
+
+	flags.StringSliceVar(
+		&opts.dataDirs,
+		"data-dir",
+		[]string{},
+		`Additional data directories to load JSON files from (can be specified multiple times)`,
+	)
+
+	flags.StringSliceVar(
+		&opts.libraryDirs,
+		"library-dir",
+		[]string{},
+		`Library directories to load machine presets (machines.yaml) from (can be specified multiple times)`,
+	)
+
+	flags.StringSliceVar(
+		&opts.playgroundDirs,
+		"playground-dir",
+		[]string{},
+		`Directories containing local playgrounds to resolve base playgrounds from (can be specified multiple times)`,
+	)
+
+	flags.BoolVar(
+		&opts.remoteBase,
+		"remote-base",
+		false,
+		`Fetch base playgrounds that are not found locally from the server to inherit their spec (requires labctl)`,
+	)
+
+	addEnvFileFlag(flags, &opts.envFile)
+
+	return cmd
+}
+
+func runExplain(cmd *cobra.Command, opts *explainOptions) error {
+	secrets, err := loadSecrets(opts.envFile, opts.path)
+	if err != nil {
+		return err
+	}
+
+	root, err := os.OpenRoot(opts.path)
+	if err != nil {
+		return err
+	}
+
+	origins, err := labx.Explain(labx.ExplainOpts{
+		Root:           root,
+		Channel:        opts.channel,
+		TemplateDirs:   dirFSs(opts.templateDirs),
+		DataDirs:       dirFSs(opts.dataDirs),
+		LibraryDirs:    dirFSs(opts.libraryDirs),
+		PlaygroundDirs: dirFSs(opts.playgroundDirs),
+		RemoteBases:    opts.remoteBase,
+		Secrets:        secrets,
+		Context:        cmd.Context(),
+	})
+	if err != nil {
+		return secrets.RedactError(err)
+	}
+
+	for _, origin := range origins {
+		fmt.Println(secrets.Redact(origin.String()))
+	}
+
+	return nil
+}
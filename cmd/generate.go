@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
 	"io/fs"
 	"os"
@@ -10,6 +11,7 @@ import (
 	"github.com/spf13/pflag"
 
 	"github.com/sagikazarmark/labx/labx"
+	"github.com/sagikazarmark/labx/pkg/fsx"
 )
 
 const defaultOutput = "dist"
@@ -18,9 +20,20 @@ type generateOptions struct {
 	path         string
 	output       string
 	clear        bool
+	archive      string
+	format       string
+	dryRun       bool
+	sync         bool
+	prune        bool
 	channel      string
 	templateDirs []string
 	dataDirs     []string
+	libraryDirs  []string
+
+	playgroundDirs []string
+	remoteBase     bool
+	envFile        string
+	concurrency    int
 }
 
 func NewGenerateCommand() *cobra.Command {
@@ -34,7 +47,7 @@ Automatically routes to appropriate processing:
 - playground: generates playground manifest
 - other kinds: generates content files`,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return runGenerate(&opts)
+			return runGenerate(cmd, &opts)
 		},
 	}
 
@@ -42,6 +55,14 @@ Automatically routes to appropriate processing:
 
 	addFlags(flags, &opts)
 
+	cmd.MarkFlagsMutuallyExclusive("archive", "output")
+	cmd.MarkFlagsMutuallyExclusive("archive", "clear")
+	cmd.MarkFlagsMutuallyExclusive("dry-run", "archive")
+	cmd.MarkFlagsMutuallyExclusive("dry-run", "clear")
+	cmd.MarkFlagsMutuallyExclusive("sync", "clear")
+	cmd.MarkFlagsMutuallyExclusive("sync", "archive")
+	cmd.MarkFlagsMutuallyExclusive("sync", "dry-run")
+
 	return cmd
 }
 
@@ -68,6 +89,41 @@ func addFlags(flags *pflag.FlagSet, opts *generateOptions) {
 		`Clear output directory before generating content`,
 	)
 
+	flags.BoolVar(
+		&opts.sync,
+		"sync",
+		false,
+		`Update the output directory in place: only write changed files and remove files that are no longer generated`,
+	)
+
+	flags.BoolVar(
+		&opts.prune,
+		"prune",
+		false,
+		`Remove files that were not generated from the output directory (requires --sync)`,
+	)
+
+	flags.StringVar(
+		&opts.archive,
+		"archive",
+		"",
+		`Write the output to an archive (and its checksum to SHA256SUMS next to it) instead of a directory`,
+	)
+
+	flags.StringVar(
+		&opts.format,
+		"format",
+		"",
+		`Archive format: tar.gz or zip (inferred from the archive extension by default)`,
+	)
+
+	flags.BoolVar(
+		&opts.dryRun,
+		"dry-run",
+		false,
+		`Print a diff against the output directory instead of writing it (exits with 2 if there are changes)`,
+	)
+
 	flags.StringVar(
 		&opts.channel,
 		"channel",
@@ -88,40 +144,160 @@ func addFlags(flags *pflag.FlagSet, opts *generateOptions) {
 		[]string{},
 		`Additional data directories to load JSON files from (can be specified multiple times)`,
 	)
+
+	flags.StringSliceVar(
+		&opts.libraryDirs,
+		"library-dir",
+		[]string{},
+		`Library directories to load machine presets (machines.yaml) from (can be specified multiple times)`,
+	)
+
+	flags.StringSliceVar(
+		&opts.playgroundDirs,
+		"playground-dir",
+		[]string{},
+		`Directories containing local playgrounds to resolve base playgrounds from before falling back to the server (can be specified multiple times)`,
+	)
+
+	flags.BoolVar(
+		&opts.remoteBase,
+		"remote-base",
+		false,
+		`Fetch base playgrounds that are not found locally from the server to inherit their spec (requires labctl)`,
+	)
+
+	flags.IntVar(
+		&opts.concurrency,
+		"concurrency",
+		8,
+		`Maximum number of lessons, units, machines and drives processed at the same time`,
+	)
+
+	addEnvFileFlag(flags, &opts.envFile)
 }
 
-func runGenerate(opts *generateOptions) error {
+func runGenerate(cmd *cobra.Command, opts *generateOptions) error {
+	if opts.prune && !opts.sync {
+		return errors.New("--prune requires --sync")
+	}
+
+	secrets, err := loadSecrets(opts.envFile, opts.path)
+	if err != nil {
+		return err
+	}
+
+	generateOpts := labx.GenerateOpts{
+		Channel:      opts.channel,
+		TemplateDirs: dirFSs(opts.templateDirs),
+		DataDirs:     dirFSs(opts.dataDirs),
+		LibraryDirs:  dirFSs(opts.libraryDirs),
+
+		PlaygroundDirs: dirFSs(opts.playgroundDirs),
+		RemoteBases:    opts.remoteBase,
+		Secrets:        secrets,
+		Context:        cmd.Context(),
+		Concurrency:    opts.concurrency,
+	}
+
+	if opts.archive != "" {
+		return generateArchive(opts, generateOpts, secrets)
+	}
+
+	if opts.dryRun {
+		return generateDryRun(cmd, opts, generateOpts, secrets)
+	}
+
+	if opts.sync {
+		return generateSync(cmd, opts, generateOpts, secrets)
+	}
+
 	root, outputRoot, err := setupFsys(opts)
 	if err != nil {
 		return err
 	}
 
-	var templateFSs []fs.FS
-	for _, templateDir := range opts.templateDirs {
-		templateFSs = append(templateFSs, os.DirFS(templateDir))
+	generateOpts.Root = root
+	generateOpts.Output = fsx.NewRootFS(outputRoot)
+
+	err = labx.Generate(generateOpts)
+	if err != nil {
+		return secrets.RedactError(err)
 	}
 
-	var dataFSs []fs.FS
-	for _, dataDir := range opts.dataDirs {
-		dataFSs = append(dataFSs, os.DirFS(dataDir))
+	return nil
+}
+
+// generateArchive generates content into an archive and records its checksum
+func generateArchive(opts *generateOptions, generateOpts labx.GenerateOpts, secrets *labx.Secrets) error {
+	format, err := archiveFormat(opts.archive, opts.format)
+	if err != nil {
+		return err
 	}
 
-	generateOpts := labx.GenerateOpts{
-		Root:         root,
-		Output:       outputRoot,
-		Channel:      opts.channel,
-		TemplateDirs: templateFSs,
-		DataDirs:     dataFSs,
+	root, err := os.OpenRoot(opts.path)
+	if err != nil {
+		return err
 	}
 
+	generateOpts.Root = root
+
+	err = writeArchive(opts.archive, format, func(output fsx.WriteFS) error {
+		generateOpts.Output = output
+
+		return labx.Generate(generateOpts)
+	})
+	if err != nil {
+		return secrets.RedactError(err)
+	}
+
+	return updateChecksums(opts.archive)
+}
+
+// generateDryRun generates content into memory and prints the difference from the output directory
+func generateDryRun(cmd *cobra.Command, opts *generateOptions, generateOpts labx.GenerateOpts, secrets *labx.Secrets) error {
+	root, err := os.OpenRoot(opts.path)
+	if err != nil {
+		return err
+	}
+
+	output := fsx.NewMemFS()
+
+	generateOpts.Root = root
+	generateOpts.Output = output
+
 	err = labx.Generate(generateOpts)
+	if err != nil {
+		return secrets.RedactError(err)
+	}
+
+	diffs, err := labx.DiffOutput(os.DirFS(outputDir(opts)), output.MapFS())
 	if err != nil {
 		return err
 	}
 
+	for _, diff := range diffs {
+		fmt.Fprint(cmd.OutOrStdout(), secrets.Redact(diff.Diff))
+	}
+
+	if len(diffs) > 0 {
+		return &ExitError{
+			Code: ExitCodeChanges,
+			Err:  fmt.Errorf("%d file(s) changed", len(diffs)),
+		}
+	}
+
 	return nil
 }
 
+func dirFSs(dirs []string) []fs.FS {
+	fsyss := make([]fs.FS, 0, len(dirs))
+	for _, dir := range dirs {
+		fsyss = append(fsyss, os.DirFS(dir))
+	}
+
+	return fsyss
+}
+
 // setupFsys handles the output directory setup logic
 func setupFsys(opts *generateOptions) (*os.Root, *os.Root, error) {
 	root, err := os.OpenRoot(opts.path)
@@ -129,12 +305,7 @@ func setupFsys(opts *generateOptions) (*os.Root, *os.Root, error) {
 		return nil, nil, err
 	}
 
-	var outputPath string
-	if opts.output == "" {
-		outputPath = filepath.Join(opts.path, defaultOutput)
-	} else {
-		outputPath = opts.output
-	}
+	outputPath := outputDir(opts)
 
 	// If clear is true, always remove the directory first
 	if opts.clear {
@@ -155,7 +326,7 @@ func setupFsys(opts *generateOptions) (*os.Root, *os.Root, error) {
 		if dirExists, err := isDirEmptyPath(outputPath); err != nil {
 			return nil, nil, err
 		} else if !dirExists {
-			return nil, nil, fmt.Errorf("output directory '%s' is not empty. Use --clear to remove it first", outputPath)
+			return nil, nil, fmt.Errorf("output directory '%s' is not empty. Use --clear to remove it first or --sync to update it", outputPath)
 		}
 	}
 
@@ -168,6 +339,61 @@ func setupFsys(opts *generateOptions) (*os.Root, *os.Root, error) {
 	return root, outputRoot, nil
 }
 
+// generateSync generates content into memory and updates the output directory with the changes
+func generateSync(cmd *cobra.Command, opts *generateOptions, generateOpts labx.GenerateOpts, secrets *labx.Secrets) error {
+	root, err := os.OpenRoot(opts.path)
+	if err != nil {
+		return err
+	}
+
+	output := fsx.NewMemFS()
+
+	generateOpts.Root = root
+	generateOpts.Output = output
+
+	err = labx.Generate(generateOpts)
+	if err != nil {
+		return secrets.RedactError(err)
+	}
+
+	outputPath := outputDir(opts)
+
+	err = os.MkdirAll(outputPath, 0o755)
+	if err != nil {
+		return err
+	}
+
+	outputRoot, err := os.OpenRoot(outputPath)
+	if err != nil {
+		return err
+	}
+	defer outputRoot.Close()
+
+	report, err := labx.SyncOutput(outputRoot, output.MapFS(), labx.SyncOpts{Prune: opts.prune})
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(
+		cmd.OutOrStdout(),
+		"%d file(s) written, %d removed, %d unchanged\n",
+		len(report.Written),
+		len(report.Removed),
+		report.Unchanged,
+	)
+
+	return nil
+}
+
+// outputDir returns the output directory (defaults to dist/ in the content directory)
+func outputDir(opts *generateOptions) string {
+	if opts.output == "" {
+		return filepath.Join(opts.path, defaultOutput)
+	}
+
+	return opts.output
+}
+
 // isDirEmptyPath checks if a directory path is empty or doesn't exist
 // Returns true if directory is empty or doesn't exist, false if it contains files
 func isDirEmptyPath(path string) (bool, error) {
@@ -0,0 +1,88 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/sagikazarmark/labx/labx"
+)
+
+type lintOptions struct {
+	path          string
+	knownCommands []string
+	strict        bool
+}
+
+func NewLintCommand() *cobra.Command {
+	var opts lintOptions
+
+	cmd := &cobra.Command{
+		Use:   "lint",
+		Short: "Check task scripts for problems",
+		Long: `Parse every task script (run, hintcheck, failcheck and init tasks) and report:
+- syntax errors
+- commands that are not provided by startup files
+- environment variables that are not listed in env`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runLint(&opts)
+		},
+	}
+
+	flags := cmd.Flags()
+
+	flags.StringVar(
+		&opts.path,
+		"path",
+		".",
+		`Path to load manifest from`,
+	)
+
+	flags.StringSliceVar(
+		&opts.knownCommands,
+		"known-command",
+		[]string{},
+		`Commands known to exist on the machines (can be specified multiple times)`,
+	)
+
+	flags.BoolVar(
+		&opts.strict,
+		"strict",
+		false,
+		`Fail on warnings too`,
+	)
+
+	return cmd
+}
+
+func runLint(opts *lintOptions) error {
+	root, err := os.OpenRoot(opts.path)
+	if err != nil {
+		return err
+	}
+
+	findings, err := labx.Lint(labx.LintOpts{
+		Root:          root,
+		KnownCommands: opts.knownCommands,
+	})
+	if err != nil {
+		return err
+	}
+
+	var failures int
+
+	for _, finding := range findings {
+		fmt.Println(finding)
+
+		if finding.Severity == labx.SeverityError || opts.strict {
+			failures++
+		}
+	}
+
+	if failures > 0 {
+		return fmt.Errorf("lint found %d problem(s)", failures)
+	}
+
+	return nil
+}
@@ -0,0 +1,55 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/pflag"
+
+	"github.com/sagikazarmark/labx/labx"
+)
+
+const defaultEnvFile = ".env"
+
+// addEnvFileFlag adds the flag to load secrets from a .env file
+func addEnvFileFlag(flags *pflag.FlagSet, envFile *string) {
+	flags.StringVar(
+		envFile,
+		"env-file",
+		"",
+		`File to load secrets from (defaults to .env in the content root, ignored if it doesn't exist, the environment takes precedence)`,
+	)
+}
+
+// envFilePath returns the path of the .env file: relative paths are relative to the working directory,
+// the default is relative to the content root.
+func envFilePath(envFile string, root string) string {
+	if envFile != "" {
+		return envFile
+	}
+
+	return filepath.Join(root, defaultEnvFile)
+}
+
+// loadSecrets loads secrets (see [envFilePath]) and makes sure they are redacted from logs
+func loadSecrets(envFile string, root string) (*labx.Secrets, error) {
+	secrets, err := labx.LoadSecrets(envFilePath(envFile, root))
+	if err != nil {
+		return nil, fmt.Errorf("load secrets: %w", err)
+	}
+
+	setRedactingLogger(os.Stderr, secrets)
+
+	return secrets, nil
+}
+
+// setRedactingLogger installs a default logger writing to w that redacts secrets.
+//
+// The handler must not wrap the default handler: it forwards to the log package,
+// which forwards to the default logger once it's replaced (deadlocking on the first log).
+func setRedactingLogger(w io.Writer, secrets *labx.Secrets) {
+	slog.SetDefault(slog.New(labx.NewRedactingHandler(slog.NewTextHandler(w, nil), secrets)))
+}
@@ -0,0 +1,77 @@
+package cmd
+
+import (
+	"bytes"
+	"log"
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sagikazarmark/labx/labx"
+)
+
+// syncBuffer is a buffer that can be written and read concurrently.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.buf.String()
+}
+
+func TestSetRedactingLogger(t *testing.T) {
+	defaultLogger := slog.Default()
+	t.Cleanup(func() { slog.SetDefault(defaultLogger) })
+
+	secrets, err := labx.NewSecrets(strings.NewReader("TOKEN=s3cr3t\n"))
+	require.NoError(t, err)
+
+	_, err = secrets.Get("TOKEN")
+	require.NoError(t, err)
+
+	var output syncBuffer
+
+	setRedactingLogger(&output, secrets)
+
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		slog.Warn("using s3cr3t", slog.String("token", "s3cr3t"))
+		log.Print("printing s3cr3t")
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("logging through the default logger deadlocked")
+	}
+
+	assert.Contains(t, output.String(), "using [REDACTED]")
+	assert.Contains(t, output.String(), "token=[REDACTED]")
+	assert.Contains(t, output.String(), "printing [REDACTED]")
+	assert.NotContains(t, output.String(), "s3cr3t")
+}
+
+func TestEnvFilePath(t *testing.T) {
+	assert.Equal(t, filepath.Join("content", ".env"), envFilePath("", "content"))
+	assert.Equal(t, "secrets.env", envFilePath("secrets.env", "content"))
+}
@@ -0,0 +1,231 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/sagikazarmark/labx/labx"
+)
+
+type testOptions struct {
+	path            string
+	channel         string
+	templateDirs    []string
+	dataDirs        []string
+	libraryDirs     []string
+	playgroundDirs  []string
+	remoteBase      bool
+	engine          string
+	chrootDir       string
+	images          map[string]string
+	solution        string
+	solutionMachine string
+	solutionUser    string
+	timeout         time.Duration
+	envFile         string
+}
+
+func NewTestCommand() *cobra.Command {
+	var opts testOptions
+
+	cmd := &cobra.Command{
+		Use:   "test",
+		Short: "Verify tasks against a local stand-in of the playground",
+		Long: `Run the init tasks, the solution script and the verification tasks
+(in dependency order) in a local stand-in for the playground machines:
+- docker or podman: one container per machine, built from the drive image
+- chroot: a single unpacked root filesystem`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runTest(cmd, &opts)
+		},
+	}
+
+	flags := cmd.Flags()
+
+	flags.StringVar(
+		&opts.path,
+		"path",
+		".",
+		`Path to load manifest from`,
+	)
+
+	flags.StringVar(
+		&opts.channel,
+		"channel",
+		"dev",
+		`Which channel to use`,
+	)
+
+	flags.StringSliceVar(
+		&opts.templateDirs,
+		"template-dir",
+		[]string{},
+		`Template directories to load machine presets (machines.yaml) from (can be specified multiple times)`,
+	)
+
+	flags.StringSliceVar(
+		&opts.dataDirs,
+		"data-dir",
+		[]string{},
+		`Additional data directories to load JSON files from (can be specified multiple times)`,
+	)
+
+	flags.StringSliceVar(
+		&opts.libraryDirs,
+		"library-dir",
+		[]string{},
+		`Library directories to load machine presets (machines.yaml) from (can be specified multiple times)`,
+	)
+
+	flags.StringSliceVar(
+		&opts.playgroundDirs,
+		"playground-dir",
+		[]string{},
+		`Directories containing local playgrounds to resolve base playgrounds from (can be specified multiple times)`,
+	)
+
+	flags.BoolVar(
+		&opts.remoteBase,
+		"remote-base",
+		false,
+		`Fetch base playgrounds that are not found locally from the server to inherit their spec (requires labctl)`,
+	)
+
+	addEnvFileFlag(flags, &opts.envFile)
+
+	flags.StringVar(
+		&opts.engine,
+		"engine",
+		"docker",
+		`Stand-in to run machines in (docker, podman or chroot)`,
+	)
+
+	flags.StringVar(
+		&opts.chrootDir,
+		"chroot-dir",
+		"",
+		`Root filesystem directory (required for the chroot engine)`,
+	)
+
+	flags.StringToStringVar(
+		&opts.images,
+		"image",
+		map[string]string{},
+		`Override the image of a machine (machine=image, can be specified multiple times)`,
+	)
+
+	flags.StringVar(
+		&opts.solution,
+		"solution",
+		"",
+		`Solution script to run (defaults to solution/solve.sh if it exists)`,
+	)
+
+	flags.StringVar(
+		&opts.solutionMachine,
+		"solution-machine",
+		"",
+		`Machine to run the solution on (defaults to the first machine)`,
+	)
+
+	flags.StringVar(
+		&opts.solutionUser,
+		"solution-user",
+		"",
+		`User to run the solution as (defaults to the default user of the machine)`,
+	)
+
+	flags.DurationVar(
+		&opts.timeout,
+		"timeout",
+		time.Minute,
+		`Timeout for tasks without timeout_seconds`,
+	)
+
+	return cmd
+}
+
+func runTest(cmd *cobra.Command, opts *testOptions) error {
+	secrets, err := loadSecrets(opts.envFile, opts.path)
+	if err != nil {
+		return err
+	}
+
+	root, err := os.OpenRoot(opts.path)
+	if err != nil {
+		return err
+	}
+
+	var standIn labx.StandIn
+
+	switch opts.engine {
+	case "docker", "podman":
+		standIn = labx.NewContainerStandIn(opts.engine)
+	case "chroot":
+		if opts.chrootDir == "" {
+			return errors.New("--chroot-dir is required for the chroot engine")
+		}
+
+		standIn = labx.ChrootStandIn{Dir: opts.chrootDir}
+	default:
+		return fmt.Errorf("unsupported engine: %s", opts.engine)
+	}
+
+	report, err := labx.RunHarness(cmd.Context(), labx.HarnessOpts{
+		Root:            root,
+		Channel:         opts.channel,
+		TemplateDirs:    dirFSs(opts.templateDirs),
+		DataDirs:        dirFSs(opts.dataDirs),
+		LibraryDirs:     dirFSs(opts.libraryDirs),
+		PlaygroundDirs:  dirFSs(opts.playgroundDirs),
+		RemoteBases:     opts.remoteBase,
+		StandIn:         standIn,
+		Images:          opts.images,
+		Solution:        opts.solution,
+		SolutionMachine: opts.solutionMachine,
+		SolutionUser:    opts.solutionUser,
+		DefaultTimeout:  opts.timeout,
+		Secrets:         secrets,
+	})
+	if err != nil {
+		return secrets.RedactError(err)
+	}
+
+	for _, result := range report.Results {
+		fmt.Printf(
+			"%s  %s (%s/%s) %s\n",
+			result.Status,
+			result.Name,
+			result.Machine,
+			result.User,
+			result.Duration.Round(time.Millisecond),
+		)
+
+		if result.Status == labx.TaskPassed {
+			continue
+		}
+
+		if result.Reason != "" {
+			fmt.Printf("      %s\n", secrets.Redact(result.Reason))
+		}
+
+		if output := strings.TrimSpace(secrets.Redact(result.Output)); output != "" {
+			for line := range strings.Lines(output) {
+				fmt.Printf("      | %s", line)
+			}
+
+			fmt.Println()
+		}
+	}
+
+	if !report.Passed() {
+		return errors.New("some tasks did not pass")
+	}
+
+	return nil
+}
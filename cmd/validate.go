@@ -0,0 +1,134 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/sagikazarmark/labx/labx"
+)
+
+type validateOptions struct {
+	path           string
+	channel        string
+	templateDirs   []string
+	dataDirs       []string
+	libraryDirs    []string
+	playgroundDirs []string
+	remoteBase     bool
+	envFile        string
+}
+
+func NewValidateCommand() *cobra.Command {
+	var opts validateOptions
+
+	cmd := &cobra.Command{
+		Use:   "validate",
+		Short: "Check content before publishing it",
+		Long: `Process the manifests (including lessons) like generate does without writing output,
+then inspect every OCI drive image and report:
+- images that are not built for linux/amd64 (or indexes without a linux/amd64 image)
+- images without layers
+- images larger than their drive`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runValidate(cmd, &opts)
+		},
+	}
+
+	flags := cmd.Flags()
+
+	flags.StringVar(
+		&opts.path,
+		"path",
+		".",
+		`Path to load manifest from`,
+	)
+
+	flags.StringVar(
+		&opts.channel,
+		"channel",
+		"dev",
+		`Which channel to use`,
+	)
+
+	flags.StringSliceVar(
+		&opts.templateDirs,
+		"template-dir",
+		[]string{},
+		`Global template directories (can be specified multiple times)`,
+	)
+
+	flags.StringSliceVar(
+		&opts.dataDirs,
+		"data-dir",
+		[]string{},
+		`Additional data directories to load JSON files from (can be specified multiple times)`,
+	)
+
+	flags.StringSliceVar(
+		&opts.libraryDirs,
+		"library-dir",
+		[]string{},
+		`Library directories to load machine presets (machines.yaml) from (can be specified multiple times)`,
+	)
+
+	flags.StringSliceVar(
+		&opts.playgroundDirs,
+		"playground-dir",
+		[]string{},
+		`Directories containing local playgrounds to resolve base playgrounds from (can be specified multiple times)`,
+	)
+
+	flags.BoolVar(
+		&opts.remoteBase,
+		"remote-base",
+		false,
+		`Fetch base playgrounds that are not found locally from the server to inherit their spec (requires labctl)`,
+	)
+
+	addEnvFileFlag(flags, &opts.envFile)
+
+	return cmd
+}
+
+func runValidate(cmd *cobra.Command, opts *validateOptions) error {
+	secrets, err := loadSecrets(opts.envFile, opts.path)
+	if err != nil {
+		return err
+	}
+
+	root, err := os.OpenRoot(opts.path)
+	if err != nil {
+		return err
+	}
+
+	report, err := labx.Validate(labx.ValidateOpts{
+		Root:           root,
+		Channel:        opts.channel,
+		TemplateDirs:   dirFSs(opts.templateDirs),
+		DataDirs:       dirFSs(opts.dataDirs),
+		LibraryDirs:    dirFSs(opts.libraryDirs),
+		PlaygroundDirs: dirFSs(opts.playgroundDirs),
+		RemoteBases:    opts.remoteBase,
+		Secrets:        secrets,
+		Context:        cmd.Context(),
+	})
+	if err != nil {
+		return secrets.RedactError(err)
+	}
+
+	var failures int
+
+	for _, image := range report.Images {
+		fmt.Println(secrets.Redact(image.String()))
+
+		failures += len(image.Problems)
+	}
+
+	if failures > 0 {
+		return fmt.Errorf("validate found %d problem(s)", failures)
+	}
+
+	return nil
+}
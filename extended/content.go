@@ -1,6 +1,8 @@
 package extended
 
 import (
+	"fmt"
+	"maps"
 	"slices"
 
 	"github.com/iximiuz/labctl/api"
@@ -21,7 +23,7 @@ type ContentManifest struct {
 	UpdatedAt   string                `yaml:"updatedAt"   json:"updatedAt"`
 	Cover       string                `yaml:"cover"       json:"cover"`
 	Playground  ContentPlaygroundSpec `yaml:"playground"  json:"playground"`
-	Tasks       map[string]Task       `yaml:"tasks"       json:"tasks"`
+	Tasks       Tasks                 `yaml:"tasks"       json:"tasks"`
 
 	// Challenge specific fields
 	Difficulty string `yaml:"difficulty,omitempty" json:"difficulty,omitempty"`
@@ -70,10 +72,41 @@ func (m ContentManifest) Convert() core.ContentManifest {
 	return v
 }
 
+// allTasks returns the tasks of the content and the init tasks generated for machines.
+func (m ContentManifest) allTasks() Tasks {
+	tasks := maps.Clone(m.Tasks)
+
+	for _, machine := range m.Playground.Machines {
+		for name, initTask := range machine.InitTasks {
+			if tasks == nil {
+				tasks = Tasks{}
+			}
+
+			if _, ok := tasks[name]; ok {
+				continue
+			}
+
+			tasks[name] = Task{
+				Machine:        initTask.Machine,
+				Init:           initTask.Init,
+				User:           initTask.User,
+				TimeoutSeconds: initTask.TimeoutSeconds,
+				Needs:          initTask.Needs,
+				Run:            initTask.Run,
+				decode:         initTask.decode,
+			}
+		}
+	}
+
+	return tasks
+}
+
 func (m ContentManifest) convertTasks() map[string]core.Task {
 	tasks := map[string]core.Task{}
 
-	for name, task := range m.Tasks {
+	allTasks := m.allTasks()
+
+	for name, task := range allTasks {
 		for _, machine := range task.Machine {
 			for _, user := range task.User {
 				newTask := task.ConvertCurrent(machine, user)
@@ -141,6 +174,11 @@ func (m ContentManifest) convertTasks() map[string]core.Task {
 					panic("unknown dependency:" + need)
 				}
 
+				// Startup files are decoded before other init tasks run on the machine
+				if task.Init && !task.decode {
+					newTask.Needs = append(newTask.Needs, decodeTaskNames(allTasks, machine)...)
+				}
+
 				tasks[task.currentName(name, machine, user)] = newTask
 			}
 		}
@@ -150,11 +188,21 @@ func (m ContentManifest) convertTasks() map[string]core.Task {
 }
 
 type ContentPlaygroundSpec struct {
-	Name     string                  `yaml:"name"     json:"name"`
-	Welcome  string                  `yaml:"welcome"  json:"welcome"`
-	Networks []api.PlaygroundNetwork `yaml:"networks" json:"networks"`
-	Machines PlaygroundMachines      `yaml:"machines" json:"machines"`
-	Tabs     []api.PlaygroundTab     `yaml:"tabs"     json:"tabs"`
+	Name     string                  `yaml:"name"              json:"name"`
+	Welcome  string                  `yaml:"welcome"           json:"welcome"`
+	Networks []api.PlaygroundNetwork `yaml:"networks"          json:"networks"`
+	Machines PlaygroundMachines      `yaml:"machines"          json:"machines"`
+	Tabs     []api.PlaygroundTab     `yaml:"tabs"              json:"tabs"`
+	AutoTabs AutoTabs                `yaml:"autoTabs,omitzero" json:"autoTabs,omitzero"`
+
+	// NetworkPool is the address pool subnets are allocated from (defaults to [DefaultNetworkPool]).
+	NetworkPool string `yaml:"networkPool,omitempty" json:"networkPool,omitempty"`
+
+	// Domain is the default domain of machine hostnames (defaults to [DefaultDomain]).
+	Domain string `yaml:"domain,omitempty" json:"domain,omitempty"`
+
+	// ClusterHosts adds every machine to the /etc/hosts file of every other machine (see [ClusterHosts]).
+	ClusterHosts bool `yaml:"clusterHosts,omitempty" json:"clusterHosts,omitempty"`
 
 	BaseName string             `yaml:"-" json:"-"`
 	Base     api.PlaygroundSpec `yaml:"-" json:"-"`
@@ -195,14 +243,11 @@ func (s ContentPlaygroundSpec) convertMachines() []core.ContentPlaygroundMachine
 		},
 	)
 
-	// Make sure to include startup files from parent playground and apply welcome message
+	// Merge machines with the parent playground and apply welcome message
 	machines := s.Machines.Convert()
 	for i, machine := range machines {
-		parentMachine := parentMachines[machine.Name]
-
-		machines[i].StartupFiles = append(
-			slices.Clone(parentMachine.StartupFiles),
-			machine.StartupFiles...)
+		machine = MergeMachine(parentMachines[machine.Name], machine, s.Machines[i].merge())
+		machines[i] = machine
 
 		// Apply welcome message to default users if specified
 		if s.Welcome != "" {
@@ -231,6 +276,25 @@ func (s ContentPlaygroundSpec) convertMachines() []core.ContentPlaygroundMachine
 	)
 }
 
+type Tasks map[string]Task
+
+// ExpandMachines expands machine patterns (e.g. node-*) of every task.
+func (t Tasks) ExpandMachines(machines []string) (Tasks, error) {
+	tasks := Tasks{}
+
+	for name, task := range t {
+		machine, err := task.Machine.ExpandPatterns(machines)
+		if err != nil {
+			return nil, fmt.Errorf("task %s: %w", name, err)
+		}
+
+		task.Machine = machine
+		tasks[name] = task
+	}
+
+	return tasks, nil
+}
+
 type Task struct {
 	Machine        StringList `yaml:"machine,omitempty" json:"machine,omitempty"`
 	Init           bool       `yaml:"init"              json:"init"`
@@ -241,6 +305,13 @@ type Task struct {
 	Run            string     `yaml:"run"               json:"run"`
 	HintCheck      string     `yaml:"hintcheck"         json:"hintcheck"`
 	FailCheck      string     `yaml:"failcheck"         json:"failcheck"`
+
+	// decode marks init tasks generated by [PlaygroundMachine.DecodeStartupFiles].
+	decode bool
+}
+
+func (t Task) decodes(machine string) bool {
+	return t.decode && slices.Contains(t.Machine, machine)
 }
 
 func (t Task) Convert() core.Task {
@@ -0,0 +1,110 @@
+package extended
+
+import (
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// EncodingBase64 marks startup files with base64 encoded content.
+const EncodingBase64 = "base64"
+
+// DecodeStartupFiles replaces base64 encoded startup files with a <path>.b64 startup file
+// and an init task that decodes it (the platform only accepts text startup files).
+//
+// Decode tasks are named after the path of the file (init_<machine>_decode_<path>)
+// and other init tasks on the machine run after them.
+// Names of init tasks of the machine and taskNames (tasks defined by the playground or content) are not reused.
+func (m PlaygroundMachine) DecodeStartupFiles(taskNames []string) PlaygroundMachine {
+	var startupFiles MachineStartupFiles
+
+	for _, startupFile := range m.StartupFiles {
+		if startupFile.Encoding != EncodingBase64 {
+			startupFiles = append(startupFiles, startupFile)
+
+			continue
+		}
+
+		encodedPath := startupFile.Path + ".b64"
+
+		startupFiles = append(startupFiles, MachineStartupFile{
+			Path:    encodedPath,
+			Content: startupFile.Content,
+			Mode:    "600",
+			Owner:   "root:root",
+		})
+
+		redirect := ">"
+		if startupFile.Append {
+			redirect = ">>"
+		}
+
+		var run strings.Builder
+
+		fmt.Fprintf(&run, "base64 -d %s %s %s\n", shellQuote(encodedPath), redirect, shellQuote(startupFile.Path))
+
+		if startupFile.Mode != "" {
+			fmt.Fprintf(&run, "chmod %s %s\n", shellQuote(startupFile.Mode), shellQuote(startupFile.Path))
+		}
+
+		if startupFile.Owner != "" {
+			fmt.Fprintf(&run, "chown %s %s\n", shellQuote(startupFile.Owner), shellQuote(startupFile.Path))
+		}
+
+		fmt.Fprintf(&run, "rm -f %s\n", shellQuote(encodedPath))
+
+		if m.InitTasks == nil {
+			m.InitTasks = InitTasks{}
+		}
+
+		m.InitTasks[m.decodeTaskName(startupFile.Path, taskNames)] = InitTask{
+			Machine: StringList{m.Name},
+			Init:    true,
+			User:    StringList{"root"},
+			Run:     run.String(),
+			decode:  true,
+		}
+	}
+
+	m.StartupFiles = startupFiles
+
+	return m
+}
+
+// decodeTaskName returns an unused init task name for decoding a startup file.
+func (m PlaygroundMachine) decodeTaskName(filePath string, taskNames []string) string {
+	segment := strings.Trim(nonTaskNameChars.ReplaceAllString(filePath, "_"), "_")
+
+	name := taskName("init", m.Name, "decode", segment)
+
+	// Paths may only differ in special characters (or the same file may be appended to)
+	for i := 2; ; i++ {
+		if _, ok := m.InitTasks[name]; !ok && !slices.Contains(taskNames, name) {
+			return name
+		}
+
+		name = taskName("init", m.Name, "decode", segment, fmt.Sprint(i))
+	}
+}
+
+var nonTaskNameChars = regexp.MustCompile(`[^A-Za-z0-9_]+`)
+
+// decodeTaskNames returns the (sorted) names of the tasks decoding startup files on a machine.
+func decodeTaskNames[T interface{ decodes(machine string) bool }](tasks map[string]T, machine string) []string {
+	var names []string
+
+	for _, name := range slices.Sorted(maps.Keys(tasks)) {
+		if tasks[name].decodes(machine) {
+			names = append(names, name)
+		}
+	}
+
+	return names
+}
+
+// shellQuote quotes a string for POSIX shells.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,118 @@
+package extended_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sagikazarmark/labx/extended"
+)
+
+func TestPlaygroundMachine_DecodeStartupFiles(t *testing.T) {
+	machine := extended.PlaygroundMachine{
+		Name: "dev-box",
+		StartupFiles: extended.MachineStartupFiles{
+			{Path: "/etc/motd", Content: "hello"},
+			{
+				Path:     "/usr/local/bin/tool",
+				Content:  "f0VMRgIBAQ==",
+				Mode:     "755",
+				Owner:    "root:root",
+				Encoding: extended.EncodingBase64,
+			},
+		},
+	}
+
+	machine = machine.DecodeStartupFiles(nil)
+
+	expectedStartupFiles := extended.MachineStartupFiles{
+		{Path: "/etc/motd", Content: "hello"},
+		{Path: "/usr/local/bin/tool.b64", Content: "f0VMRgIBAQ==", Mode: "600", Owner: "root:root"},
+	}
+
+	assert.Equal(t, expectedStartupFiles, machine.StartupFiles)
+
+	require.Len(t, machine.InitTasks, 1)
+
+	initTask := machine.InitTasks["init_dev_box_decode_usr_local_bin_tool"]
+
+	assert.Equal(t, extended.StringList{"dev-box"}, initTask.Machine)
+	assert.True(t, initTask.Init)
+	assert.Equal(t, extended.StringList{"root"}, initTask.User)
+	assert.Equal(t, `base64 -d '/usr/local/bin/tool.b64' > '/usr/local/bin/tool'
+chmod '755' '/usr/local/bin/tool'
+chown 'root:root' '/usr/local/bin/tool'
+rm -f '/usr/local/bin/tool.b64'
+`, initTask.Run)
+}
+
+func TestPlaygroundMachine_DecodeStartupFiles_Collision(t *testing.T) {
+	machine := extended.PlaygroundMachine{
+		Name: "dev",
+		StartupFiles: extended.MachineStartupFiles{
+			{Path: "/opt/data-1", Content: "AA==", Encoding: extended.EncodingBase64},
+			{Path: "/opt/data_1", Content: "AQ==", Encoding: extended.EncodingBase64},
+		},
+		InitTasks: extended.InitTasks{
+			"init_dev_decode_opt_data_1_2": {Machine: extended.StringList{"dev"}, User: extended.StringList{"root"}},
+		},
+	}
+
+	machine = machine.DecodeStartupFiles([]string{"init_dev_decode_opt_data_1_3"})
+
+	assert.Len(t, machine.InitTasks, 3)
+	assert.Contains(t, machine.InitTasks, "init_dev_decode_opt_data_1")
+	assert.Contains(t, machine.InitTasks, "init_dev_decode_opt_data_1_4")
+}
+
+func TestPlaygroundSpec_Convert_DecodeFirst(t *testing.T) {
+	spec := extended.PlaygroundSpec{
+		Machines: extended.PlaygroundMachines{
+			extended.PlaygroundMachine{
+				Name: "dev",
+				StartupFiles: extended.MachineStartupFiles{
+					{Path: "/usr/local/bin/tool", Content: "f0VMRgIBAQ==", Encoding: extended.EncodingBase64},
+				},
+			}.DecodeStartupFiles(nil),
+			{Name: "db"},
+		},
+		InitTasks: extended.InitTasks{
+			"init_tool": {
+				Machine: extended.StringList{"dev", "db"},
+				Init:    true,
+				User:    extended.StringList{"root"},
+				Run:     "tool",
+			},
+		},
+	}
+
+	initTasks := spec.Convert().InitTasks
+
+	assert.Equal(t, []string{"init_dev_decode_usr_local_bin_tool"}, initTasks["init_tool_dev"].Needs)
+	assert.Empty(t, initTasks["init_tool_db"].Needs)
+	assert.Empty(t, initTasks["init_dev_decode_usr_local_bin_tool"].Needs)
+}
+
+func TestPlaygroundSpec_Convert_GeneratedInitTasks(t *testing.T) {
+	spec := extended.PlaygroundSpec{
+		Machines: extended.PlaygroundMachines{
+			{
+				Name: "dev",
+				InitTasks: extended.InitTasks{
+					"init_dev_decode_usr_local_bin_tool": {
+						Machine: extended.StringList{"dev"},
+						Init:    true,
+						User:    extended.StringList{"root"},
+						Run:     "true",
+					},
+				},
+			},
+		},
+	}
+
+	initTasks := spec.Convert().InitTasks
+
+	assert.Contains(t, initTasks, "init_dev_decode_usr_local_bin_tool")
+	assert.Equal(t, "dev", initTasks["init_dev_decode_usr_local_bin_tool"].Machine)
+}
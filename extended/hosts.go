@@ -0,0 +1,91 @@
+package extended
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/iximiuz/labctl/api"
+)
+
+// DefaultDomain is the domain of machine hostnames when neither the machine nor the playground sets one.
+const DefaultDomain = "local"
+
+// HostNames returns the names a machine is resolvable by: the hostname, the hostname in the domain of the machine and the aliases.
+func (m PlaygroundMachine) HostNames() []string {
+	var names []string
+
+	if m.Hostname != "" {
+		names = append(names, m.Hostname, m.Hostname+"."+cmp.Or(m.Domain, DefaultDomain))
+	}
+
+	return append(names, m.Aliases...)
+}
+
+// ClusterHosts appends the host names of every other machine with an address to the /etc/hosts file of each machine.
+//
+// Machines are resolvable by their host names (see [PlaygroundMachine.HostNames]) or by their name when they have none.
+// The first interface with an address is used. Machines of the base playground are resolvable by their name.
+func ClusterHosts(machines PlaygroundMachines, base []api.PlaygroundMachine) PlaygroundMachines {
+	type host struct {
+		name  string
+		entry string
+	}
+
+	var hosts []host
+
+	for _, machine := range InheritMachines(base, machines.Convert(), machines.Merges()) {
+		if machine.Network == nil {
+			continue
+		}
+
+		i := slices.IndexFunc(machine.Network.Interfaces, func(iface api.MachineNetworkInterface) bool {
+			return iface.Address != ""
+		})
+		if i < 0 {
+			continue
+		}
+
+		addr, err := parseInterfaceAddress(machine.Network.Interfaces[i].Address)
+		if err != nil {
+			continue
+		}
+
+		names := []string{machine.Name}
+
+		j := slices.IndexFunc(machines, func(m PlaygroundMachine) bool { return m.Name == machine.Name })
+		if j >= 0 && len(machines[j].HostNames()) > 0 {
+			names = machines[j].HostNames()
+		}
+
+		hosts = append(hosts, host{
+			name:  machine.Name,
+			entry: fmt.Sprintf("%-15s %s\n", addr, strings.Join(names, " ")),
+		})
+	}
+
+	machines = slices.Clone(machines)
+
+	for i, machine := range machines {
+		var content strings.Builder
+
+		for _, host := range hosts {
+			if host.name != machine.Name {
+				content.WriteString(host.entry)
+			}
+		}
+
+		if content.Len() == 0 {
+			continue
+		}
+
+		machines[i].StartupFiles = append(slices.Clone(machine.StartupFiles), MachineStartupFile{
+			Path:    "/etc/hosts",
+			Content: content.String(),
+			Append:  true,
+		})
+	}
+
+	return machines
+}
@@ -0,0 +1,84 @@
+package extended_test
+
+import (
+	"testing"
+
+	"github.com/iximiuz/labctl/api"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/sagikazarmark/labx/extended"
+)
+
+func TestPlaygroundMachine_HostNames(t *testing.T) {
+	tests := []struct {
+		name     string
+		machine  extended.PlaygroundMachine
+		expected []string
+	}{
+		{"none", extended.PlaygroundMachine{Name: "node"}, nil},
+		{"default domain", extended.PlaygroundMachine{Hostname: "openbao"}, []string{"openbao", "openbao.local"}},
+		{
+			"domain and aliases",
+			extended.PlaygroundMachine{Hostname: "openbao", Domain: "lab.internal", Aliases: []string{"vault"}},
+			[]string{"openbao", "openbao.lab.internal", "vault"},
+		},
+		{"aliases only", extended.PlaygroundMachine{Aliases: []string{"vault"}}, []string{"vault"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, test.machine.HostNames())
+		})
+	}
+}
+
+func TestPlaygroundMachine_Convert_Aliases(t *testing.T) {
+	machine := extended.PlaygroundMachine{
+		Name:     "node",
+		Hostname: "openbao",
+		Domain:   "lab.internal",
+		Aliases:  []string{"vault"},
+	}.Convert()
+
+	assert.Equal(t, api.MachineStartupFile{
+		Path:    "/etc/hosts",
+		Content: "127.0.0.1       openbao openbao.lab.internal vault\n",
+		Append:  true,
+	}, machine.StartupFiles[1])
+}
+
+func TestClusterHosts(t *testing.T) {
+	iface := func(address string) *api.MachineNetwork {
+		return &api.MachineNetwork{Interfaces: []api.MachineNetworkInterface{{Network: "local", Address: address}}}
+	}
+
+	base := []api.PlaygroundMachine{
+		{Name: "registry", Network: iface("172.16.0.10/24")},
+	}
+
+	machines := extended.PlaygroundMachines{
+		{Name: "node-01", Hostname: "cplane", Aliases: []string{"k8s"}, Network: iface("172.16.0.2/24")},
+		{Name: "node-02", Network: iface("172.16.0.3/24")},
+		{Name: "no-address"},
+	}
+
+	machines = extended.ClusterHosts(machines, base)
+
+	assert.Equal(t, extended.MachineStartupFiles{
+		{
+			Path:    "/etc/hosts",
+			Content: "172.16.0.10     registry\n172.16.0.3      node-02\n",
+			Append:  true,
+		},
+	}, machines[0].StartupFiles)
+
+	assert.Equal(t, extended.MachineStartupFiles{
+		{
+			Path:    "/etc/hosts",
+			Content: "172.16.0.10     registry\n172.16.0.2      cplane cplane.local k8s\n",
+			Append:  true,
+		},
+	}, machines[1].StartupFiles)
+
+	assert.Len(t, machines[2].StartupFiles, 1)
+}
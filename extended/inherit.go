@@ -0,0 +1,97 @@
+package extended
+
+import (
+	"maps"
+	"slices"
+
+	"github.com/iximiuz/labctl/api"
+)
+
+// InheritPlaygroundSpec merges a playground spec with the spec of its base playground:
+//
+//   - networks, tabs, init tasks and init conditions of the base playground are kept unless redefined (by name or key)
+//   - machines are merged with the base machine of the same name (see [MergeMachine])
+//   - registry auth and port forwards are taken from the base playground when undefined
+//   - access control is never inherited
+func InheritPlaygroundSpec(
+	base api.PlaygroundSpec,
+	spec api.PlaygroundSpec,
+	merges map[string]MachineMerge,
+) api.PlaygroundSpec {
+	spec.Networks = inheritList(base.Networks, spec.Networks, func(n api.PlaygroundNetwork) string { return n.Name })
+	spec.Machines = InheritMachines(base.Machines, spec.Machines, merges)
+	spec.Tabs = inheritList(base.Tabs, spec.Tabs, TabKey)
+	spec.InitTasks = inheritMap(base.InitTasks, spec.InitTasks)
+	spec.InitConditions.Values = inheritList(
+		base.InitConditions.Values,
+		spec.InitConditions.Values,
+		func(v api.InitConditionValue) string { return v.Key },
+	)
+
+	if spec.RegistryAuth == "" {
+		spec.RegistryAuth = base.RegistryAuth
+	}
+
+	if len(spec.PortForwards) == 0 {
+		spec.PortForwards = base.PortForwards
+	}
+
+	return spec
+}
+
+// InheritMachines merges machines with the base machines of the same name.
+//
+// Base machines keep their position, new machines are appended.
+func InheritMachines(
+	base []api.PlaygroundMachine,
+	machines []api.PlaygroundMachine,
+	merges map[string]MachineMerge,
+) []api.PlaygroundMachine {
+	if len(base) == 0 {
+		return machines
+	}
+
+	result := slices.Clone(base)
+
+	for _, machine := range machines {
+		i := slices.IndexFunc(result, func(m api.PlaygroundMachine) bool { return m.Name == machine.Name })
+		if i < 0 {
+			result = append(result, machine)
+
+			continue
+		}
+
+		result[i] = MergeMachine(result[i], machine, merges[machine.Name])
+	}
+
+	return result
+}
+
+// TabKey returns the key tabs are merged by.
+func TabKey(tab api.PlaygroundTab) string {
+	if tab.ID != "" {
+		return tab.ID
+	}
+
+	return tab.Name
+}
+
+// inheritList replaces base items with the items of the same key, and appends the rest.
+func inheritList[T any](base []T, override []T, key func(T) string) []T {
+	if len(base) == 0 {
+		return override
+	}
+
+	return mergeList(MergeMerge, base, override, key, takeOverride)
+}
+
+func inheritMap[V any](base map[string]V, override map[string]V) map[string]V {
+	if len(base) == 0 {
+		return override
+	}
+
+	result := maps.Clone(base)
+	maps.Copy(result, override)
+
+	return result
+}
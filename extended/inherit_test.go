@@ -0,0 +1,106 @@
+package extended_test
+
+import (
+	"testing"
+
+	"github.com/iximiuz/labctl/api"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/sagikazarmark/labx/extended"
+)
+
+func TestInheritPlaygroundSpec(t *testing.T) {
+	base := api.PlaygroundSpec{
+		Networks: []api.PlaygroundNetwork{
+			{Name: "local", Subnet: "172.16.0.0/24"},
+			{Name: "backend", Subnet: "172.16.1.0/24"},
+		},
+		Machines: []api.PlaygroundMachine{
+			{
+				Name:         "dev",
+				Users:        []api.MachineUser{{Name: "laborant", Default: true}},
+				StartupFiles: []api.MachineStartupFile{{Path: "/etc/base"}},
+			},
+			{Name: "db"},
+		},
+		Tabs: []api.PlaygroundTab{
+			{Kind: "terminal", Name: "dev", Machine: "dev"},
+			{Kind: "ide", Name: "IDE"},
+		},
+		InitTasks: map[string]api.InitTask{
+			"init_base": {Name: "init_base", Run: "true"},
+			"init_db":   {Name: "init_db", Run: "true"},
+		},
+		InitConditions: api.InitConditions{
+			Values: []api.InitConditionValue{{Key: "version", Default: "1"}},
+		},
+		RegistryAuth: "ghcr",
+	}
+
+	spec := api.PlaygroundSpec{
+		Networks: []api.PlaygroundNetwork{
+			{Name: "backend", Subnet: "10.0.0.0/24"},
+		},
+		Machines: []api.PlaygroundMachine{
+			{
+				Name:         "dev",
+				StartupFiles: []api.MachineStartupFile{{Path: "/etc/child"}},
+			},
+			{Name: "cache"},
+		},
+		Tabs: []api.PlaygroundTab{
+			{Kind: "terminal", Name: "cache", Machine: "cache"},
+		},
+		InitTasks: map[string]api.InitTask{
+			"init_db": {Name: "init_db", Run: "false"},
+		},
+		InitConditions: api.InitConditions{
+			Values: []api.InitConditionValue{{Key: "version", Default: "2"}},
+		},
+		AccessControl: api.PlaygroundAccessControl{CanRead: []string{"anyone"}},
+	}
+
+	expected := api.PlaygroundSpec{
+		Networks: []api.PlaygroundNetwork{
+			{Name: "local", Subnet: "172.16.0.0/24"},
+			{Name: "backend", Subnet: "10.0.0.0/24"},
+		},
+		Machines: []api.PlaygroundMachine{
+			{
+				Name:  "dev",
+				Users: []api.MachineUser{{Name: "laborant", Default: true}},
+				StartupFiles: []api.MachineStartupFile{
+					{Path: "/etc/base"},
+					{Path: "/etc/child"},
+				},
+			},
+			{Name: "db"},
+			{Name: "cache"},
+		},
+		Tabs: []api.PlaygroundTab{
+			{Kind: "terminal", Name: "dev", Machine: "dev"},
+			{Kind: "ide", Name: "IDE"},
+			{Kind: "terminal", Name: "cache", Machine: "cache"},
+		},
+		InitTasks: map[string]api.InitTask{
+			"init_base": {Name: "init_base", Run: "true"},
+			"init_db":   {Name: "init_db", Run: "false"},
+		},
+		InitConditions: api.InitConditions{
+			Values: []api.InitConditionValue{{Key: "version", Default: "2"}},
+		},
+		RegistryAuth:  "ghcr",
+		AccessControl: api.PlaygroundAccessControl{CanRead: []string{"anyone"}},
+	}
+
+	assert.Equal(t, expected, extended.InheritPlaygroundSpec(base, spec, nil))
+}
+
+func TestInheritPlaygroundSpec_NoBase(t *testing.T) {
+	spec := api.PlaygroundSpec{
+		Machines: []api.PlaygroundMachine{{Name: "dev"}},
+		Tabs:     []api.PlaygroundTab{{Kind: "ide", Name: "IDE"}},
+	}
+
+	assert.Equal(t, spec, extended.InheritPlaygroundSpec(api.PlaygroundSpec{}, spec, nil))
+}
@@ -0,0 +1,356 @@
+package extended
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+
+	"github.com/iximiuz/labctl/api"
+)
+
+// MergeStrategy describes how a machine field is merged with the same field of a base machine
+// (a machine of the base playground or a preset).
+type MergeStrategy string
+
+const (
+	// MergeReplace uses the field of the machine, or the field of the base machine when undefined.
+	MergeReplace MergeStrategy = "replace"
+
+	// MergeAppend appends the items of the machine to the items of the base machine (lists only).
+	MergeAppend MergeStrategy = "append"
+
+	// MergeMerge merges items with the same key (or fields of structs) giving precedence to the machine.
+	MergeMerge MergeStrategy = "merge"
+)
+
+// MachineMergeStrategies configures the merge strategy of each machine field.
+//
+// Empty values fall back to [DefaultMachineMergeStrategies].
+type MachineMergeStrategies struct {
+	Users        MergeStrategy `yaml:"users,omitempty"        json:"users,omitempty"`
+	Resources    MergeStrategy `yaml:"resources,omitempty"    json:"resources,omitempty"`
+	Kernel       MergeStrategy `yaml:"kernel,omitempty"       json:"kernel,omitempty"`
+	Drives       MergeStrategy `yaml:"drives,omitempty"       json:"drives,omitempty"`
+	Network      MergeStrategy `yaml:"network,omitempty"      json:"network,omitempty"`
+	NoSSH        MergeStrategy `yaml:"noSSH,omitempty"        json:"noSSH,omitempty"`
+	StartupFiles MergeStrategy `yaml:"startupFiles,omitempty" json:"startupFiles,omitempty"`
+}
+
+// DefaultMachineMergeStrategies are the merge rules applied when a machine doesn't configure them:
+//
+//   - startup files are appended to the startup files of the base machine
+//   - users are copied from the base machine when none are defined
+//   - resources, kernel, drives, network and noSSH are taken from the base machine when undefined
+var DefaultMachineMergeStrategies = MachineMergeStrategies{
+	Users:        MergeReplace,
+	Resources:    MergeReplace,
+	Kernel:       MergeReplace,
+	Drives:       MergeReplace,
+	Network:      MergeReplace,
+	NoSSH:        MergeReplace,
+	StartupFiles: MergeAppend,
+}
+
+func (s MachineMergeStrategies) withDefaults() MachineMergeStrategies {
+	d := DefaultMachineMergeStrategies
+
+	return MachineMergeStrategies{
+		Users:        orDefault(s.Users, d.Users),
+		Resources:    orDefault(s.Resources, d.Resources),
+		Kernel:       orDefault(s.Kernel, d.Kernel),
+		Drives:       orDefault(s.Drives, d.Drives),
+		Network:      orDefault(s.Network, d.Network),
+		NoSSH:        orDefault(s.NoSSH, d.NoSSH),
+		StartupFiles: orDefault(s.StartupFiles, d.StartupFiles),
+	}
+}
+
+func orDefault(s MergeStrategy, d MergeStrategy) MergeStrategy {
+	if s == "" {
+		return d
+	}
+
+	return s
+}
+
+// Validate checks that every strategy is supported by its field.
+func (s MachineMergeStrategies) Validate() error {
+	listStrategies := []MergeStrategy{"", MergeReplace, MergeAppend, MergeMerge}
+	scalarStrategies := []MergeStrategy{"", MergeReplace, MergeMerge}
+
+	fields := []struct {
+		name       string
+		strategy   MergeStrategy
+		strategies []MergeStrategy
+	}{
+		{"users", s.Users, listStrategies},
+		{"resources", s.Resources, scalarStrategies},
+		{"kernel", s.Kernel, scalarStrategies},
+		{"drives", s.Drives, listStrategies},
+		{"network", s.Network, listStrategies},
+		{"noSSH", s.NoSSH, scalarStrategies},
+		{"startupFiles", s.StartupFiles, listStrategies},
+	}
+
+	for _, field := range fields {
+		if !slices.Contains(field.strategies, field.strategy) {
+			return fmt.Errorf("unsupported merge strategy for %s: %s", field.name, field.strategy)
+		}
+	}
+
+	return nil
+}
+
+// MachineMerge configures how a machine is merged with the machine of the same name of the base playground.
+type MachineMerge struct {
+	Strategies MachineMergeStrategies
+
+	// NoSSH is the noSSH value of the machine (nil if undefined).
+	// Converted machines can't tell an explicit false from an undefined value.
+	NoSSH *bool
+}
+
+// MergeMachine merges a machine with a machine of the base playground.
+//
+// Only one user remains the default: a default user of the machine wins over the one of the base machine.
+func MergeMachine(
+	base api.PlaygroundMachine,
+	machine api.PlaygroundMachine,
+	merge MachineMerge,
+) api.PlaygroundMachine {
+	s := merge.Strategies.withDefaults()
+
+	machine.Users = singleDefaultUser(
+		mergeList(s.Users, base.Users, machine.Users, userKey, mergeUser),
+		machine.Users,
+		userKey,
+		func(user *api.MachineUser) *bool { return &user.Default },
+	)
+	machine.Resources = mergePointer(s.Resources, base.Resources, machine.Resources, mergeResources)
+	machine.Kernel = mergePointer(s.Kernel, base.Kernel, machine.Kernel, mergeKernel)
+	machine.Drives = mergeList(s.Drives, base.Drives, machine.Drives, driveKey, mergeDrive)
+	machine.Network = mergeMachineNetwork(s.Network, base.Network, machine.Network)
+	machine.NoSSH = *mergeBool(s.NoSSH, &base.NoSSH, cmp.Or(merge.NoSSH, definedBool(machine.NoSSH)))
+	machine.StartupFiles = mergeList(
+		s.StartupFiles,
+		base.StartupFiles,
+		machine.StartupFiles,
+		func(f api.MachineStartupFile) string { return f.Path },
+		takeOverride,
+	)
+
+	return machine
+}
+
+// mergeList merges two lists.
+//
+// Items with an empty key are never merged.
+func mergeList[T any](
+	strategy MergeStrategy,
+	base []T,
+	override []T,
+	key func(T) string,
+	merge func(base T, override T) T,
+) []T {
+	switch strategy {
+	case MergeAppend:
+		return append(slices.Clone(base), override...)
+
+	case MergeMerge:
+		result := slices.Clone(base)
+
+		for _, item := range override {
+			i := slices.IndexFunc(result, func(b T) bool {
+				return key(item) != "" && key(b) == key(item)
+			})
+
+			if i < 0 {
+				result = append(result, item)
+
+				continue
+			}
+
+			result[i] = merge(result[i], item)
+		}
+
+		return result
+
+	default:
+		if len(override) == 0 {
+			return slices.Clone(base)
+		}
+
+		return override
+	}
+}
+
+func mergePointer[T any](strategy MergeStrategy, base *T, override *T, merge func(base T, override T) T) *T {
+	if override == nil {
+		return base
+	}
+
+	if base == nil || strategy != MergeMerge {
+		return override
+	}
+
+	merged := merge(*base, *override)
+
+	return &merged
+}
+
+// mergeBool merges optional booleans (nil is undefined).
+func mergeBool(strategy MergeStrategy, base *bool, override *bool) *bool {
+	switch {
+	case base == nil:
+		return override
+	case override == nil:
+		return base
+	case strategy == MergeMerge:
+		merged := *base || *override
+
+		return &merged
+	default:
+		return override
+	}
+}
+
+// definedBool returns a defined boolean for true (false can't be told apart from undefined).
+func definedBool(value bool) *bool {
+	if !value {
+		return nil
+	}
+
+	return &value
+}
+
+// singleDefaultUser makes sure there is only one default user among merged users:
+// the default user of the machine wins over the default user of the base machine.
+func singleDefaultUser[T any](users []T, override []T, key func(T) string, isDefault func(user *T) *bool) []T {
+	defaultKey, found := "", false
+
+	// Users of the machine are checked last, so they take precedence
+	for _, list := range [][]T{users, override} {
+		for i := range list {
+			if *isDefault(&list[i]) {
+				defaultKey, found = key(list[i]), true
+			}
+		}
+	}
+
+	if !found {
+		return users
+	}
+
+	users = slices.Clone(users)
+
+	keep := -1
+
+	for i := range users {
+		if *isDefault(&users[i]) && key(users[i]) == defaultKey {
+			keep = i
+		}
+	}
+
+	for i := range users {
+		if i != keep {
+			*isDefault(&users[i]) = false
+		}
+	}
+
+	return users
+}
+
+func takeOverride[T any](_ T, override T) T {
+	return override
+}
+
+func userKey(user api.MachineUser) string {
+	return user.Name
+}
+
+func mergeUser(base api.MachineUser, override api.MachineUser) api.MachineUser {
+	base.Default = base.Default || override.Default
+
+	if override.Welcome != "" {
+		base.Welcome = override.Welcome
+	}
+
+	return base
+}
+
+func mergeResources(base api.MachineResources, override api.MachineResources) api.MachineResources {
+	if override.CPUCount != 0 {
+		base.CPUCount = override.CPUCount
+	}
+
+	if override.RAMSize != "" {
+		base.RAMSize = override.RAMSize
+	}
+
+	return base
+}
+
+func mergeKernel(base api.MachineKernel, override api.MachineKernel) api.MachineKernel {
+	if override.Source != "" {
+		base.Source = override.Source
+	}
+
+	if override.Snapshot != nil {
+		base.Snapshot = override.Snapshot
+	}
+
+	return base
+}
+
+func driveKey(drive api.MachineDrive) string {
+	return drive.Mount
+}
+
+func mergeDrive(base api.MachineDrive, override api.MachineDrive) api.MachineDrive {
+	if override.Source != "" {
+		base.Source = override.Source
+	}
+
+	if override.Size != "" {
+		base.Size = override.Size
+	}
+
+	if override.Filesystem != "" {
+		base.Filesystem = override.Filesystem
+	}
+
+	if override.Snapshot != nil {
+		base.Snapshot = override.Snapshot
+	}
+
+	base.ReadOnly = base.ReadOnly || override.ReadOnly
+	base.Persistent = base.Persistent || override.Persistent
+
+	return base
+}
+
+func mergeMachineNetwork(
+	strategy MergeStrategy,
+	base *api.MachineNetwork,
+	override *api.MachineNetwork,
+) *api.MachineNetwork {
+	if base == nil || override == nil || strategy == MergeReplace {
+		return mergePointer(strategy, base, override, takeOverride)
+	}
+
+	return &api.MachineNetwork{
+		Interfaces: mergeList(
+			strategy,
+			base.Interfaces,
+			override.Interfaces,
+			func(i api.MachineNetworkInterface) string { return i.Network },
+			func(base api.MachineNetworkInterface, override api.MachineNetworkInterface) api.MachineNetworkInterface {
+				if override.Address != "" {
+					base.Address = override.Address
+				}
+
+				return base
+			},
+		),
+	}
+}
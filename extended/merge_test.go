@@ -0,0 +1,146 @@
+package extended_test
+
+import (
+	"testing"
+
+	"github.com/iximiuz/labctl/api"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/sagikazarmark/labx/core"
+	"github.com/sagikazarmark/labx/extended"
+)
+
+func TestContentPlaygroundSpec_MergeMachines(t *testing.T) {
+	base := api.PlaygroundSpec{
+		Machines: []api.PlaygroundMachine{
+			{
+				Name:         "node",
+				Users:        []api.MachineUser{{Name: "laborant", Default: true}},
+				Kernel:       &api.MachineKernel{Source: "oci://example.com/kernel"},
+				Drives:       []api.MachineDrive{{Source: "oci://example.com/rootfs", Mount: "/"}},
+				Resources:    &api.MachineResources{CPUCount: 2, RAMSize: "4Gi"},
+				StartupFiles: []api.MachineStartupFile{{Path: "/etc/base"}},
+				NoSSH:        true,
+			},
+		},
+	}
+
+	testCases := []struct {
+		name     string
+		machine  extended.PlaygroundMachine
+		expected core.ContentPlaygroundMachine
+	}{
+		{
+			name: "defaults",
+			machine: extended.PlaygroundMachine{
+				Name:         "node",
+				StartupFiles: extended.MachineStartupFiles{{Path: "/etc/content"}},
+			},
+			expected: core.ContentPlaygroundMachine{
+				Name:      "node",
+				Users:     []api.MachineUser{{Name: "laborant", Default: true}},
+				Kernel:    &api.MachineKernel{Source: "oci://example.com/kernel"},
+				Drives:    []api.MachineDrive{{Source: "oci://example.com/rootfs", Mount: "/"}},
+				Resources: &api.MachineResources{CPUCount: 2, RAMSize: "4Gi"},
+				StartupFiles: []api.MachineStartupFile{
+					{Path: "/etc/base"},
+					{Path: "/etc/content"},
+				},
+				NoSSH: true,
+			},
+		},
+		{
+			name: "explicit noSSH",
+			machine: extended.PlaygroundMachine{
+				Name:  "node",
+				NoSSH: lo.ToPtr(false),
+			},
+			expected: core.ContentPlaygroundMachine{
+				Name:         "node",
+				Users:        []api.MachineUser{{Name: "laborant", Default: true}},
+				Kernel:       &api.MachineKernel{Source: "oci://example.com/kernel"},
+				Drives:       []api.MachineDrive{{Source: "oci://example.com/rootfs", Mount: "/"}},
+				Resources:    &api.MachineResources{CPUCount: 2, RAMSize: "4Gi"},
+				StartupFiles: []api.MachineStartupFile{{Path: "/etc/base"}},
+			},
+		},
+		{
+			name: "default user",
+			machine: extended.PlaygroundMachine{
+				Name:  "node",
+				Users: extended.MachineUsers{{Name: "root", Default: true}},
+				Merge: extended.MachineMergeStrategies{
+					Users: extended.MergeAppend,
+				},
+			},
+			expected: core.ContentPlaygroundMachine{
+				Name: "node",
+				Users: []api.MachineUser{
+					{Name: "laborant"},
+					{Name: "root", Default: true},
+				},
+				Kernel:       &api.MachineKernel{Source: "oci://example.com/kernel"},
+				Drives:       []api.MachineDrive{{Source: "oci://example.com/rootfs", Mount: "/"}},
+				Resources:    &api.MachineResources{CPUCount: 2, RAMSize: "4Gi"},
+				StartupFiles: []api.MachineStartupFile{{Path: "/etc/base"}},
+				NoSSH:        true,
+			},
+		},
+		{
+			name: "strategies",
+			machine: extended.PlaygroundMachine{
+				Name:         "node",
+				Users:        extended.MachineUsers{{Name: "root"}},
+				Resources:    &api.MachineResources{RAMSize: "8Gi"},
+				StartupFiles: extended.MachineStartupFiles{{Path: "/etc/content"}},
+				Merge: extended.MachineMergeStrategies{
+					Users:        extended.MergeAppend,
+					Resources:    extended.MergeMerge,
+					NoSSH:        extended.MergeMerge,
+					StartupFiles: extended.MergeReplace,
+				},
+			},
+			expected: core.ContentPlaygroundMachine{
+				Name: "node",
+				Users: []api.MachineUser{
+					{Name: "laborant", Default: true},
+					{Name: "root"},
+				},
+				Kernel:       &api.MachineKernel{Source: "oci://example.com/kernel"},
+				Drives:       []api.MachineDrive{{Source: "oci://example.com/rootfs", Mount: "/"}},
+				Resources:    &api.MachineResources{CPUCount: 2, RAMSize: "8Gi"},
+				StartupFiles: []api.MachineStartupFile{{Path: "/etc/content"}},
+				NoSSH:        true,
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			spec := extended.ContentPlaygroundSpec{
+				Machines: extended.PlaygroundMachines{testCase.machine},
+				BaseName: "k8s",
+				Base:     base,
+			}
+
+			assert.Equal(t, []core.ContentPlaygroundMachine{testCase.expected}, spec.Convert().Machines)
+		})
+	}
+}
+
+func TestMergeMachine_NoSSH(t *testing.T) {
+	base := api.PlaygroundMachine{Name: "node", NoSSH: true}
+
+	merged := extended.MergeMachine(base, api.PlaygroundMachine{Name: "node"}, extended.MachineMerge{})
+	assert.True(t, merged.NoSSH)
+
+	merged = extended.MergeMachine(base, api.PlaygroundMachine{Name: "node"}, extended.MachineMerge{NoSSH: lo.ToPtr(false)})
+	assert.False(t, merged.NoSSH)
+}
+
+func TestMachineMergeStrategies_Validate(t *testing.T) {
+	assert.NoError(t, extended.MachineMergeStrategies{Users: extended.MergeMerge}.Validate())
+	assert.Error(t, extended.MachineMergeStrategies{Kernel: extended.MergeAppend}.Validate())
+	assert.Error(t, extended.MachineMergeStrategies{Users: "unknown"}.Validate())
+}
@@ -0,0 +1,363 @@
+package extended
+
+import (
+	"fmt"
+	"net/netip"
+	"slices"
+	"strings"
+
+	"github.com/iximiuz/labctl/api"
+)
+
+// DefaultNetworkPool is the address pool subnets are allocated from when the playground doesn't set one.
+const DefaultNetworkPool = "10.100.0.0/16"
+
+// allocatedSubnetBits is the size of allocated subnets.
+const allocatedSubnetBits = 24
+
+// AllocateNetworks allocates subnets (from pool) for networks and addresses for machine interfaces that omit them.
+//
+// It also validates that subnets don't overlap, machine interfaces reference declared networks
+// (including the networks of the base playground) and addresses fall inside the subnet of their network.
+func AllocateNetworks(
+	pool string,
+	networks []api.PlaygroundNetwork,
+	machines PlaygroundMachines,
+	base api.PlaygroundSpec,
+) ([]api.PlaygroundNetwork, PlaygroundMachines, error) {
+	networks = slices.Clone(networks)
+	machines = slices.Clone(machines)
+
+	allNetworks := inheritList(base.Networks, networks, func(n api.PlaygroundNetwork) string { return n.Name })
+
+	subnets := map[string]netip.Prefix{}
+
+	for _, network := range allNetworks {
+		if network.Subnet == "" {
+			continue
+		}
+
+		subnet, err := parseSubnet(network)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		for name, other := range subnets {
+			if subnet.Overlaps(other) {
+				return nil, nil, fmt.Errorf("network %s: subnet %s overlaps with network %s (%s)", network.Name, subnet, name, other)
+			}
+		}
+
+		subnets[network.Name] = subnet
+	}
+
+	for i, network := range networks {
+		if network.Subnet != "" {
+			continue
+		}
+
+		subnet, err := allocateSubnet(pool, subnets)
+		if err != nil {
+			return nil, nil, fmt.Errorf("network %s: %w", network.Name, err)
+		}
+
+		networks[i].Subnet = subnet.String()
+		subnets[network.Name] = subnet
+	}
+
+	// Addresses in use by network
+	used := map[string][]netip.Addr{}
+
+	for _, network := range allNetworks {
+		if network.Gateway == "" {
+			continue
+		}
+
+		gateway, err := netip.ParseAddr(network.Gateway)
+		if err != nil {
+			return nil, nil, fmt.Errorf("network %s: invalid gateway %q: %w", network.Name, network.Gateway, err)
+		}
+
+		if !subnets[network.Name].Contains(gateway) {
+			return nil, nil, fmt.Errorf("network %s: gateway %s is outside of subnet %s", network.Name, gateway, subnets[network.Name])
+		}
+
+		used[network.Name] = append(used[network.Name], gateway)
+	}
+
+	// Base machines that are redefined get their interfaces from the machine
+	for _, machine := range base.Machines {
+		if machine.Network == nil || slices.ContainsFunc(machines, func(m PlaygroundMachine) bool { return m.Name == machine.Name }) {
+			continue
+		}
+
+		for _, iface := range machine.Network.Interfaces {
+			addr, err := parseInterfaceAddress(iface.Address)
+			if err == nil && iface.Address != "" {
+				used[iface.Network] = append(used[iface.Network], addr)
+			}
+		}
+	}
+
+	for i, machine := range machines {
+		if machine.Network == nil {
+			continue
+		}
+
+		interfaces := slices.Clone(machine.Network.Interfaces)
+
+		for _, iface := range interfaces {
+			if iface.Network == "" {
+				continue
+			}
+
+			subnet, ok := subnets[iface.Network]
+			if !ok {
+				return nil, nil, fmt.Errorf("machine %s: unknown network %s", machine.Name, iface.Network)
+			}
+
+			if iface.Address == "" {
+				continue
+			}
+
+			addr, err := parseInterfaceAddress(iface.Address)
+			if err != nil {
+				return nil, nil, fmt.Errorf("machine %s: %w", machine.Name, err)
+			}
+
+			if !subnet.Contains(addr) || addr == subnet.Addr() || addr == lastAddr(subnet) {
+				return nil, nil, fmt.Errorf("machine %s: address %s is not a host address of network %s (%s)", machine.Name, addr, iface.Network, subnet)
+			}
+
+			if slices.Contains(used[iface.Network], addr) {
+				return nil, nil, fmt.Errorf("machine %s: address %s is already in use in network %s", machine.Name, addr, iface.Network)
+			}
+
+			used[iface.Network] = append(used[iface.Network], addr)
+		}
+
+		machines[i].Network = &api.MachineNetwork{Interfaces: interfaces}
+	}
+
+	for _, machine := range machines {
+		if machine.Network == nil {
+			continue
+		}
+
+		for j, iface := range machine.Network.Interfaces {
+			if iface.Network == "" || iface.Address != "" {
+				continue
+			}
+
+			subnet := subnets[iface.Network]
+
+			addr, err := allocateAddress(subnet, used[iface.Network])
+			if err != nil {
+				return nil, nil, fmt.Errorf("machine %s: network %s: %w", machine.Name, iface.Network, err)
+			}
+
+			machine.Network.Interfaces[j].Address = fmt.Sprintf("%s/%d", addr, subnet.Bits())
+			used[iface.Network] = append(used[iface.Network], addr)
+		}
+	}
+
+	return networks, machines, nil
+}
+
+// AllocatesNetworks reports whether [AllocateNetworks] allocates anything:
+// a network without a subnet or a machine interface without an address.
+func AllocatesNetworks(networks []api.PlaygroundNetwork, machines PlaygroundMachines) bool {
+	if slices.ContainsFunc(networks, func(network api.PlaygroundNetwork) bool { return network.Subnet == "" }) {
+		return true
+	}
+
+	return slices.ContainsFunc(machines, func(machine PlaygroundMachine) bool {
+		return machine.Network != nil && slices.ContainsFunc(machine.Network.Interfaces, func(iface api.MachineNetworkInterface) bool {
+			return iface.Network != "" && iface.Address == ""
+		})
+	})
+}
+
+// MachineAddresses returns the addresses of machines by machine and network name.
+func MachineAddresses(machines []api.PlaygroundMachine) map[string]map[string]string {
+	addresses := map[string]map[string]string{}
+
+	for _, machine := range machines {
+		if machine.Network == nil {
+			continue
+		}
+
+		for _, iface := range machine.Network.Interfaces {
+			if iface.Network == "" || iface.Address == "" {
+				continue
+			}
+
+			addr, err := parseInterfaceAddress(iface.Address)
+			if err != nil {
+				continue
+			}
+
+			if addresses[machine.Name] == nil {
+				addresses[machine.Name] = map[string]string{}
+			}
+
+			addresses[machine.Name][iface.Network] = addr.String()
+		}
+	}
+
+	return addresses
+}
+
+// NetworkEnvFile is the file exposing machine addresses to scripts (see [NetworkEnvFiles]).
+const NetworkEnvFile = "/etc/labx/network.env"
+
+// NetworkEnvFiles returns startup files exposing machine addresses to scripts
+// as LABX_<MACHINE>_<NETWORK>_IP variables (none if there are no addresses):
+// [NetworkEnvFile] and a script exporting its variables in login shells.
+func NetworkEnvFiles(addresses map[string]map[string]string) MachineStartupFiles {
+	var lines []string
+
+	for machine, networks := range addresses {
+		for network, addr := range networks {
+			lines = append(lines, fmt.Sprintf("LABX_%s_%s_IP=%s\n", envName(machine), envName(network), addr))
+		}
+	}
+
+	if len(lines) == 0 {
+		return nil
+	}
+
+	slices.Sort(lines)
+
+	return MachineStartupFiles{
+		{
+			Path:    NetworkEnvFile,
+			Content: strings.Join(lines, ""),
+			Mode:    "644",
+			Owner:   "root:root",
+		},
+		{
+			Path:    "/etc/profile.d/labx-network.sh",
+			Content: fmt.Sprintf("set -a\n. %s\nset +a\n", NetworkEnvFile),
+			Mode:    "644",
+			Owner:   "root:root",
+		},
+	}
+}
+
+// Address returns the address of the machine in a network (without the prefix length).
+func (m PlaygroundMachine) Address(network string) string {
+	return MachineAddresses([]api.PlaygroundMachine{{Name: m.Name, Network: m.Network}})[m.Name][network]
+}
+
+func parseSubnet(network api.PlaygroundNetwork) (netip.Prefix, error) {
+	subnet, err := netip.ParsePrefix(network.Subnet)
+	if err != nil {
+		return netip.Prefix{}, fmt.Errorf("network %s: invalid subnet %q: %w", network.Name, network.Subnet, err)
+	}
+
+	if subnet != subnet.Masked() {
+		return netip.Prefix{}, fmt.Errorf("network %s: subnet %s is not a network address (did you mean %s?)", network.Name, subnet, subnet.Masked())
+	}
+
+	return subnet, nil
+}
+
+// parseInterfaceAddress parses an address with or without a prefix length.
+func parseInterfaceAddress(address string) (netip.Addr, error) {
+	if strings.Contains(address, "/") {
+		prefix, err := netip.ParsePrefix(address)
+		if err != nil {
+			return netip.Addr{}, fmt.Errorf("invalid address %q: %w", address, err)
+		}
+
+		return prefix.Addr(), nil
+	}
+
+	addr, err := netip.ParseAddr(address)
+	if err != nil {
+		return netip.Addr{}, fmt.Errorf("invalid address %q: %w", address, err)
+	}
+
+	return addr, nil
+}
+
+func allocateSubnet(pool string, subnets map[string]netip.Prefix) (netip.Prefix, error) {
+	poolPrefix, err := netip.ParsePrefix(pool)
+	if err != nil {
+		return netip.Prefix{}, fmt.Errorf("invalid network pool %q: %w", pool, err)
+	}
+
+	poolPrefix = poolPrefix.Masked()
+
+	if !poolPrefix.Addr().Is4() || poolPrefix.Bits() > allocatedSubnetBits {
+		return netip.Prefix{}, fmt.Errorf("network pool %s must be an IPv4 prefix of at most /%d", poolPrefix, allocatedSubnetBits)
+	}
+
+	for addr := poolPrefix.Addr(); poolPrefix.Contains(addr); {
+		candidate := netip.PrefixFrom(addr, allocatedSubnetBits)
+
+		if !overlapsAny(candidate, subnets) {
+			return candidate, nil
+		}
+
+		next := lastAddr(candidate).Next()
+		if !next.IsValid() {
+			break
+		}
+
+		addr = next
+	}
+
+	return netip.Prefix{}, fmt.Errorf("network pool %s is exhausted", poolPrefix)
+}
+
+func overlapsAny(prefix netip.Prefix, subnets map[string]netip.Prefix) bool {
+	for _, subnet := range subnets {
+		if prefix.Overlaps(subnet) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// allocateAddress returns the first free host address of a subnet (the first host address is reserved for the gateway).
+func allocateAddress(subnet netip.Prefix, used []netip.Addr) (netip.Addr, error) {
+	last := lastAddr(subnet)
+
+	for addr := subnet.Addr().Next().Next(); addr.IsValid() && addr.Less(last); addr = addr.Next() {
+		if !slices.Contains(used, addr) {
+			return addr, nil
+		}
+	}
+
+	return netip.Addr{}, fmt.Errorf("no free addresses left in subnet %s", subnet)
+}
+
+// lastAddr returns the last (broadcast) address of a prefix.
+func lastAddr(prefix netip.Prefix) netip.Addr {
+	bytes := prefix.Masked().Addr().AsSlice()
+
+	for i := prefix.Bits(); i < len(bytes)*8; i++ {
+		bytes[i/8] |= 1 << (7 - i%8)
+	}
+
+	addr, _ := netip.AddrFromSlice(bytes)
+
+	return addr
+}
+
+func envName(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' {
+			return r - 'a' + 'A'
+		}
+
+		if (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			return r
+		}
+
+		return '_'
+	}, s)
+}
@@ -0,0 +1,163 @@
+package extended_test
+
+import (
+	"testing"
+
+	"github.com/iximiuz/labctl/api"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sagikazarmark/labx/extended"
+)
+
+func TestAllocateNetworks(t *testing.T) {
+	base := api.PlaygroundSpec{
+		Networks: []api.PlaygroundNetwork{
+			{Name: "base", Subnet: "10.100.0.0/24"},
+		},
+		Machines: []api.PlaygroundMachine{
+			{
+				Name: "registry",
+				Network: &api.MachineNetwork{
+					Interfaces: []api.MachineNetworkInterface{{Network: "base", Address: "10.100.0.2/24"}},
+				},
+			},
+		},
+	}
+
+	networks := []api.PlaygroundNetwork{
+		{Name: "local"},
+		{Name: "static", Subnet: "172.16.0.0/24", Gateway: "172.16.0.1"},
+	}
+
+	machines := extended.PlaygroundMachines{
+		{
+			Name: "node-01",
+			Network: &api.MachineNetwork{
+				Interfaces: []api.MachineNetworkInterface{
+					{Network: "local"},
+					{Network: "base"},
+					{Network: "static", Address: "172.16.0.2/24"},
+				},
+			},
+		},
+		{
+			Name: "node-02",
+			Network: &api.MachineNetwork{
+				Interfaces: []api.MachineNetworkInterface{
+					{Network: "local"},
+					{Network: "static"},
+				},
+			},
+		},
+		{Name: "no-network"},
+	}
+
+	networks, machines, err := extended.AllocateNetworks(extended.DefaultNetworkPool, networks, machines, base)
+	require.NoError(t, err)
+
+	assert.Equal(t, []api.PlaygroundNetwork{
+		{Name: "local", Subnet: "10.100.1.0/24"},
+		{Name: "static", Subnet: "172.16.0.0/24", Gateway: "172.16.0.1"},
+	}, networks)
+
+	assert.Equal(t, []api.MachineNetworkInterface{
+		{Network: "local", Address: "10.100.1.2/24"},
+		{Network: "base", Address: "10.100.0.3/24"},
+		{Network: "static", Address: "172.16.0.2/24"},
+	}, machines[0].Network.Interfaces)
+
+	assert.Equal(t, []api.MachineNetworkInterface{
+		{Network: "local", Address: "10.100.1.3/24"},
+		{Network: "static", Address: "172.16.0.3/24"},
+	}, machines[1].Network.Interfaces)
+
+	assert.Nil(t, machines[2].Network)
+
+	assert.Equal(t, "10.100.1.2", machines[0].Address("local"))
+
+	addresses := extended.MachineAddresses(extended.InheritMachines(base.Machines, machines.Convert(), nil))
+	assert.Equal(t, map[string]map[string]string{
+		"registry": {"base": "10.100.0.2"},
+		"node-01":  {"local": "10.100.1.2", "base": "10.100.0.3", "static": "172.16.0.2"},
+		"node-02":  {"local": "10.100.1.3", "static": "172.16.0.3"},
+	}, addresses)
+
+	envFiles := extended.NetworkEnvFiles(addresses)
+	require.Len(t, envFiles, 2)
+	assert.Equal(t, "/etc/labx/network.env", envFiles[0].Path)
+	assert.Contains(t, envFiles[0].Content, "LABX_NODE_01_LOCAL_IP=10.100.1.2\n")
+	assert.Contains(t, envFiles[0].Content, "LABX_REGISTRY_BASE_IP=10.100.0.2\n")
+	assert.Equal(t, "/etc/profile.d/labx-network.sh", envFiles[1].Path)
+	assert.Equal(t, "set -a\n. /etc/labx/network.env\nset +a\n", envFiles[1].Content)
+
+	assert.True(t, extended.AllocatesNetworks(nil, extended.PlaygroundMachines{
+		{Name: "node", Network: &api.MachineNetwork{Interfaces: []api.MachineNetworkInterface{{Network: "local"}}}},
+	}))
+	assert.False(t, extended.AllocatesNetworks([]api.PlaygroundNetwork{{Name: "local", Subnet: "10.0.0.0/24"}}, extended.PlaygroundMachines{
+		{Name: "node", Network: &api.MachineNetwork{Interfaces: []api.MachineNetworkInterface{{Network: "local", Address: "10.0.0.2/24"}}}},
+	}))
+}
+
+func TestAllocateNetworks_Errors(t *testing.T) {
+	machine := func(iface api.MachineNetworkInterface) extended.PlaygroundMachine {
+		return extended.PlaygroundMachine{
+			Name:    "node",
+			Network: &api.MachineNetwork{Interfaces: []api.MachineNetworkInterface{iface}},
+		}
+	}
+
+	tests := []struct {
+		name     string
+		networks []api.PlaygroundNetwork
+		machines extended.PlaygroundMachines
+		err      string
+	}{
+		{
+			name: "overlapping subnets",
+			networks: []api.PlaygroundNetwork{
+				{Name: "a", Subnet: "172.16.0.0/16"},
+				{Name: "b", Subnet: "172.16.1.0/24"},
+			},
+			err: "overlaps with network a",
+		},
+		{
+			name:     "not a network address",
+			networks: []api.PlaygroundNetwork{{Name: "a", Subnet: "172.16.0.1/24"}},
+			err:      "did you mean 172.16.0.0/24?",
+		},
+		{
+			name:     "unknown network",
+			machines: extended.PlaygroundMachines{machine(api.MachineNetworkInterface{Network: "missing"})},
+			err:      "machine node: unknown network missing",
+		},
+		{
+			name:     "address outside of subnet",
+			networks: []api.PlaygroundNetwork{{Name: "a", Subnet: "172.16.0.0/24"}},
+			machines: extended.PlaygroundMachines{machine(api.MachineNetworkInterface{Network: "a", Address: "172.16.1.2/24"})},
+			err:      "address 172.16.1.2 is not a host address of network a",
+		},
+		{
+			name:     "gateway address",
+			networks: []api.PlaygroundNetwork{{Name: "a", Subnet: "172.16.0.0/24", Gateway: "172.16.0.1"}},
+			machines: extended.PlaygroundMachines{machine(api.MachineNetworkInterface{Network: "a", Address: "172.16.0.1"})},
+			err:      "address 172.16.0.1 is already in use in network a",
+		},
+		{
+			name:     "exhausted subnet",
+			networks: []api.PlaygroundNetwork{{Name: "a", Subnet: "172.16.0.0/30"}},
+			machines: extended.PlaygroundMachines{
+				machine(api.MachineNetworkInterface{Network: "a"}),
+				machine(api.MachineNetworkInterface{Network: "a"}),
+			},
+			err: "no free addresses left in subnet 172.16.0.0/30",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, _, err := extended.AllocateNetworks(extended.DefaultNetworkPool, test.networks, test.machines, api.PlaygroundSpec{})
+			require.ErrorContains(t, err, test.err)
+		})
+	}
+}
@@ -2,7 +2,9 @@ package extended
 
 import (
 	"fmt"
+	"maps"
 	"slices"
+	"strings"
 
 	"github.com/iximiuz/labctl/api"
 	"github.com/samber/lo"
@@ -40,41 +42,82 @@ type PlaygroundSpec struct {
 	Networks       []api.PlaygroundNetwork `yaml:"networks"               json:"networks"`
 	Machines       PlaygroundMachines      `yaml:"machines"               json:"machines"`
 	Tabs           []api.PlaygroundTab     `yaml:"tabs"                   json:"tabs"`
+	AutoTabs       AutoTabs                `yaml:"autoTabs,omitzero"      json:"autoTabs,omitzero"`
 	InitTasks      InitTasks               `yaml:"initTasks"              json:"initTasks"`
 	InitConditions api.InitConditions      `yaml:"initConditions"         json:"initConditions"`
 	RegistryAuth   string                  `yaml:"registryAuth,omitempty" json:"registryAuth,omitempty"`
 
+	// NetworkPool is the address pool subnets are allocated from (defaults to [DefaultNetworkPool]).
+	NetworkPool string `yaml:"networkPool,omitempty" json:"networkPool,omitempty"`
+
+	// Domain is the default domain of machine hostnames (defaults to [DefaultDomain]).
+	Domain string `yaml:"domain,omitempty" json:"domain,omitempty"`
+
+	// ClusterHosts adds every machine to the /etc/hosts file of every other machine (see [ClusterHosts]).
+	ClusterHosts bool `yaml:"clusterHosts,omitempty" json:"clusterHosts,omitempty"`
+
 	AccessControl api.PlaygroundAccessControl `yaml:"accessControl" json:"accessControl"`
 
 	BaseName string `yaml:"-" json:"-"`
+
+	// Base is the spec of the base playground (see [InheritPlaygroundSpec]).
+	Base api.PlaygroundSpec `yaml:"-" json:"-"`
 }
 
 func (s PlaygroundSpec) Convert() api.PlaygroundSpec {
-	return api.PlaygroundSpec{
+	spec := api.PlaygroundSpec{
 		Networks:       s.Networks,
-		Machines:       s.convertMachines(),
+		Machines:       s.Machines.Convert(),
 		Tabs:           s.Tabs,
-		InitTasks:      s.InitTasks.Convert(),
+		InitTasks:      s.initTasks().Convert(),
 		InitConditions: s.InitConditions,
 		RegistryAuth:   s.RegistryAuth,
 		AccessControl:  s.AccessControl,
 	}
+
+	spec = InheritPlaygroundSpec(s.Base, spec, s.Machines.Merges())
+	spec.Machines = s.applyWelcome(spec.Machines)
+
+	return spec
+}
+
+// initTasks returns the init tasks of the playground and the ones generated for machines.
+func (s PlaygroundSpec) initTasks() InitTasks {
+	initTasks := maps.Clone(s.InitTasks)
+
+	for _, machine := range s.Machines {
+		for name, initTask := range machine.InitTasks {
+			if initTasks == nil {
+				initTasks = InitTasks{}
+			}
+
+			if _, ok := initTasks[name]; !ok {
+				initTasks[name] = initTask
+			}
+		}
+	}
+
+	return initTasks
 }
 
-func (s PlaygroundSpec) convertMachines() []api.PlaygroundMachine {
+func (s PlaygroundSpec) applyWelcome(machines []api.PlaygroundMachine) []api.PlaygroundMachine {
 	if s.BaseName == "flexbox" {
-		return s.Machines.Convert()
+		return machines
 	}
 
 	// Apply welcome message to default users if specified
-	machines := s.Machines.Convert()
 	if s.Welcome != "" {
 		for i, machine := range machines {
-			for j, user := range machine.Users {
+			// Users may be shared with the base playground
+			users := slices.Clone(machine.Users)
+
+			for j, user := range users {
 				if user.Default && (user.Welcome == "" || user.Welcome == "-") {
-					machines[i].Users[j].Welcome = s.Welcome
+					users[j].Welcome = s.Welcome
 				}
 			}
+
+			machines[i].Users = users
 		}
 	}
 
@@ -89,9 +132,19 @@ func (m PlaygroundMachines) Convert() []api.PlaygroundMachine {
 	})
 }
 
+// Merges returns how the machines are merged with the base machines by machine name.
+func (m PlaygroundMachines) Merges() map[string]MachineMerge {
+	return lo.SliceToMap(m, func(machine PlaygroundMachine) (string, MachineMerge) {
+		return machine.Name, machine.merge()
+	})
+}
+
 type PlaygroundMachine struct {
 	Name         string                `yaml:"name"               json:"name"`
+	Preset       string                `yaml:"preset,omitempty"   json:"preset,omitempty"`
 	Hostname     string                `yaml:"hostname,omitempty" json:"hostname,omitempty"`
+	Aliases      []string              `yaml:"aliases,omitempty"  json:"aliases,omitempty"`
+	Domain       string                `yaml:"domain,omitempty"   json:"domain,omitempty"`
 	IDEPath      string                `yaml:"idePath,omitempty"  json:"idePath,omitempty"`
 	Users        MachineUsers          `yaml:"users"              json:"users"`
 	Kernel       *api.MachineKernel    `yaml:"kernel,omitempty"   json:"kernel,omitempty"`
@@ -99,7 +152,32 @@ type PlaygroundMachine struct {
 	Network      *api.MachineNetwork   `yaml:"network,omitzero"   json:"network,omitzero"`
 	Resources    *api.MachineResources `yaml:"resources,omitzero" json:"resources,omitzero"`
 	StartupFiles MachineStartupFiles   `yaml:"startupFiles"       json:"startupFiles"`
-	NoSSH        bool                  `yaml:"noSSH,omitzero"     json:"noSSH,omitzero"`
+	NoSSH        *bool                 `yaml:"noSSH,omitempty"    json:"noSSH,omitempty"`
+
+	// ResourcePreset expands into resources (see [PlaygroundMachines.ApplyResourcePresets]).
+	ResourcePreset string `yaml:"resourcePreset,omitempty" json:"resourcePreset,omitempty"`
+
+	// Replicas expands the machine into N identical machines (see [PlaygroundMachines.Expand]).
+	Replicas    int    `yaml:"replicas,omitempty"    json:"replicas,omitempty"`
+	NamePattern string `yaml:"namePattern,omitempty" json:"namePattern,omitempty"`
+
+	// ReplicaIndex is the index of a replica (starting from 1, 0 if the machine is not a replica).
+	ReplicaIndex int `yaml:"-" json:"-"`
+
+	// Merge configures how fields are merged with the base playground machine (or preset) of the same name.
+	Merge MachineMergeStrategies `yaml:"merge,omitzero" json:"merge,omitzero"`
+
+	// InitTasks are generated for the machine (e.g. to decode binary startup files).
+	InitTasks InitTasks `yaml:"-" json:"-"`
+}
+
+// merge returns the merge strategies of the machine along with the values
+// that can't be told apart from undefined ones after conversion.
+func (m PlaygroundMachine) merge() MachineMerge {
+	return MachineMerge{
+		Strategies: m.Merge,
+		NoSSH:      m.NoSSH,
+	}
 }
 
 func (m PlaygroundMachine) Convert() api.PlaygroundMachine {
@@ -113,13 +191,17 @@ func (m PlaygroundMachine) Convert() api.PlaygroundMachine {
 			Owner:   "root:root",
 		}
 
+		playgroundStartupFiles = append(playgroundStartupFiles, hostname)
+	}
+
+	if hostNames := m.HostNames(); len(hostNames) > 0 {
 		hosts := api.MachineStartupFile{
 			Path:    "/etc/hosts",
-			Content: fmt.Sprintf("127.0.0.1       %s %s.local\n", m.Hostname, m.Hostname),
+			Content: fmt.Sprintf("127.0.0.1       %s\n", strings.Join(hostNames, " ")),
 			Append:  true,
 		}
 
-		playgroundStartupFiles = append(playgroundStartupFiles, hostname, hosts)
+		playgroundStartupFiles = append(playgroundStartupFiles, hosts)
 	}
 
 	if m.IDEPath != "" {
@@ -141,7 +223,7 @@ func (m PlaygroundMachine) Convert() api.PlaygroundMachine {
 		Network:      m.Network,
 		Resources:    m.Resources,
 		StartupFiles: append(playgroundStartupFiles, m.StartupFiles.Convert()...),
-		NoSSH:        m.NoSSH,
+		NoSSH:        lo.FromPtr(m.NoSSH),
 	}
 }
 
@@ -158,6 +240,22 @@ type MachineUser struct {
 	Default     bool   `yaml:"default,omitempty"     json:"default,omitempty"`
 	Welcome     string `yaml:"welcome,omitempty"     json:"welcome,omitempty"`
 	WelcomeFile string `yaml:"welcomeFile,omitempty" json:"welcomeFile,omitempty"`
+
+	// Template renders the welcome message as a template.
+	Template bool `yaml:"template,omitempty" json:"template,omitempty"`
+
+	// Groups the user is added to (created if missing).
+	Groups []string `yaml:"groups,omitempty" json:"groups,omitempty"`
+
+	// Sudo grants passwordless sudo.
+	Sudo bool `yaml:"sudo,omitempty" json:"sudo,omitempty"`
+
+	// AuthorizedKeys are appended to ~/.ssh/authorized_keys.
+	AuthorizedKeys     string `yaml:"authorizedKeys,omitempty"     json:"authorizedKeys,omitempty"`
+	AuthorizedKeysFile string `yaml:"authorizedKeysFile,omitempty" json:"authorizedKeysFile,omitempty"`
+
+	// Shell is the login shell of the user.
+	Shell string `yaml:"shell,omitempty" json:"shell,omitempty"`
 }
 
 func (u MachineUser) Convert() api.MachineUser {
@@ -183,6 +281,19 @@ type MachineStartupFile struct {
 	Mode     string `yaml:"mode,omitempty"     json:"mode,omitempty"`
 	Owner    string `yaml:"owner,omitempty"    json:"owner,omitempty"`
 	Append   bool   `yaml:"append,omitempty"   json:"append,omitempty"`
+
+	// Template renders the content as a template.
+	Template bool `yaml:"template,omitempty" json:"template,omitempty"`
+
+	// Encoding of the content: base64 content is decoded on the machine by a generated init task.
+	// Files that are not valid UTF-8 are base64 encoded automatically.
+	Encoding string `yaml:"encoding,omitempty" json:"encoding,omitempty"`
+
+	// FromDir expands a local directory into startup files under Path.
+	// Include and Exclude filter files by glob (matched against the relative path and the file name).
+	FromDir string   `yaml:"fromDir,omitempty" json:"fromDir,omitempty"`
+	Include []string `yaml:"include,omitempty" json:"include,omitempty"`
+	Exclude []string `yaml:"exclude,omitempty" json:"exclude,omitempty"`
 }
 
 func (f MachineStartupFile) Convert() api.MachineStartupFile {
@@ -247,6 +358,11 @@ func (t InitTasks) Convert() map[string]api.InitTask {
 					panic("unknown dependency:" + need)
 				}
 
+				// Startup files are decoded before other init tasks run on the machine
+				if initTask.Init && !initTask.decode {
+					newInitTask.Needs = append(newInitTask.Needs, decodeTaskNames(t, machine)...)
+				}
+
 				initTasks[newInitTask.Name] = newInitTask
 			}
 		}
@@ -255,6 +371,23 @@ func (t InitTasks) Convert() map[string]api.InitTask {
 	return initTasks
 }
 
+// ExpandMachines expands machine patterns (e.g. node-*) of every init task.
+func (t InitTasks) ExpandMachines(machines []string) (InitTasks, error) {
+	initTasks := InitTasks{}
+
+	for name, initTask := range t {
+		machine, err := initTask.Machine.ExpandPatterns(machines)
+		if err != nil {
+			return nil, fmt.Errorf("init task %s: %w", name, err)
+		}
+
+		initTask.Machine = machine
+		initTasks[name] = initTask
+	}
+
+	return initTasks, nil
+}
+
 type InitTask struct {
 	Name           string              `yaml:"name"                 json:"name"`
 	Machine        StringList          `yaml:"machine,omitempty"    json:"machine,omitempty"`
@@ -264,6 +397,13 @@ type InitTask struct {
 	Needs          []string            `yaml:"needs,omitempty"      json:"needs,omitempty"`
 	Run            string              `yaml:"run"                  json:"run"`
 	Conditions     []api.InitCondition `yaml:"conditions,omitempty" json:"conditions,omitempty"`
+
+	// decode marks tasks generated by [PlaygroundMachine.DecodeStartupFiles].
+	decode bool
+}
+
+func (t InitTask) decodes(machine string) bool {
+	return t.decode && slices.Contains(t.Machine, machine)
 }
 
 func (t InitTask) Convert() api.InitTask {
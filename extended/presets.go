@@ -0,0 +1,128 @@
+package extended
+
+import (
+	"fmt"
+	"slices"
+)
+
+// MachineLibrary is a collection of reusable machine presets.
+type MachineLibrary struct {
+	Machines map[string]PlaygroundMachine `yaml:"machines" json:"machines"`
+}
+
+// ApplyPresets merges the referenced preset into every machine that has one.
+//
+// Presets may reference other presets.
+func (m PlaygroundMachines) ApplyPresets(presets map[string]PlaygroundMachine) (PlaygroundMachines, error) {
+	machines := make(PlaygroundMachines, 0, len(m))
+
+	for _, machine := range m {
+		applied, err := machine.applyPreset(presets, nil)
+		if err != nil {
+			return nil, fmt.Errorf("machine %s: %w", machine.Name, err)
+		}
+
+		machines = append(machines, applied)
+	}
+
+	return machines, nil
+}
+
+func (m PlaygroundMachine) applyPreset(
+	presets map[string]PlaygroundMachine,
+	seen []string,
+) (PlaygroundMachine, error) {
+	if m.Preset == "" {
+		return m, nil
+	}
+
+	if slices.Contains(seen, m.Preset) {
+		return PlaygroundMachine{}, fmt.Errorf("preset cycle: %v", append(seen, m.Preset))
+	}
+
+	preset, ok := presets[m.Preset]
+	if !ok {
+		return PlaygroundMachine{}, fmt.Errorf("unknown preset: %s", m.Preset)
+	}
+
+	preset, err := preset.applyPreset(presets, append(seen, m.Preset))
+	if err != nil {
+		return PlaygroundMachine{}, err
+	}
+
+	return m.WithPreset(preset), nil
+}
+
+// WithPreset returns the machine merged with a preset.
+//
+// Fields are merged using the merge strategies of the machine (see [DefaultMachineMergeStrategies]).
+func (m PlaygroundMachine) WithPreset(preset PlaygroundMachine) PlaygroundMachine {
+	s := m.Merge.withDefaults()
+
+	machine := m
+	machine.Preset = ""
+
+	machine.Users = singleDefaultUser(
+		mergeList(s.Users, preset.Users, m.Users, machineUserKey, mergeMachineUser),
+		m.Users,
+		machineUserKey,
+		func(user *MachineUser) *bool { return &user.Default },
+	)
+	machine.Resources = mergePointer(s.Resources, clonePtr(preset.Resources), m.Resources, mergeResources)
+	machine.Kernel = mergePointer(s.Kernel, cloneKernel(preset.Kernel), m.Kernel, mergeKernel)
+	machine.Drives = mergeList(s.Drives, preset.Drives, m.Drives, driveKey, mergeDrive)
+	machine.Network = mergeMachineNetwork(s.Network, cloneNetwork(preset.Network), m.Network)
+	machine.NoSSH = mergeBool(s.NoSSH, preset.NoSSH, m.NoSSH)
+	machine.StartupFiles = mergeList(
+		s.StartupFiles,
+		preset.StartupFiles,
+		m.StartupFiles,
+		func(file MachineStartupFile) string { return file.Path },
+		takeOverride,
+	)
+
+	if machine.Hostname == "" {
+		machine.Hostname = preset.Hostname
+	}
+
+	if len(machine.Aliases) == 0 {
+		machine.Aliases = slices.Clone(preset.Aliases)
+	}
+
+	if machine.Domain == "" {
+		machine.Domain = preset.Domain
+	}
+
+	if machine.IDEPath == "" {
+		machine.IDEPath = preset.IDEPath
+	}
+
+	if machine.ResourcePreset == "" && machine.Resources == nil {
+		machine.ResourcePreset = preset.ResourcePreset
+	}
+
+	if machine.Replicas == 0 {
+		machine.Replicas = preset.Replicas
+	}
+
+	if machine.NamePattern == "" {
+		machine.NamePattern = preset.NamePattern
+	}
+
+	return machine
+}
+
+func machineUserKey(user MachineUser) string {
+	return user.Name
+}
+
+func mergeMachineUser(base MachineUser, override MachineUser) MachineUser {
+	base.Default = base.Default || override.Default
+
+	if override.Welcome != "" || override.WelcomeFile != "" {
+		base.Welcome = override.Welcome
+		base.WelcomeFile = override.WelcomeFile
+	}
+
+	return base
+}
@@ -0,0 +1,97 @@
+package extended_test
+
+import (
+	"testing"
+
+	"github.com/iximiuz/labctl/api"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sagikazarmark/labx/extended"
+)
+
+func TestPlaygroundMachines_ApplyPresets(t *testing.T) {
+	presets := map[string]extended.PlaygroundMachine{
+		"base": {
+			Users: extended.MachineUsers{{Name: "root", Default: true}},
+			Resources: &api.MachineResources{
+				CPUCount: 2,
+				RAMSize:  "4Gi",
+			},
+			StartupFiles: extended.MachineStartupFiles{{Path: "/etc/base"}},
+		},
+		"k8s-node": {
+			Preset:       "base",
+			Kernel:       &api.MachineKernel{Source: "oci://example.com/kernel"},
+			StartupFiles: extended.MachineStartupFiles{{Path: "/etc/k8s"}},
+		},
+	}
+
+	machines := extended.PlaygroundMachines{
+		{
+			Name:         "node-01",
+			Preset:       "k8s-node",
+			Users:        extended.MachineUsers{{Name: "laborant", Default: true}},
+			StartupFiles: extended.MachineStartupFiles{{Path: "/etc/node"}},
+		},
+		{
+			Name: "dev",
+		},
+	}
+
+	expected := extended.PlaygroundMachines{
+		{
+			Name:   "node-01",
+			Users:  extended.MachineUsers{{Name: "laborant", Default: true}},
+			Kernel: &api.MachineKernel{Source: "oci://example.com/kernel"},
+			Resources: &api.MachineResources{
+				CPUCount: 2,
+				RAMSize:  "4Gi",
+			},
+			StartupFiles: extended.MachineStartupFiles{
+				{Path: "/etc/base"},
+				{Path: "/etc/k8s"},
+				{Path: "/etc/node"},
+			},
+		},
+		{
+			Name: "dev",
+		},
+	}
+
+	actual, err := machines.ApplyPresets(presets)
+	require.NoError(t, err)
+
+	assert.Equal(t, expected, actual)
+}
+
+func TestPlaygroundMachine_WithPreset_NoAliasing(t *testing.T) {
+	preset := extended.PlaygroundMachine{
+		Aliases:   []string{"node"},
+		Resources: &api.MachineResources{CPUCount: 2},
+		Kernel:    &api.MachineKernel{Source: "oci://kernel", Snapshot: &api.RemoteSnapshot{}},
+		Network: &api.MachineNetwork{
+			Interfaces: []api.MachineNetworkInterface{{Network: "local"}},
+		},
+	}
+
+	machine := extended.PlaygroundMachine{Name: "node-01"}.WithPreset(preset)
+
+	machine.Aliases[0] = "changed"
+	machine.Resources.CPUCount = 4
+	machine.Kernel.Source = "oci://changed"
+	machine.Network.Interfaces[0].Address = "10.0.0.2/24"
+
+	assert.Equal(t, "node", preset.Aliases[0])
+	assert.Equal(t, 2, preset.Resources.CPUCount)
+	assert.Equal(t, "oci://kernel", preset.Kernel.Source)
+	assert.NotSame(t, preset.Kernel.Snapshot, machine.Kernel.Snapshot)
+	assert.Empty(t, preset.Network.Interfaces[0].Address)
+}
+
+func TestPlaygroundMachines_ApplyPresets_Unknown(t *testing.T) {
+	machines := extended.PlaygroundMachines{{Name: "node", Preset: "missing"}}
+
+	_, err := machines.ApplyPresets(nil)
+	require.Error(t, err)
+}
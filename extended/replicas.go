@@ -0,0 +1,222 @@
+package extended
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+	"text/template"
+
+	"github.com/iximiuz/labctl/api"
+)
+
+// ReplicaData is available to hostname, alias and startup file path templates of replicated machines.
+type ReplicaData struct {
+	// Index of the replica (starting from 1).
+	Index int
+
+	// Name of the replica.
+	Name string
+}
+
+// Expand replaces every machine with replicas set by its replicas.
+//
+// Replica names are generated from namePattern (defaults to "<name>-%02d") using a 1-based index.
+// Hostname, aliases and startup file paths (path and fromFile) are rendered as templates with [ReplicaData].
+// Startup file content is only rendered if it's a template (see [PlaygroundMachine.ReplicaIndex]).
+func (m PlaygroundMachines) Expand() (PlaygroundMachines, error) {
+	var machines PlaygroundMachines
+
+	// Generated names must not clash with other machines
+	names := map[string]bool{}
+
+	for _, machine := range m {
+		if machine.Replicas <= 0 {
+			names[machine.Name] = true
+		}
+	}
+
+	for _, machine := range m {
+		if machine.Replicas <= 0 {
+			machines = append(machines, machine)
+
+			continue
+		}
+
+		namePattern := machine.NamePattern
+		if namePattern == "" {
+			namePattern = machine.Name + "-%02d"
+		}
+
+		err := validateNamePattern(namePattern)
+		if err != nil {
+			return nil, fmt.Errorf("expanding machine %s: %w", machine.Name, err)
+		}
+
+		for i := 1; i <= machine.Replicas; i++ {
+			name := fmt.Sprintf(namePattern, i)
+			if names[name] {
+				return nil, fmt.Errorf("expanding machine %s: duplicate machine name %q", machine.Name, name)
+			}
+
+			names[name] = true
+
+			replica, err := machine.replica(ReplicaData{
+				Index: i,
+				Name:  name,
+			})
+			if err != nil {
+				return nil, fmt.Errorf("expanding machine %s: %w", machine.Name, err)
+			}
+
+			machines = append(machines, replica)
+		}
+	}
+
+	return machines, nil
+}
+
+// validateNamePattern makes sure a name pattern contains a single integer verb (e.g. node-%02d).
+func validateNamePattern(namePattern string) error {
+	verbs := strings.ReplaceAll(namePattern, "%%", "")
+
+	if strings.Count(verbs, "%") != 1 || !namePatternVerb.MatchString(verbs) {
+		return fmt.Errorf("invalid name pattern %q: it must contain a single integer verb (e.g. %%02d)", namePattern)
+	}
+
+	return nil
+}
+
+var namePatternVerb = regexp.MustCompile(`%[-+# 0]*[0-9]*[dxXob]`)
+
+func (m PlaygroundMachine) replica(data ReplicaData) (PlaygroundMachine, error) {
+	replica := m
+	replica.Name = data.Name
+	replica.Replicas = 0
+	replica.NamePattern = ""
+	replica.ReplicaIndex = data.Index
+
+	// Processors modify these in place
+	replica.Users = slices.Clone(m.Users)
+	replica.Drives = slices.Clone(m.Drives)
+	replica.StartupFiles = slices.Clone(m.StartupFiles)
+
+	// Replicas must not share settings (e.g. when one is changed by a processor or a merge)
+	replica.Kernel = cloneKernel(m.Kernel)
+	replica.Network = cloneNetwork(m.Network)
+	replica.Resources = clonePtr(m.Resources)
+
+	var err error
+
+	replica.Hostname, err = renderReplicaTemplate(m.Hostname, data)
+	if err != nil {
+		return PlaygroundMachine{}, fmt.Errorf("hostname: %w", err)
+	}
+
+	replica.Aliases = slices.Clone(m.Aliases)
+
+	for i, alias := range replica.Aliases {
+		replica.Aliases[i], err = renderReplicaTemplate(alias, data)
+		if err != nil {
+			return PlaygroundMachine{}, fmt.Errorf("alias %d: %w", i, err)
+		}
+	}
+
+	for i, startupFile := range replica.StartupFiles {
+		for _, field := range []*string{&startupFile.Path, &startupFile.FromFile} {
+			*field, err = renderReplicaTemplate(*field, data)
+			if err != nil {
+				return PlaygroundMachine{}, fmt.Errorf("startup file %d: %w", i, err)
+			}
+		}
+
+		replica.StartupFiles[i] = startupFile
+	}
+
+	return replica, nil
+}
+
+func renderReplicaTemplate(text string, data ReplicaData) (string, error) {
+	if !strings.Contains(text, "{{") {
+		return text, nil
+	}
+
+	tpl, err := template.New("").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+
+	err = tpl.Execute(&buf, data)
+	if err != nil {
+		return "", err
+	}
+
+	return buf.String(), nil
+}
+
+// ExpandPatterns replaces glob patterns (see [path.Match]) in the list with the matching names.
+//
+// Entries without glob characters are kept as is.
+func (s StringList) ExpandPatterns(names []string) (StringList, error) {
+	var result StringList
+
+	for _, entry := range s {
+		if !strings.ContainsAny(entry, "*?[") {
+			result = append(result, entry)
+
+			continue
+		}
+
+		var matched bool
+
+		for _, name := range names {
+			ok, err := path.Match(entry, name)
+			if err != nil {
+				return nil, fmt.Errorf("invalid pattern %q: %w", entry, err)
+			}
+
+			if ok && !slices.Contains(result, name) {
+				result = append(result, name)
+				matched = true
+			}
+		}
+
+		if !matched {
+			return nil, fmt.Errorf("pattern %q does not match any machine", entry)
+		}
+	}
+
+	return result, nil
+}
+
+func clonePtr[T any](v *T) *T {
+	if v == nil {
+		return nil
+	}
+
+	c := *v
+
+	return &c
+}
+
+func cloneKernel(kernel *api.MachineKernel) *api.MachineKernel {
+	kernel = clonePtr(kernel)
+	if kernel != nil {
+		kernel.Snapshot = clonePtr(kernel.Snapshot)
+	}
+
+	return kernel
+}
+
+func cloneNetwork(network *api.MachineNetwork) *api.MachineNetwork {
+	network = clonePtr(network)
+	if network != nil {
+		network.Interfaces = slices.Clone(network.Interfaces)
+	}
+
+	return network
+}
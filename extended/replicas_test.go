@@ -0,0 +1,190 @@
+package extended_test
+
+import (
+	"testing"
+
+	"github.com/iximiuz/labctl/api"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sagikazarmark/labx/extended"
+)
+
+func TestPlaygroundMachines_Expand(t *testing.T) {
+	machines := extended.PlaygroundMachines{
+		{
+			Name: "dev",
+		},
+		{
+			Name:        "node",
+			Hostname:    "worker-{{ .Index }}",
+			Replicas:    2,
+			NamePattern: "node-%02d",
+			Users:       extended.MachineUsers{{Name: "root"}},
+			StartupFiles: extended.MachineStartupFiles{
+				{
+					Path:     "/etc/node-{{ .Index }}",
+					Content:  "{{ .Name }}={{ .Index }}",
+					Template: true,
+				},
+				{
+					Path:    "/usr/local/bin/containers",
+					Content: "docker ps --format '{{ .Names }}'",
+				},
+			},
+		},
+	}
+
+	expected := extended.PlaygroundMachines{
+		{
+			Name: "dev",
+		},
+		{
+			Name:         "node-01",
+			Hostname:     "worker-1",
+			Users:        extended.MachineUsers{{Name: "root"}},
+			ReplicaIndex: 1,
+			StartupFiles: extended.MachineStartupFiles{
+				// Content is rendered later (see [PlaygroundMachine.ReplicaIndex])
+				{
+					Path:     "/etc/node-1",
+					Content:  "{{ .Name }}={{ .Index }}",
+					Template: true,
+				},
+				{
+					Path:    "/usr/local/bin/containers",
+					Content: "docker ps --format '{{ .Names }}'",
+				},
+			},
+		},
+		{
+			Name:         "node-02",
+			Hostname:     "worker-2",
+			Users:        extended.MachineUsers{{Name: "root"}},
+			ReplicaIndex: 2,
+			StartupFiles: extended.MachineStartupFiles{
+				// Content is rendered later (see [PlaygroundMachine.ReplicaIndex])
+				{
+					Path:     "/etc/node-2",
+					Content:  "{{ .Name }}={{ .Index }}",
+					Template: true,
+				},
+				{
+					Path:    "/usr/local/bin/containers",
+					Content: "docker ps --format '{{ .Names }}'",
+				},
+			},
+		},
+	}
+
+	actual, err := machines.Expand()
+	require.NoError(t, err)
+
+	assert.Equal(t, expected, actual)
+}
+
+func TestPlaygroundMachines_Expand_Invalid(t *testing.T) {
+	tests := []struct {
+		name     string
+		machines extended.PlaygroundMachines
+		err      string
+	}{
+		{
+			"no verb",
+			extended.PlaygroundMachines{{Name: "node", Replicas: 2, NamePattern: "node"}},
+			`invalid name pattern "node"`,
+		},
+		{
+			"string verb",
+			extended.PlaygroundMachines{{Name: "node", Replicas: 2, NamePattern: "node-%s"}},
+			`invalid name pattern "node-%s"`,
+		},
+		{
+			"two verbs",
+			extended.PlaygroundMachines{{Name: "node", Replicas: 2, NamePattern: "node-%d-%d"}},
+			`invalid name pattern "node-%d-%d"`,
+		},
+		{
+			"existing machine",
+			extended.PlaygroundMachines{{Name: "node-01"}, {Name: "node", Replicas: 2}},
+			`duplicate machine name "node-01"`,
+		},
+		{
+			"other replicas",
+			extended.PlaygroundMachines{{Name: "a", Replicas: 2, NamePattern: "node-%d"}, {Name: "b", Replicas: 2, NamePattern: "node-%d"}},
+			`duplicate machine name "node-1"`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := test.machines.Expand()
+			require.ErrorContains(t, err, test.err)
+		})
+	}
+
+	machines, err := extended.PlaygroundMachines{{Name: "node", Replicas: 1, NamePattern: "100%%-node-%d"}}.Expand()
+	require.NoError(t, err)
+	assert.Equal(t, "100%-node-1", machines[0].Name)
+}
+
+func TestPlaygroundMachines_Expand_Independent(t *testing.T) {
+	machines := extended.PlaygroundMachines{
+		{
+			Name:      "node",
+			Replicas:  3,
+			Kernel:    &api.MachineKernel{Source: "oci://ghcr.io/iximiuz/labs/kernel:6.1"},
+			Resources: &api.MachineResources{CPUCount: 2},
+			Network: &api.MachineNetwork{
+				Interfaces: []api.MachineNetworkInterface{{Network: "local"}},
+			},
+		},
+	}
+
+	replicas, err := machines.Expand()
+	require.NoError(t, err)
+	require.Len(t, replicas, 3)
+
+	replicas[0].Network.Interfaces[0].Address = "172.16.0.2"
+	replicas[0].Network.Interfaces = append(replicas[0].Network.Interfaces, api.MachineNetworkInterface{Network: "private"})
+	replicas[0].Kernel.Source = "oci://ghcr.io/iximiuz/labs/kernel:6.6"
+	replicas[0].Resources.CPUCount = 4
+
+	for _, replica := range append(replicas[1:], machines[0]) {
+		assert.Equal(t, []api.MachineNetworkInterface{{Network: "local"}}, replica.Network.Interfaces, replica.Name)
+		assert.Equal(t, "oci://ghcr.io/iximiuz/labs/kernel:6.1", replica.Kernel.Source, replica.Name)
+		assert.Equal(t, 2, replica.Resources.CPUCount, replica.Name)
+	}
+}
+
+func TestInitTasks_ExpandMachines(t *testing.T) {
+	initTasks := extended.InitTasks{
+		"init_nodes": {
+			Machine: extended.StringList{"node-*"},
+			User:    extended.StringList{"root"},
+		},
+		"init_dev": {
+			Machine: extended.StringList{"dev"},
+			User:    extended.StringList{"root"},
+		},
+	}
+
+	expected := extended.InitTasks{
+		"init_nodes": {
+			Machine: extended.StringList{"node-01", "node-02"},
+			User:    extended.StringList{"root"},
+		},
+		"init_dev": {
+			Machine: extended.StringList{"dev"},
+			User:    extended.StringList{"root"},
+		},
+	}
+
+	actual, err := initTasks.ExpandMachines([]string{"dev", "node-01", "node-02"})
+	require.NoError(t, err)
+
+	assert.Equal(t, expected, actual)
+
+	_, err = initTasks.ExpandMachines([]string{"dev"})
+	require.Error(t, err)
+}
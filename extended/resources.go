@@ -0,0 +1,375 @@
+package extended
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/iximiuz/labctl/api"
+)
+
+// ResourceLibrary holds platform limits and named resource presets.
+type ResourceLibrary struct {
+	Limits  PlatformLimits                  `yaml:"limits"          json:"limits"`
+	Presets map[string]api.MachineResources `yaml:"resourcePresets" json:"resourcePresets"`
+}
+
+// DefaultResourceLibrary returns the built-in resource presets.
+//
+// Limits are not checked unless they are set (e.g. in a limits.yaml file).
+func DefaultResourceLibrary() ResourceLibrary {
+	return ResourceLibrary{
+		Presets: map[string]api.MachineResources{
+			"small":  {CPUCount: 1, RAMSize: "2GiB"},
+			"medium": {CPUCount: 2, RAMSize: "4GiB"},
+			"large":  {CPUCount: 4, RAMSize: "8GiB"},
+		},
+	}
+}
+
+// Override returns the library with the limits and presets of another library taking precedence.
+func (l ResourceLibrary) Override(other ResourceLibrary) ResourceLibrary {
+	l.Limits = l.Limits.Override(other.Limits)
+
+	presets := maps.Clone(l.Presets)
+	if presets == nil {
+		presets = map[string]api.MachineResources{}
+	}
+
+	maps.Copy(presets, other.Presets)
+	l.Presets = presets
+
+	return l
+}
+
+// PlatformLimits are the limits of a playground. Zero values are not checked.
+type PlatformLimits struct {
+	MaxMachines int `yaml:"maxMachines,omitempty" json:"maxMachines,omitempty"`
+
+	// Totals of all machines.
+	CPUCount int    `yaml:"cpuCount,omitempty" json:"cpuCount,omitempty"`
+	RAMSize  string `yaml:"ramSize,omitempty"  json:"ramSize,omitempty"`
+	DiskSize string `yaml:"diskSize,omitempty" json:"diskSize,omitempty"`
+
+	// Defaults of the platform for machines and drives that don't set them.
+	DefaultCPUCount  int    `yaml:"defaultCpuCount,omitempty"  json:"defaultCpuCount,omitempty"`
+	DefaultRAMSize   string `yaml:"defaultRamSize,omitempty"   json:"defaultRamSize,omitempty"`
+	DefaultDriveSize string `yaml:"defaultDriveSize,omitempty" json:"defaultDriveSize,omitempty"`
+}
+
+// Override returns the limits with the non-zero fields of other taking precedence.
+func (l PlatformLimits) Override(other PlatformLimits) PlatformLimits {
+	if other.MaxMachines != 0 {
+		l.MaxMachines = other.MaxMachines
+	}
+
+	if other.CPUCount != 0 {
+		l.CPUCount = other.CPUCount
+	}
+
+	if other.RAMSize != "" {
+		l.RAMSize = other.RAMSize
+	}
+
+	if other.DiskSize != "" {
+		l.DiskSize = other.DiskSize
+	}
+
+	if other.DefaultCPUCount != 0 {
+		l.DefaultCPUCount = other.DefaultCPUCount
+	}
+
+	if other.DefaultRAMSize != "" {
+		l.DefaultRAMSize = other.DefaultRAMSize
+	}
+
+	if other.DefaultDriveSize != "" {
+		l.DefaultDriveSize = other.DefaultDriveSize
+	}
+
+	return l
+}
+
+// ApplyResourcePresets expands the resource preset of every machine that has one.
+// Resources set on the machine override the ones of the preset.
+func (m PlaygroundMachines) ApplyResourcePresets(presets map[string]api.MachineResources) (PlaygroundMachines, error) {
+	machines := make(PlaygroundMachines, 0, len(m))
+
+	for _, machine := range m {
+		if machine.ResourcePreset == "" {
+			machines = append(machines, machine)
+
+			continue
+		}
+
+		preset, ok := presets[machine.ResourcePreset]
+		if !ok {
+			return nil, fmt.Errorf("machine %s: unknown resource preset %s", machine.Name, machine.ResourcePreset)
+		}
+
+		if machine.Resources != nil {
+			preset = mergeResources(preset, *machine.Resources)
+		}
+
+		machine.Resources = &preset
+		machine.ResourcePreset = ""
+
+		machines = append(machines, machine)
+	}
+
+	return machines, nil
+}
+
+// FitDrives sets the size of drives without one when the default drive size of the platform would exceed the disk limit:
+// the remaining disk space is shared evenly between them (in whole GiBs).
+//
+// Drives of base machines are taken into account, but only the drives of machines are changed.
+func (l PlatformLimits) FitDrives(
+	machines PlaygroundMachines,
+	base []api.PlaygroundMachine,
+	merges map[string]MachineMerge,
+) (PlaygroundMachines, error) {
+	if l.DiskSize == "" || l.DefaultDriveSize == "" {
+		return machines, nil
+	}
+
+	diskLimit, err := ParseSize(l.DiskSize)
+	if err != nil {
+		return nil, fmt.Errorf("disk limit: %w", err)
+	}
+
+	defaultDriveSize, err := ParseSize(l.DefaultDriveSize)
+	if err != nil {
+		return nil, fmt.Errorf("default drive size: %w", err)
+	}
+
+	var (
+		sized   int64
+		unsized int
+		total   int64
+	)
+
+	for _, machine := range InheritMachines(base, machines.Convert(), merges) {
+		for _, drive := range machine.Drives {
+			if drive.Size == "" {
+				unsized++
+				total += defaultDriveSize
+
+				continue
+			}
+
+			size, err := ParseSize(drive.Size)
+			if err != nil {
+				return nil, fmt.Errorf("machine %s: drive %s: %w", machine.Name, drive.Mount, err)
+			}
+
+			sized += size
+			total += size
+		}
+	}
+
+	if unsized == 0 || total <= diskLimit || sized >= diskLimit {
+		return machines, nil
+	}
+
+	share := (diskLimit - sized) / int64(unsized) / gib * gib
+	if share == 0 {
+		return machines, nil
+	}
+
+	machines = slices.Clone(machines)
+
+	for i, machine := range machines {
+		machines[i].Drives = slices.Clone(machine.Drives)
+
+		for j, drive := range machine.Drives {
+			if drive.Size == "" {
+				machines[i].Drives[j].Size = FormatSize(share)
+			}
+		}
+	}
+
+	return machines, nil
+}
+
+// Check checks the machines against the limits and reports every exceeded limit with the totals.
+func (l PlatformLimits) Check(machines []api.PlaygroundMachine) error {
+	var errs []error
+
+	if l.MaxMachines > 0 && len(machines) > l.MaxMachines {
+		errs = append(errs, fmt.Errorf("%d machines exceed the limit of %d machines", len(machines), l.MaxMachines))
+	}
+
+	var (
+		cpu, ram, disk                      int64
+		cpuDetails, ramDetails, diskDetails []string
+	)
+
+	defaultRAMSize, err := parseOptionalSize(l.DefaultRAMSize)
+	if err != nil {
+		return fmt.Errorf("default RAM size: %w", err)
+	}
+
+	defaultDriveSize, err := parseOptionalSize(l.DefaultDriveSize)
+	if err != nil {
+		return fmt.Errorf("default drive size: %w", err)
+	}
+
+	for _, machine := range machines {
+		machineCPU := int64(l.DefaultCPUCount)
+		machineRAM := defaultRAMSize
+
+		if machine.Resources != nil && machine.Resources.CPUCount != 0 {
+			machineCPU = int64(machine.Resources.CPUCount)
+		}
+
+		if machine.Resources != nil && machine.Resources.RAMSize != "" {
+			machineRAM, err = ParseSize(machine.Resources.RAMSize)
+			if err != nil {
+				return fmt.Errorf("machine %s: RAM size: %w", machine.Name, err)
+			}
+		}
+
+		var machineDisk int64
+
+		for _, drive := range machine.Drives {
+			size := defaultDriveSize
+
+			if drive.Size != "" {
+				size, err = ParseSize(drive.Size)
+				if err != nil {
+					return fmt.Errorf("machine %s: drive %s: %w", machine.Name, drive.Mount, err)
+				}
+			}
+
+			machineDisk += size
+		}
+
+		cpu += machineCPU
+		ram += machineRAM
+		disk += machineDisk
+
+		cpuDetails = append(cpuDetails, fmt.Sprintf("%s: %d", machine.Name, machineCPU))
+		ramDetails = append(ramDetails, fmt.Sprintf("%s: %s", machine.Name, FormatSize(machineRAM)))
+		diskDetails = append(diskDetails, fmt.Sprintf("%s: %s", machine.Name, FormatSize(machineDisk)))
+	}
+
+	if l.CPUCount > 0 && cpu > int64(l.CPUCount) {
+		errs = append(errs, fmt.Errorf(
+			"total CPU count %d exceeds the limit of %d (%s)",
+			cpu, l.CPUCount, strings.Join(cpuDetails, ", "),
+		))
+	}
+
+	if l.RAMSize != "" {
+		limit, err := ParseSize(l.RAMSize)
+		if err != nil {
+			return fmt.Errorf("RAM limit: %w", err)
+		}
+
+		if ram > limit {
+			errs = append(errs, fmt.Errorf(
+				"total RAM size %s exceeds the limit of %s (%s)",
+				FormatSize(ram), FormatSize(limit), strings.Join(ramDetails, ", "),
+			))
+		}
+	}
+
+	if l.DiskSize != "" {
+		limit, err := ParseSize(l.DiskSize)
+		if err != nil {
+			return fmt.Errorf("disk limit: %w", err)
+		}
+
+		if disk > limit {
+			errs = append(errs, fmt.Errorf(
+				"total disk size %s exceeds the limit of %s (%s)",
+				FormatSize(disk), FormatSize(limit), strings.Join(diskDetails, ", "),
+			))
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("playground exceeds platform limits: %w", errors.Join(errs...))
+	}
+
+	return nil
+}
+
+const (
+	kib = int64(1) << 10
+	mib = kib << 10
+	gib = mib << 10
+	tib = gib << 10
+)
+
+var sizeUnits = map[string]int64{
+	"":    1,
+	"b":   1,
+	"k":   1000,
+	"kb":  1000,
+	"ki":  kib,
+	"kib": kib,
+	"m":   1000 * 1000,
+	"mb":  1000 * 1000,
+	"mi":  mib,
+	"mib": mib,
+	"g":   1000 * 1000 * 1000,
+	"gb":  1000 * 1000 * 1000,
+	"gi":  gib,
+	"gib": gib,
+	"t":   1000 * 1000 * 1000 * 1000,
+	"tb":  1000 * 1000 * 1000 * 1000,
+	"ti":  tib,
+	"tib": tib,
+}
+
+// ParseSize parses a size (e.g. 512MiB, 4Gi or 30GB) into bytes.
+func ParseSize(s string) (int64, error) {
+	s = strings.TrimSpace(s)
+
+	i := strings.IndexFunc(s, func(r rune) bool { return !unicode.IsDigit(r) && r != '.' })
+	if i < 0 {
+		i = len(s)
+	}
+
+	value, err := strconv.ParseFloat(s[:i], 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+
+	unit, ok := sizeUnits[strings.ToLower(strings.TrimSpace(s[i:]))]
+	if !ok {
+		return 0, fmt.Errorf("invalid size %q: unknown unit", s)
+	}
+
+	return int64(value * float64(unit)), nil
+}
+
+// FormatSize formats a size in TiB or GiB (MiB below 1GiB), with one decimal if needed.
+func FormatSize(size int64) string {
+	switch {
+	case size == 0:
+		return "0GiB"
+	case size%tib == 0:
+		return fmt.Sprintf("%dTiB", size/tib)
+	case size%gib == 0:
+		return fmt.Sprintf("%dGiB", size/gib)
+	case size < gib && size%mib == 0:
+		return fmt.Sprintf("%dMiB", size/mib)
+	default:
+		return fmt.Sprintf("%.1fGiB", float64(size)/float64(gib))
+	}
+}
+
+func parseOptionalSize(s string) (int64, error) {
+	if s == "" {
+		return 0, nil
+	}
+
+	return ParseSize(s)
+}
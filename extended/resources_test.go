@@ -0,0 +1,168 @@
+package extended_test
+
+import (
+	"testing"
+
+	"github.com/iximiuz/labctl/api"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sagikazarmark/labx/extended"
+)
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		size     string
+		expected int64
+	}{
+		{"512MiB", 512 << 20},
+		{"4Gi", 4 << 30},
+		{"30GiB", 30 << 30},
+		{"1.5GiB", 3 << 29},
+		{"2GB", 2_000_000_000},
+		{"1024", 1024},
+	}
+
+	for _, test := range tests {
+		t.Run(test.size, func(t *testing.T) {
+			size, err := extended.ParseSize(test.size)
+			require.NoError(t, err)
+
+			assert.Equal(t, test.expected, size)
+		})
+	}
+
+	_, err := extended.ParseSize("lots")
+	require.Error(t, err)
+
+	_, err = extended.ParseSize("4XB")
+	require.ErrorContains(t, err, "unknown unit")
+}
+
+func TestFormatSize(t *testing.T) {
+	assert.Equal(t, "30GiB", extended.FormatSize(30<<30))
+	assert.Equal(t, "512MiB", extended.FormatSize(512<<20))
+	assert.Equal(t, "1TiB", extended.FormatSize(1<<40))
+	assert.Equal(t, "1.9GiB", extended.FormatSize(2_000_000_000))
+}
+
+func TestPlaygroundMachines_ApplyResourcePresets(t *testing.T) {
+	presets := extended.DefaultResourceLibrary().Presets
+
+	machines, err := extended.PlaygroundMachines{
+		{Name: "small", ResourcePreset: "small"},
+		{Name: "custom", ResourcePreset: "large", Resources: &api.MachineResources{RAMSize: "16GiB"}},
+		{Name: "none"},
+	}.ApplyResourcePresets(presets)
+	require.NoError(t, err)
+
+	assert.Equal(t, &api.MachineResources{CPUCount: 1, RAMSize: "2GiB"}, machines[0].Resources)
+	assert.Equal(t, &api.MachineResources{CPUCount: 4, RAMSize: "16GiB"}, machines[1].Resources)
+	assert.Empty(t, machines[1].ResourcePreset)
+	assert.Nil(t, machines[2].Resources)
+
+	_, err = extended.PlaygroundMachines{{Name: "node", ResourcePreset: "huge"}}.ApplyResourcePresets(presets)
+	require.EqualError(t, err, "machine node: unknown resource preset huge")
+}
+
+func TestResourceLibrary_Override(t *testing.T) {
+	library := extended.DefaultResourceLibrary().Override(extended.ResourceLibrary{
+		Limits:  extended.PlatformLimits{DiskSize: "200GiB"},
+		Presets: map[string]api.MachineResources{"small": {CPUCount: 2, RAMSize: "1GiB"}},
+	})
+
+	assert.Equal(t, "200GiB", library.Limits.DiskSize)
+	assert.Equal(t, extended.PlatformLimits{DiskSize: "200GiB"}, library.Limits, "limits are not checked by default")
+	assert.Equal(t, api.MachineResources{CPUCount: 2, RAMSize: "1GiB"}, library.Presets["small"])
+	assert.Contains(t, library.Presets, "medium")
+}
+
+func TestPlatformLimits_FitDrives(t *testing.T) {
+	drive := func(size string) []api.MachineDrive {
+		return []api.MachineDrive{{Source: "oci://ghcr.io/iximiuz/labs/rootfs:ubuntu-24-04", Mount: "/", Size: size}}
+	}
+
+	limits := extended.PlatformLimits{
+		DiskSize:         "120GiB",
+		DefaultDriveSize: "40GiB",
+	}
+
+	t.Run("fits", func(t *testing.T) {
+		machines := extended.PlaygroundMachines{
+			{Name: "node-01", Drives: drive("")},
+			{Name: "node-02", Drives: drive("")},
+		}
+
+		fitted, err := limits.FitDrives(machines, nil, nil)
+		require.NoError(t, err)
+
+		assert.Empty(t, fitted[0].Drives[0].Size)
+	})
+
+	t.Run("shares remaining space", func(t *testing.T) {
+		machines := extended.PlaygroundMachines{
+			{Name: "node-01", Drives: drive("")},
+			{Name: "node-02", Drives: drive("")},
+			{Name: "node-03", Drives: drive("")},
+			{Name: "node-04", Drives: drive("")},
+		}
+
+		fitted, err := limits.FitDrives(machines, nil, nil)
+		require.NoError(t, err)
+
+		for _, machine := range fitted {
+			assert.Equal(t, "30GiB", machine.Drives[0].Size)
+		}
+
+		assert.Empty(t, machines[0].Drives[0].Size, "input is not modified")
+	})
+
+	t.Run("base machines", func(t *testing.T) {
+		base := []api.PlaygroundMachine{
+			{Name: "registry", Drives: drive("60GiB")},
+		}
+
+		machines := extended.PlaygroundMachines{
+			{Name: "node-01", Drives: drive("")},
+			{Name: "node-02", Drives: drive("")},
+		}
+
+		fitted, err := limits.FitDrives(machines, base, nil)
+		require.NoError(t, err)
+
+		assert.Equal(t, "30GiB", fitted[0].Drives[0].Size)
+		assert.Equal(t, "30GiB", fitted[1].Drives[0].Size)
+	})
+}
+
+func TestPlatformLimits_Check(t *testing.T) {
+	limits := extended.PlatformLimits{
+		MaxMachines:      2,
+		CPUCount:         4,
+		RAMSize:          "8GiB",
+		DiskSize:         "50GiB",
+		DefaultCPUCount:  2,
+		DefaultRAMSize:   "4GiB",
+		DefaultDriveSize: "40GiB",
+	}
+
+	drive := []api.MachineDrive{{Mount: "/"}}
+
+	err := limits.Check([]api.PlaygroundMachine{
+		{Name: "node-01", Drives: []api.MachineDrive{{Mount: "/", Size: "25GiB"}}},
+		{Name: "node-02", Resources: &api.MachineResources{CPUCount: 1}, Drives: []api.MachineDrive{{Mount: "/", Size: "25GiB"}}},
+	})
+	require.NoError(t, err)
+
+	err = limits.Check([]api.PlaygroundMachine{
+		{Name: "node-01", Drives: drive},
+		{Name: "node-02", Resources: &api.MachineResources{RAMSize: "8GiB"}, Drives: drive},
+		{Name: "node-03", Resources: &api.MachineResources{CPUCount: 1, RAMSize: "512MiB"}},
+	})
+	require.Error(t, err)
+
+	assert.Equal(t, `playground exceeds platform limits: 3 machines exceed the limit of 2 machines
+total CPU count 5 exceeds the limit of 4 (node-01: 2, node-02: 2, node-03: 1)
+total RAM size 12.5GiB exceeds the limit of 8GiB (node-01: 4GiB, node-02: 8GiB, node-03: 512MiB)
+total disk size 80GiB exceeds the limit of 50GiB (node-01: 40GiB, node-02: 40GiB, node-03: 0GiB)`, err.Error())
+}
@@ -0,0 +1,140 @@
+package extended
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/iximiuz/labctl/api"
+)
+
+// AutoTabs configures generating playground tabs from machines.
+type AutoTabs struct {
+	// Terminals generates a terminal tab per machine.
+	Terminals bool `yaml:"terminals,omitempty" json:"terminals,omitempty"`
+
+	// IDE generates an IDE tab.
+	IDE bool `yaml:"ide,omitempty" json:"ide,omitempty"`
+
+	// WebPages generates web page tabs.
+	WebPages []AutoWebPage `yaml:"webPages,omitempty" json:"webPages,omitempty"`
+}
+
+// AutoWebPage is a web page tab pointing to a URL or a port of a machine.
+type AutoWebPage struct {
+	Name string `yaml:"name" json:"name"`
+
+	// Machine defaults to the first machine.
+	Machine string `yaml:"machine,omitempty" json:"machine,omitempty"`
+	Port    int    `yaml:"port,omitempty"    json:"port,omitempty"`
+	URL     string `yaml:"url,omitempty"     json:"url,omitempty"`
+}
+
+// Enabled reports whether any tabs should be generated.
+func (a AutoTabs) Enabled() bool {
+	return a.Terminals || a.IDE || len(a.WebPages) > 0
+}
+
+// tabKindOrder is the order of generated tabs (unknown kinds come last).
+var tabKindOrder = []string{"ide", "terminal", "kexp", "web-page"}
+
+// GenerateTabs generates tabs for the given machines and merges them with the hand-written tabs.
+//
+// Hand-written tabs replace generated tabs with the same ID or name (or the terminal generated for the same machine),
+// duplicates are dropped, and tabs are ordered by kind: IDE, terminals, kexp, web pages and everything else.
+//
+// Hand-written tabs are validated to reference existing machines, even if no tabs are generated.
+func GenerateTabs(auto AutoTabs, machines []api.PlaygroundMachine, tabs []api.PlaygroundTab) ([]api.PlaygroundTab, error) {
+	names := make([]string, 0, len(machines))
+	for _, machine := range machines {
+		names = append(names, machine.Name)
+	}
+
+	// Machines are unknown (e.g. content without a base playground)
+	if len(names) > 0 {
+		for _, tab := range tabs {
+			if tab.Machine != "" && !slices.Contains(names, tab.Machine) {
+				return nil, fmt.Errorf("tab %s: unknown machine %s", TabKey(tab), tab.Machine)
+			}
+		}
+	}
+
+	if !auto.Enabled() {
+		return tabs, nil
+	}
+
+	var generated []api.PlaygroundTab
+
+	if auto.IDE {
+		generated = append(generated, api.PlaygroundTab{Kind: "ide", Name: "IDE"})
+	}
+
+	if auto.Terminals {
+		for _, machine := range machines {
+			generated = append(generated, api.PlaygroundTab{Kind: "terminal", Name: machine.Name, Machine: machine.Name})
+		}
+	}
+
+	for _, page := range auto.WebPages {
+		tab := api.PlaygroundTab{Kind: "web-page", Name: page.Name, URL: page.URL}
+
+		if page.URL == "" {
+			tab.Machine = page.Machine
+			if tab.Machine == "" && len(names) > 0 {
+				tab.Machine = names[0]
+			}
+
+			tab.Number = page.Port
+		}
+
+		if tab.Machine != "" && !slices.Contains(names, tab.Machine) {
+			return nil, fmt.Errorf("web page %s: unknown machine %s", page.Name, tab.Machine)
+		}
+
+		generated = append(generated, tab)
+	}
+
+	result := slices.Clone(generated)
+	replaced := make([]bool, len(generated))
+
+	for _, tab := range tabs {
+		i := slices.IndexFunc(result, func(t api.PlaygroundTab) bool { return TabKey(t) == TabKey(tab) })
+
+		// Hand-written terminals replace the terminal generated for the same machine
+		if i < 0 && tab.Kind == "terminal" {
+			j := slices.IndexFunc(generated, func(t api.PlaygroundTab) bool {
+				return t.Kind == "terminal" && t.ID == "" && t.Machine == tab.Machine
+			})
+
+			if j >= 0 && !replaced[j] {
+				i = j
+			}
+		}
+
+		switch {
+		case i < 0:
+			result = append(result, tab)
+
+		case i < len(generated) && !replaced[i]:
+			result[i] = tab
+			replaced[i] = true
+
+		default:
+			// Duplicate of a hand-written tab
+		}
+	}
+
+	slices.SortStableFunc(result, func(a api.PlaygroundTab, b api.PlaygroundTab) int {
+		return tabKindRank(a.Kind) - tabKindRank(b.Kind)
+	})
+
+	return result, nil
+}
+
+func tabKindRank(kind string) int {
+	i := slices.Index(tabKindOrder, kind)
+	if i < 0 {
+		return len(tabKindOrder)
+	}
+
+	return i
+}
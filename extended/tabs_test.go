@@ -0,0 +1,77 @@
+package extended_test
+
+import (
+	"testing"
+
+	"github.com/iximiuz/labctl/api"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sagikazarmark/labx/extended"
+)
+
+func TestGenerateTabs(t *testing.T) {
+	machines := []api.PlaygroundMachine{
+		{
+			Name: "dev",
+			Users: []api.MachineUser{
+				{Name: "root"},
+				{Name: "laborant", Default: true},
+				{Name: "admin"},
+			},
+		},
+		{Name: "db"},
+	}
+
+	auto := extended.AutoTabs{
+		Terminals: true,
+		IDE:       true,
+		WebPages: []extended.AutoWebPage{
+			{Name: "App", Port: 8080},
+			{Name: "Docs", URL: "https://example.com"},
+		},
+	}
+
+	tabs := []api.PlaygroundTab{
+		{Kind: "web-page", Name: "Grafana", Machine: "db", Number: 3000},
+		{Kind: "terminal", Name: "Database", Machine: "db"},
+		{Kind: "terminal", Name: "Database", Machine: "db"},
+		{Kind: "kexp", Name: "Explorer"},
+	}
+
+	expected := []api.PlaygroundTab{
+		{Kind: "ide", Name: "IDE"},
+		{Kind: "terminal", Name: "dev", Machine: "dev"},
+		{Kind: "terminal", Name: "Database", Machine: "db"},
+		{Kind: "kexp", Name: "Explorer"},
+		{Kind: "web-page", Name: "App", Machine: "dev", Number: 8080},
+		{Kind: "web-page", Name: "Docs", URL: "https://example.com"},
+		{Kind: "web-page", Name: "Grafana", Machine: "db", Number: 3000},
+	}
+
+	actual, err := extended.GenerateTabs(auto, machines, tabs)
+	require.NoError(t, err)
+
+	assert.Equal(t, expected, actual)
+}
+
+func TestGenerateTabs_Disabled(t *testing.T) {
+	tabs := []api.PlaygroundTab{
+		{Kind: "terminal", Name: "dev", Machine: "dev"},
+		{Kind: "ide", Name: "IDE"},
+	}
+
+	actual, err := extended.GenerateTabs(extended.AutoTabs{}, []api.PlaygroundMachine{{Name: "dev"}}, tabs)
+	require.NoError(t, err)
+
+	assert.Equal(t, tabs, actual)
+}
+
+func TestGenerateTabs_UnknownMachine(t *testing.T) {
+	tabs := []api.PlaygroundTab{
+		{Kind: "terminal", Name: "node", Machine: "node"},
+	}
+
+	_, err := extended.GenerateTabs(extended.AutoTabs{}, []api.PlaygroundMachine{{Name: "dev"}}, tabs)
+	require.EqualError(t, err, "tab node: unknown machine node")
+}
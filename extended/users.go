@@ -0,0 +1,92 @@
+package extended
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// HomeDir returns the home directory of the user.
+func (u MachineUser) HomeDir() string {
+	if u.Name == "root" {
+		return "/root"
+	}
+
+	return path.Join("/home", u.Name)
+}
+
+// ConfigureUsers compiles the groups, sudo, authorized keys and shell of users
+// into startup files and init tasks.
+//
+// User and group names are not quoted: they must be validated first.
+func (m PlaygroundMachine) ConfigureUsers() PlaygroundMachine {
+	var startupFiles MachineStartupFiles
+
+	for _, user := range m.Users {
+		if user.Sudo {
+			// Files in /etc/sudoers.d containing a dot are ignored by sudo
+			startupFiles = append(startupFiles, MachineStartupFile{
+				Path:    "/etc/sudoers.d/90-labx-" + strings.ReplaceAll(user.Name, ".", "_"),
+				Content: fmt.Sprintf("%s ALL=(ALL) NOPASSWD:ALL\n", user.Name),
+				Mode:    "440",
+				Owner:   "root:root",
+			})
+		}
+
+		sshDir := path.Join(user.HomeDir(), ".ssh")
+
+		if user.AuthorizedKeys != "" {
+			keys := user.AuthorizedKeys
+			if !strings.HasSuffix(keys, "\n") {
+				keys += "\n"
+			}
+
+			// Keys added by the platform are kept
+			startupFiles = append(startupFiles, MachineStartupFile{
+				Path:    path.Join(sshDir, "authorized_keys"),
+				Content: keys,
+				Append:  true,
+			})
+		}
+
+		var run strings.Builder
+
+		for _, group := range user.Groups {
+			fmt.Fprintf(&run, "getent group %s >/dev/null || groupadd %s\n", group, group)
+		}
+
+		if len(user.Groups) > 0 {
+			fmt.Fprintf(&run, "usermod -aG %s %s\n", strings.Join(user.Groups, ","), user.Name)
+		}
+
+		if user.Shell != "" {
+			fmt.Fprintf(&run, "usermod -s %s %s\n", shellQuote(user.Shell), user.Name)
+		}
+
+		// Startup files are written as root
+		if user.AuthorizedKeys != "" {
+			fmt.Fprintf(&run, "chmod 700 %s\n", shellQuote(sshDir))
+			fmt.Fprintf(&run, "chmod 600 %s\n", shellQuote(path.Join(sshDir, "authorized_keys")))
+			fmt.Fprintf(&run, "chown -R %s: %s\n", user.Name, shellQuote(sshDir))
+		}
+
+		if run.Len() == 0 {
+			continue
+		}
+
+		if m.InitTasks == nil {
+			m.InitTasks = InitTasks{}
+		}
+
+		m.InitTasks[taskName("init", m.Name, "user", user.Name)] = InitTask{
+			Machine: StringList{m.Name},
+			Init:    true,
+			User:    StringList{"root"},
+			Run:     run.String(),
+		}
+	}
+
+	m.StartupFiles = append(m.StartupFiles, startupFiles...)
+
+	return m
+}
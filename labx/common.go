@@ -11,6 +11,7 @@ import (
 	"github.com/sagikazarmark/go-finder"
 
 	"github.com/sagikazarmark/labx/core"
+	"github.com/sagikazarmark/labx/pkg/fsx"
 )
 
 const defaultImageRepo = "ghcr.io/sagikazarmark/iximiuz-labs"
@@ -26,7 +27,7 @@ kind: warning
 `
 
 // copyStaticFiles copies static files from source to destination
-func copyStaticFiles(root *os.Root, output *os.Root, sourcePath, destPath string) error {
+func copyStaticFiles(root *os.Root, output fsx.WriteFS, sourcePath, destPath string) error {
 	fsys := root.FS()
 
 	// Create the parent static directory first
@@ -101,7 +102,7 @@ func writeManifest[T api.PlaygroundManifest | core.ContentManifest](w io.Writer,
 }
 
 func renderManifest[T api.PlaygroundManifest | core.ContentManifest](
-	output *os.Root,
+	output fsx.WriteFS,
 	filePath string,
 	manifest T,
 ) error {
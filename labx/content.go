@@ -1,22 +1,29 @@
 package labx
 
 import (
+	"cmp"
+	"context"
 	"fmt"
 	"io"
 	"io/fs"
+	"maps"
 	"os"
+	"slices"
 	"strings"
 	"text/template"
 
 	"github.com/goccy/go-yaml"
+	"github.com/iximiuz/labctl/api"
 	"github.com/iximiuz/labctl/content"
+	"github.com/samber/lo"
 
 	"github.com/sagikazarmark/labx/core"
 	"github.com/sagikazarmark/labx/extended"
+	"github.com/sagikazarmark/labx/pkg/fsx"
 )
 
 func Content(ctx GenerateContext) error {
-	extendedManifest, err := loadContentManifest(ctx.Root.FS(), ctx.Channel)
+	extendedManifest, err := loadContentManifest(ctx.Root.FS(), ctx.manifestOptions())
 	if err != nil {
 		return err
 	}
@@ -48,13 +55,21 @@ func Content(ctx GenerateContext) error {
 	}
 
 	renderCtx := renderContext{
-		Root:         ctx.Root,
-		Output:       ctx.Output,
-		Channel:      ctx.Channel,
-		Name:         extendedManifest.Channels[ctx.Channel].Name,
-		Manifest:     manifest,
-		Extra:        ctx.ExtraData,
-		BaseTemplate: ctx.BaseTemplate,
+		Root:           ctx.Root,
+		Output:         ctx.Output,
+		Channel:        ctx.Channel,
+		Name:           contentName(extendedManifest, ctx.Channel),
+		Manifest:       manifest,
+		Extra:          ctx.ExtraData,
+		BaseTemplate:   ctx.BaseTemplate,
+		MachinePresets: ctx.MachinePresets,
+		Resources:      ctx.Resources,
+		DriveSources:   ctx.DriveSources,
+		PlaygroundDirs: ctx.PlaygroundDirs,
+		RemoteBases:    ctx.RemoteBases,
+		Secrets:        ctx.Secrets,
+		Context:        ctx.Context,
+		Limiter:        ctx.Limiter,
 	}
 
 	data := templateData{
@@ -104,7 +119,9 @@ func Content(ctx GenerateContext) error {
 	return nil
 }
 
-func loadContentManifest(fsys fs.FS, channel string) (extended.ContentManifest, error) {
+func loadContentManifest(fsys fs.FS, opts manifestOptions) (extended.ContentManifest, error) {
+	channel := opts.Channel
+
 	manifestFile, err := fsys.Open("manifest.yaml")
 	if err != nil {
 		return extended.ContentManifest{}, err
@@ -120,33 +137,104 @@ func loadContentManifest(fsys fs.FS, channel string) (extended.ContentManifest,
 		return extended.ContentManifest{}, err
 	}
 
+	err = interpolateSecrets(opts.Secrets, &extendedManifest)
+	if err != nil {
+		return extended.ContentManifest{}, err
+	}
+
+	machines, err := extendedManifest.Playground.Machines.ApplyPresets(opts.MachinePresets)
+	if err != nil {
+		return extended.ContentManifest{}, err
+	}
+
+	machines, err = machines.ApplyResourcePresets(opts.Resources.Presets)
+	if err != nil {
+		return extended.ContentManifest{}, err
+	}
+
+	machines, err = machines.Expand()
+	if err != nil {
+		return extended.ContentManifest{}, err
+	}
+
+	extendedManifest.Playground.Machines = machines
+
 	if extendedManifest.Playground.Name != "" {
-		basePlayground, err := getPlaygroundManifest(extendedManifest.Playground.Name)
+		basePlayground, err := resolvePlaygroundManifest(extendedManifest.Playground.Name, opts)
 		if err != nil {
 			return extended.ContentManifest{}, err
 		}
 
+		// Local playgrounds are referenced by the name of the channel they are published to
+		extendedManifest.Playground.Name = basePlayground.Name
 		extendedManifest.Playground.BaseName = basePlayground.Name
 		extendedManifest.Playground.Base = basePlayground.Playground
 
+		// Lessons use the default images of their course
+		contentKind, name := extendedManifest.Kind, contentName(extendedManifest, channel)
+		if opts.ContentKind != "" {
+			contentKind, name = opts.ContentKind, opts.ContentName
+		}
+
+		renderer := &TemplateRenderer{
+			Funcs:   createTemplateFuncs(fsys, opts.Secrets),
+			Channel: channel,
+			Extra:   opts.ExtraData,
+		}
+
 		machinesProcessor := MachinesProcessor{
 			MachineProcessor: MachineProcessor{
 				UserProcessor: MachineUserProcessor{
-					Fsys: fsys,
+					Fsys:     fsys,
+					Renderer: renderer,
 				},
 				DriveProcessor: MachineDriveProcessor{
-					ContentKind:      extendedManifest.Kind,
-					ContentName:      "",
+					ContentKind:      contentKind,
+					ContentName:      name,
 					Channel:          channel,
+					Extra:            opts.ExtraData,
 					DefaultImageRepo: defaultImageRepo,
+					Sources:          newDriveSourceRegistry(opts.DriveSources, defaultImageRepo, renderer.Funcs),
+					Images:           opts.Images,
 				},
 				StartupFileProcessor: MachineStartupFileProcessor{
-					Fsys: fsys,
+					Fsys:     fsys,
+					Renderer: renderer,
 				},
 			},
+			Limiter: opts.Limiter,
+		}
+
+		networks, machines, addresses, err := allocateNetworks(
+			extendedManifest.Playground.NetworkPool,
+			extendedManifest.Playground.Networks,
+			extendedManifest.Playground.Machines,
+			extendedManifest.Playground.Base,
+		)
+		if err != nil {
+			return extended.ContentManifest{}, err
 		}
 
-		machines, err := machinesProcessor.Process(extendedManifest.Playground.Machines)
+		extendedManifest.Playground.Networks = networks
+		machinesProcessor.MachineProcessor.Addresses = addresses
+		machinesProcessor.MachineProcessor.TaskNames = slices.Concat(
+			slices.Collect(maps.Keys(extendedManifest.Tasks)),
+			slices.Collect(maps.Keys(extendedManifest.Playground.Base.InitTasks)),
+		)
+
+		machines = configureHosts(
+			extendedManifest.Playground.Domain,
+			extendedManifest.Playground.ClusterHosts,
+			machines,
+			extendedManifest.Playground.Base,
+		)
+
+		machines, err = machinesProcessor.Process(opts.ctx(), machines)
+		if err != nil {
+			return extended.ContentManifest{}, err
+		}
+
+		machines, err = checkLimits(opts.Resources.Limits, machines, extendedManifest.Playground.Base)
 		if err != nil {
 			return extended.ContentManifest{}, err
 		}
@@ -154,6 +242,43 @@ func loadContentManifest(fsys fs.FS, channel string) (extended.ContentManifest,
 		extendedManifest.Playground.Machines = machines
 	}
 
+	// Tasks run on the content machines or on the playground machines when not overridden
+	names := lo.Map(
+		extendedManifest.Playground.Machines,
+		func(machine extended.PlaygroundMachine, _ int) string { return machine.Name },
+	)
+	if len(names) == 0 {
+		names = lo.Map(
+			extendedManifest.Playground.Base.Machines,
+			func(machine api.PlaygroundMachine, _ int) string { return machine.Name },
+		)
+	}
+
+	tasks, err := extendedManifest.Tasks.ExpandMachines(names)
+	if err != nil {
+		return extended.ContentManifest{}, err
+	}
+
+	extendedManifest.Tasks = tasks
+
+	// Tabs are generated for the content machines or for the playground machines when not overridden
+	tabMachines := lo.Map(
+		extendedManifest.Playground.Convert().Machines,
+		func(machine core.ContentPlaygroundMachine, _ int) api.PlaygroundMachine {
+			return api.PlaygroundMachine{Name: machine.Name, Users: machine.Users}
+		},
+	)
+	if len(tabMachines) == 0 {
+		tabMachines = extendedManifest.Playground.Base.Machines
+	}
+
+	tabs, err := extended.GenerateTabs(extendedManifest.Playground.AutoTabs, tabMachines, extendedManifest.Playground.Tabs)
+	if err != nil {
+		return extended.ContentManifest{}, err
+	}
+
+	extendedManifest.Playground.Tabs = tabs
+
 	// Apply channel-specific title processing only for real content kinds (not lessons)
 	if channel != "live" && string(extendedManifest.Kind) != "lesson" {
 		extendedManifest.Title = fmt.Sprintf(
@@ -166,8 +291,26 @@ func loadContentManifest(fsys fs.FS, channel string) (extended.ContentManifest,
 	return extendedManifest, err
 }
 
-func convertContentManifest(fsys fs.FS, channel string) (core.ContentManifest, error) {
-	extendedManifest, err := loadContentManifest(fsys, channel)
+// contentName returns the name of the content in a channel (falling back to its slug).
+func contentName(manifest extended.ContentManifest, channel string) string {
+	return cmp.Or(manifest.Channels[channel].Name, manifest.Slug)
+}
+
+// lessonOptions returns the options for loading the manifest of a lesson
+// (at lessonPath relative to the lessons or modules directory) of a course.
+func lessonOptions(opts manifestOptions, course string, lessonPath string) manifestOptions {
+	opts.ContentKind = content.KindCourse
+	opts.ContentName = ""
+
+	if course != "" {
+		opts.ContentName = course + "/" + lessonPath
+	}
+
+	return opts
+}
+
+func convertContentManifest(fsys fs.FS, opts manifestOptions) (core.ContentManifest, error) {
+	extendedManifest, err := loadContentManifest(fsys, opts)
 
 	manifest := extendedManifest.Convert()
 
@@ -177,12 +320,47 @@ func convertContentManifest(fsys fs.FS, channel string) (core.ContentManifest, e
 // renderContext holds all the data needed for rendering templates
 type renderContext struct {
 	Root         *os.Root
-	Output       *os.Root
+	Output       fsx.WriteFS
 	Channel      string
 	Name         string
 	Manifest     core.ContentManifest
 	Extra        map[string]any
 	BaseTemplate *template.Template
+
+	MachinePresets map[string]extended.PlaygroundMachine
+	Resources      extended.ResourceLibrary
+	DriveSources   DriveSourceConfig
+	PlaygroundDirs []fs.FS
+	RemoteBases    bool
+	Secrets        *Secrets
+
+	Context context.Context
+	Limiter *Limiter
+}
+
+// context returns the context of rendering (defaults to context.Background()).
+func (c renderContext) context() context.Context {
+	if c.Context == nil {
+		return context.Background()
+	}
+
+	return c.Context
+}
+
+func (c renderContext) manifestOptions() manifestOptions {
+	return manifestOptions{
+		Channel:        c.Channel,
+		BaseTemplate:   c.BaseTemplate,
+		ExtraData:      c.Extra,
+		MachinePresets: c.MachinePresets,
+		Resources:      c.Resources,
+		DriveSources:   c.DriveSources,
+		PlaygroundDirs: c.PlaygroundDirs,
+		RemoteBases:    c.RemoteBases,
+		Secrets:        c.Secrets,
+		Context:        c.Context,
+		Limiter:        c.Limiter,
+	}
 }
 
 // templateData holds the data passed to template executions
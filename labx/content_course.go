@@ -1,16 +1,19 @@
 package labx
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"io/fs"
 	"os"
+	"slices"
 	"strings"
 	"text/template"
 
 	"github.com/goccy/go-yaml"
 
 	"github.com/sagikazarmark/labx/core"
+	"github.com/sagikazarmark/labx/pkg/fsx"
 )
 
 // lessonTemplateData holds the data passed to lesson template executions
@@ -62,21 +65,29 @@ func renderSimpleCourse(ctx renderContext) error {
 		return err
 	}
 
-	for _, lesson := range lessons {
-		if !lesson.IsDir() {
-			continue
-		}
+	lessons = slices.DeleteFunc(lessons, func(lesson fs.DirEntry) bool { return !lesson.IsDir() })
 
-		lessonName := lesson.Name()
+	return forEach(ctx.context(), ctx.Limiter, len(lessons), func(groupCtx context.Context, i int) error {
+		lessonName := lessons[i].Name()
 		lessonPath := "lessons/" + lessonName
 
-		err = renderLesson(ctx, lessonPath, lessonName, nil)
+		lessonCtx := ctx
+		lessonCtx.Context = groupCtx
+
+		err := renderLesson(lessonCtx, lessonPath, lessonName, nil)
 		if err != nil {
 			return fmt.Errorf("render lesson %s: %w", lessonName, err)
 		}
-	}
 
-	return nil
+		return nil
+	})
+}
+
+// courseLesson is a lesson of a modular course.
+type courseLesson struct {
+	module   string
+	name     string
+	manifest *core.ContentManifest
 }
 
 // renderModularCourse handles modular courses with a modules directory
@@ -88,6 +99,9 @@ func renderModularCourse(ctx renderContext) error {
 		return err
 	}
 
+	var lessons []courseLesson
+
+	// Modules are rendered first: lessons are rendered into their directories
 	for _, module := range modules {
 		if !module.IsDir() {
 			continue
@@ -102,53 +116,78 @@ func renderModularCourse(ctx renderContext) error {
 			return fmt.Errorf("render module manifest %s: %w", moduleName, err)
 		}
 
-		manifestFile, err := fsys.Open(modulePath + "/manifest.yaml")
-		if err != nil {
-			return fmt.Errorf("read module manifest: %w", err)
-		}
-
-		decoder := yaml.NewDecoder(manifestFile)
-
-		var moduleManifest core.ContentManifest
-
-		err = decoder.Decode(&moduleManifest)
+		moduleManifest, err := loadModuleManifest(fsys, modulePath, ctx.Secrets)
 		if err != nil {
-			return fmt.Errorf("decode module manifest: %w", err)
+			return fmt.Errorf("module manifest %s: %w", moduleName, err)
 		}
-		defer manifestFile.Close()
 
 		// Process lessons within the module
-		lessons, err := fs.ReadDir(fsys, modulePath)
+		moduleLessons, err := fs.ReadDir(fsys, modulePath)
 		if err != nil {
 			return err
 		}
 
-		for _, lesson := range lessons {
+		for _, lesson := range moduleLessons {
 			if !lesson.IsDir() {
 				continue
 			}
 
-			lessonName := lesson.Name()
-			lessonPath := modulePath + "/" + lessonName
-			outputPath := moduleName + "/" + lessonName
+			lessons = append(lessons, courseLesson{
+				module:   moduleName,
+				name:     lesson.Name(),
+				manifest: moduleManifest,
+			})
+		}
+	}
 
-			err = renderLesson(ctx, lessonPath, outputPath, &moduleManifest)
-			if err != nil {
-				return fmt.Errorf(
-					"render lesson %s in module %s: %w",
-					lessonName,
-					moduleName,
-					err,
-				)
-			}
+	return forEach(ctx.context(), ctx.Limiter, len(lessons), func(groupCtx context.Context, i int) error {
+		lesson := lessons[i]
+
+		lessonCtx := ctx
+		lessonCtx.Context = groupCtx
+
+		lessonPath := "modules/" + lesson.module + "/" + lesson.name
+		outputPath := lesson.module + "/" + lesson.name
+
+		err := renderLesson(lessonCtx, lessonPath, outputPath, lesson.manifest)
+		if err != nil {
+			return fmt.Errorf(
+				"render lesson %s in module %s: %w",
+				lesson.name,
+				lesson.module,
+				err,
+			)
 		}
+
+		return nil
+	})
+}
+
+// loadModuleManifest loads the manifest of a module (passed to the templates of its lessons).
+func loadModuleManifest(fsys fs.FS, modulePath string, secrets *Secrets) (*core.ContentManifest, error) {
+	manifestFile, err := fsys.Open(modulePath + "/manifest.yaml")
+	if err != nil {
+		return nil, fmt.Errorf("read module manifest: %w", err)
 	}
+	defer manifestFile.Close()
 
-	return nil
+	var moduleManifest core.ContentManifest
+
+	err = yaml.NewDecoder(manifestFile).Decode(&moduleManifest)
+	if err != nil {
+		return nil, fmt.Errorf("decode module manifest: %w", err)
+	}
+
+	err = interpolateSecrets(secrets, &moduleManifest)
+	if err != nil {
+		return nil, err
+	}
+
+	return &moduleManifest, nil
 }
 
 // renderModuleManifest processes a module's manifest.yaml and creates 00-index.md
-func renderModuleManifest(root *os.Root, output *os.Root, modulePath, moduleName string) error {
+func renderModuleManifest(root *os.Root, output fsx.WriteFS, modulePath, moduleName string) error {
 	fsys := root.FS()
 
 	manifestPath := modulePath + "/manifest.yaml"
@@ -207,7 +246,7 @@ func renderLesson(
 	}
 
 	// Convert lesson manifest once and reuse
-	lessonManifest, err := convertContentManifest(lessonFS, ctx.Channel)
+	lessonManifest, err := convertContentManifest(lessonFS, lessonOptions(ctx.manifestOptions(), ctx.Name, outputPath))
 	if err != nil {
 		return fmt.Errorf("convert lesson manifest: %w", err)
 	}
@@ -219,7 +258,7 @@ func renderLesson(
 	}
 
 	// Create lesson-specific template instance with access to course-level templates
-	tpl, err := createLessonTemplate(ctx.Root.FS(), lessonFS, ctx.BaseTemplate)
+	tpl, err := createLessonTemplate(ctx.Root.FS(), lessonFS, ctx.BaseTemplate, ctx.Secrets)
 	if err != nil {
 		return fmt.Errorf("create lesson template: %w", err)
 	}
@@ -274,6 +313,7 @@ func renderLesson(
 func createLessonTemplate(
 	courseFS, lessonFS fs.FS,
 	baseTemplate *template.Template,
+	secrets *Secrets,
 ) (*template.Template, error) {
 	// Clone the global template to avoid conflicts
 	tpl, err := baseTemplate.Clone()
@@ -281,7 +321,7 @@ func createLessonTemplate(
 		return nil, fmt.Errorf("clone global template: %w", err)
 	}
 
-	tpl = tpl.Funcs(createTemplateFuncs(lessonFS))
+	tpl = tpl.Funcs(createTemplateFuncs(lessonFS, secrets))
 
 	// Parse course-level templates on top (excluding *.md to avoid content files)
 	coursePatterns := []string{
@@ -9,6 +9,7 @@ import (
 	"github.com/stretchr/testify/require"
 
 	"github.com/sagikazarmark/labx/labx"
+	"github.com/sagikazarmark/labx/pkg/fsx"
 )
 
 func TestTutorials(t *testing.T) {
@@ -35,6 +36,9 @@ func testGenerate(t *testing.T, content *os.Root) {
 	data, err := root.OpenRoot("_data")
 	require.NoError(t, err)
 
+	playgrounds, err := root.OpenRoot("playgrounds")
+	require.NoError(t, err)
+
 	fs.WalkDir(content.FS(), ".", func(path string, d fs.DirEntry, err error) error {
 		if !d.IsDir() || d.Name() == "." {
 			return nil
@@ -64,19 +68,35 @@ func testGenerate(t *testing.T, content *os.Root) {
 			root, err := content.OpenRoot(path)
 			require.NoError(t, err)
 
-			output, err := os.OpenRoot(t.TempDir())
-			require.NoError(t, err)
+			generate := func(t *testing.T, output fsx.WriteFS) {
+				opts := labx.GenerateOpts{
+					Root:         root,
+					Output:       output,
+					Channel:      "dev",
+					TemplateDirs: []fs.FS{templates.FS()},
+					DataDirs:     []fs.FS{data.FS()},
 
-			opts := labx.GenerateOpts{
-				Root:         root,
-				Output:       output,
-				Channel:      "dev",
-				TemplateDirs: []fs.FS{templates.FS()},
-				DataDirs:     []fs.FS{data.FS()},
+					PlaygroundDirs: []fs.FS{playgrounds.FS()},
+				}
+
+				err := labx.Generate(opts)
+				require.NoError(t, err)
 			}
 
-			err = labx.Generate(opts)
-			require.NoError(t, err)
+			t.Run("disk", func(t *testing.T) {
+				output, err := os.OpenRoot(t.TempDir())
+				require.NoError(t, err)
+
+				generate(t, fsx.NewRootFS(output))
+			})
+
+			t.Run("memory", func(t *testing.T) {
+				output := fsx.NewMemFS()
+
+				generate(t, output)
+
+				require.NotEmpty(t, output.MapFS())
+			})
 		})
 
 		return fs.SkipDir
@@ -1,8 +1,10 @@
 package labx
 
 import (
+	"context"
 	"fmt"
 	"io/fs"
+	"slices"
 	"strings"
 	"text/template"
 )
@@ -46,20 +48,22 @@ func renderTraining(ctx renderContext, tpl *template.Template) error {
 			return err
 		}
 
-		for _, unit := range units {
-			if unit.IsDir() {
-				continue
-			}
+		units = slices.DeleteFunc(units, func(unit fs.DirEntry) bool {
+			return unit.IsDir() || !strings.HasSuffix(unit.Name(), ".md")
+		})
 
-			unitName := unit.Name()
-			if !strings.HasSuffix(unitName, ".md") {
-				continue
-			}
+		err = forEach(ctx.context(), ctx.Limiter, len(units), func(_ context.Context, i int) error {
+			unitName := units[i].Name()
 
-			err = renderTrainingUnit(ctx, "units", unitName)
+			err := renderTrainingUnit(ctx, "units", unitName)
 			if err != nil {
 				return fmt.Errorf("render unit %s: %w", unitName, err)
 			}
+
+			return nil
+		})
+		if err != nil {
+			return err
 		}
 	}
 
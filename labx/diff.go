@@ -0,0 +1,245 @@
+package labx
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"path"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/goccy/go-yaml"
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+// DiffStatus describes how a file changed.
+type DiffStatus string
+
+const (
+	DiffAdded    DiffStatus = "added"
+	DiffRemoved  DiffStatus = "removed"
+	DiffModified DiffStatus = "modified"
+)
+
+// FileDiff is the difference between the existing and the generated version of a file.
+type FileDiff struct {
+	Path   string
+	Status DiffStatus
+
+	// Diff is a unified diff of the file (YAML and Markdown frontmatter are normalized first).
+	Diff string
+}
+
+// DiffOutput compares existing output (nil if there is none) with generated output.
+//
+// YAML files and the YAML frontmatter of Markdown files are compared structurally:
+// formatting and key order are not considered changes.
+func DiffOutput(existing fs.FS, generated fs.FS) ([]FileDiff, error) {
+	existingFiles, err := listFiles(existing)
+	if err != nil {
+		return nil, fmt.Errorf("list existing output: %w", err)
+	}
+
+	// Bookkeeping of --sync, not output
+	delete(existingFiles, GeneratedManifest)
+
+	generatedFiles, err := listFiles(generated)
+	if err != nil {
+		return nil, fmt.Errorf("list generated output: %w", err)
+	}
+
+	paths := maps.Clone(existingFiles)
+	maps.Copy(paths, generatedFiles)
+
+	var diffs []FileDiff
+
+	for _, name := range slices.Sorted(maps.Keys(paths)) {
+		var before, after []byte
+
+		if existingFiles[name] {
+			before, err = fs.ReadFile(existing, name)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		if generatedFiles[name] {
+			after, err = fs.ReadFile(generated, name)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		diff := FileDiff{Path: name, Status: DiffModified}
+		fromFile, toFile := "a/"+name, "b/"+name
+
+		switch {
+		case !existingFiles[name]:
+			diff.Status = DiffAdded
+			fromFile = "/dev/null"
+		case !generatedFiles[name]:
+			diff.Status = DiffRemoved
+			toFile = "/dev/null"
+		default:
+			if bytes.Equal(before, after) {
+				continue
+			}
+		}
+
+		if !isText(before) || !isText(after) {
+			diff.Diff = fmt.Sprintf("Binary files %s and %s differ\n", fromFile, toFile)
+			diffs = append(diffs, diff)
+
+			continue
+		}
+
+		beforeText := normalizeForDiff(name, before)
+		afterText := normalizeForDiff(name, after)
+
+		if diff.Status == DiffModified && beforeText == afterText {
+			continue
+		}
+
+		diff.Diff, err = difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+			A:        splitLines(beforeText),
+			B:        splitLines(afterText),
+			FromFile: fromFile,
+			ToFile:   toFile,
+			Context:  3,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("diff %s: %w", name, err)
+		}
+
+		diffs = append(diffs, diff)
+	}
+
+	return diffs, nil
+}
+
+// listFiles returns the regular files in a filesystem (nil means no files).
+func listFiles(fsys fs.FS) (map[string]bool, error) {
+	files := map[string]bool{}
+
+	if fsys == nil {
+		return files, nil
+	}
+
+	err := fs.WalkDir(fsys, ".", func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if d.Type().IsRegular() {
+			files[path] = true
+		}
+
+		return nil
+	})
+	if errors.Is(err, fs.ErrNotExist) {
+		return files, nil
+	}
+
+	return files, err
+}
+
+// splitLines splits text into lines (ending with a newline) for diffing.
+//
+// Unlike [difflib.SplitLines], it doesn't produce an extra empty line for text ending with a newline.
+func splitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+
+	lines := strings.SplitAfter(text, "\n")
+
+	if lines[len(lines)-1] == "" {
+		return lines[:len(lines)-1]
+	}
+
+	lines[len(lines)-1] += "\n"
+
+	return lines
+}
+
+func isText(data []byte) bool {
+	return utf8.Valid(data) && !bytes.ContainsRune(data, 0)
+}
+
+// normalizeForDiff formats YAML documents and Markdown frontmatter canonically (with sorted keys).
+//
+// Files that fail to parse are compared as they are.
+func normalizeForDiff(name string, data []byte) string {
+	switch path.Ext(name) {
+	case ".yaml", ".yml":
+		normalized, err := normalizeYAML(data)
+		if err != nil {
+			return string(data)
+		}
+
+		return normalized
+
+	case ".md":
+		frontmatter, body, ok := splitFrontmatter(string(data))
+		if !ok {
+			return string(data)
+		}
+
+		normalized, err := normalizeYAML([]byte(frontmatter))
+		if err != nil {
+			return string(data)
+		}
+
+		return "---\n" + normalized + "---\n" + body
+
+	default:
+		return string(data)
+	}
+}
+
+// splitFrontmatter splits a Markdown document into YAML frontmatter (delimited by --- lines) and body.
+func splitFrontmatter(content string) (string, string, bool) {
+	rest, ok := strings.CutPrefix(content, "---\n")
+	if !ok {
+		return "", "", false
+	}
+
+	// Empty frontmatter
+	if body, ok := strings.CutPrefix(rest, "---\n"); ok {
+		return "", body, true
+	}
+
+	frontmatter, body, ok := strings.Cut(rest, "\n---\n")
+	if !ok {
+		return "", "", false
+	}
+
+	return frontmatter + "\n", body, true
+}
+
+func normalizeYAML(data []byte) (string, error) {
+	var value any
+
+	err := yaml.Unmarshal(data, &value)
+	if err != nil {
+		return "", err
+	}
+
+	if value == nil {
+		return "", nil
+	}
+
+	normalized, err := yaml.MarshalWithOptions(
+		value,
+		yaml.UseLiteralStyleIfMultiline(true),
+		yaml.IndentSequence(true),
+	)
+	if err != nil {
+		return "", err
+	}
+
+	return string(normalized), nil
+}
@@ -0,0 +1,96 @@
+package labx
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiffOutput(t *testing.T) {
+	existing := fstest.MapFS{
+		"index.md":      {Data: []byte("---\ntitle:   Hello\nkind: challenge\n---\nBody\n")},
+		"manifest.yaml": {Data: []byte("kind: playground\nname: test\n")},
+		"stale.md":      {Data: []byte("stale\n")},
+		"unit.md":       {Data: []byte("---\ntitle: Unit\n---\nOld body\n")},
+
+		GeneratedManifest: {Data: []byte("index.md\n")},
+	}
+
+	generated := fstest.MapFS{
+		// Only formatting and key order differ
+		"index.md":             {Data: []byte("---\nkind: challenge\ntitle: Hello\n---\nBody\n")},
+		"manifest.yaml":        {Data: []byte("name: test\nkind: playground\n")},
+		"new.md":               {Data: []byte("new\n")},
+		"unit.md":              {Data: []byte("---\ntitle: Unit\n---\nNew body\n")},
+		"__static__/image.png": {Data: []byte{0x89, 'P', 'N', 'G', 0}},
+	}
+
+	diffs, err := DiffOutput(existing, generated)
+	require.NoError(t, err)
+
+	expected := []FileDiff{
+		{
+			Path:   "__static__/image.png",
+			Status: DiffAdded,
+			Diff:   "Binary files /dev/null and b/__static__/image.png differ\n",
+		},
+		{
+			Path:   "new.md",
+			Status: DiffAdded,
+			Diff:   "--- /dev/null\n+++ b/new.md\n@@ -0,0 +1 @@\n+new\n",
+		},
+		{
+			Path:   "stale.md",
+			Status: DiffRemoved,
+			Diff:   "--- a/stale.md\n+++ /dev/null\n@@ -1 +0,0 @@\n-stale\n",
+		},
+		{
+			Path:   "unit.md",
+			Status: DiffModified,
+			Diff:   "--- a/unit.md\n+++ b/unit.md\n@@ -1,4 +1,4 @@\n ---\n title: Unit\n ---\n-Old body\n+New body\n",
+		},
+	}
+
+	assert.Equal(t, expected, diffs)
+}
+
+func TestDiffOutput_NoExistingOutput(t *testing.T) {
+	generated := fstest.MapFS{
+		"index.md": {Data: []byte("---\n---\nBody\n")},
+	}
+
+	diffs, err := DiffOutput(nil, generated)
+	require.NoError(t, err)
+
+	require.Len(t, diffs, 1)
+	assert.Equal(t, DiffAdded, diffs[0].Status)
+
+	diffs, err = DiffOutput(generated, generated)
+	require.NoError(t, err)
+
+	assert.Empty(t, diffs)
+}
+
+func TestSplitFrontmatter(t *testing.T) {
+	tests := []struct {
+		content     string
+		frontmatter string
+		body        string
+		ok          bool
+	}{
+		{"---\ntitle: Hello\n---\nBody\n", "title: Hello\n", "Body\n", true},
+		{"---\n---\nBody\n", "", "Body\n", true},
+		{"Body\n", "", "", false},
+		{"---\ntitle: Hello\n", "", "", false},
+	}
+
+	for _, test := range tests {
+		frontmatter, body, ok := splitFrontmatter(test.content)
+
+		assert.Equal(t, test.ok, ok, test.content)
+		assert.Equal(t, test.frontmatter, frontmatter, test.content)
+		assert.Equal(t, test.body, body, test.content)
+	}
+}
@@ -0,0 +1,276 @@
+package labx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"slices"
+	"strings"
+	"text/template"
+
+	"github.com/goccy/go-yaml"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/samber/lo"
+)
+
+const driveSourceLibraryFile = "drives.yaml"
+
+// Maximum number of scheme mappings followed when resolving a drive source.
+const maxDriveSourceResolutions = 10
+
+// DriveSourceData is the data available to drive source templates.
+type DriveSourceData struct {
+	Channel string
+	Name    string
+	Extra   map[string]any
+
+	// Kind of the content (e.g. challenge) and its plural form (e.g. challenges).
+	Kind  string
+	Kinds string
+
+	// Path is the source without the scheme (scheme mappings only).
+	Path string
+}
+
+// DriveSourceResolver resolves the path of a drive source (the part after scheme://) into a new source.
+//
+// The returned source is resolved again if it has a different scheme.
+type DriveSourceResolver interface {
+	ResolveDriveSource(ctx context.Context, path string, data DriveSourceData) (string, error)
+}
+
+// DriveSourceResolverFunc is a function implementing [DriveSourceResolver].
+type DriveSourceResolverFunc func(ctx context.Context, path string, data DriveSourceData) (string, error)
+
+func (fn DriveSourceResolverFunc) ResolveDriveSource(ctx context.Context, path string, data DriveSourceData) (string, error) {
+	return fn(ctx, path, data)
+}
+
+// DriveSourceRegistry resolves drive sources using resolvers registered for their scheme.
+// Sources with unknown schemes are left as is.
+type DriveSourceRegistry struct {
+	// Funcs are available in drive source templates.
+	Funcs template.FuncMap
+
+	resolvers map[string]DriveSourceResolver
+}
+
+// NewDriveSourceRegistry creates a new [DriveSourceRegistry].
+func NewDriveSourceRegistry(funcs template.FuncMap) *DriveSourceRegistry {
+	return &DriveSourceRegistry{
+		Funcs:     funcs,
+		resolvers: map[string]DriveSourceResolver{},
+	}
+}
+
+// Register registers a resolver for a scheme (replacing any previous one).
+func (r *DriveSourceRegistry) Register(scheme string, resolver DriveSourceResolver) {
+	r.resolvers[scheme] = resolver
+}
+
+// Resolve renders a drive source as a template and resolves it until a scheme without a resolver is reached
+// (or the resolver returns a source with the same scheme).
+func (r *DriveSourceRegistry) Resolve(ctx context.Context, source string, data DriveSourceData) (string, error) {
+	source, err := r.render("source", source, data)
+	if err != nil {
+		return "", err
+	}
+
+	// Backward compatible channel placeholder
+	source = strings.ReplaceAll(source, "__CHANNEL__", data.Channel)
+
+	for range maxDriveSourceResolutions {
+		scheme, path, ok := strings.Cut(source, "://")
+		if !ok {
+			return source, nil
+		}
+
+		resolver, ok := r.resolvers[scheme]
+		if !ok {
+			return source, nil
+		}
+
+		resolved, err := resolver.ResolveDriveSource(ctx, path, data)
+		if err != nil {
+			return "", fmt.Errorf("resolve drive source %s: %w", source, err)
+		}
+
+		if resolvedScheme, _, _ := strings.Cut(resolved, "://"); resolvedScheme == scheme {
+			return resolved, nil
+		}
+
+		source = resolved
+	}
+
+	return "", fmt.Errorf("resolve drive source %s: too many scheme mappings", source)
+}
+
+func (r *DriveSourceRegistry) render(name string, text string, data DriveSourceData) (string, error) {
+	if !strings.Contains(text, "{{") {
+		return text, nil
+	}
+
+	tpl, err := template.New(name).Funcs(r.Funcs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("parse drive source template: %w", err)
+	}
+
+	var buf strings.Builder
+
+	err = tpl.Execute(&buf, data)
+	if err != nil {
+		return "", fmt.Errorf("render drive source template: %w", err)
+	}
+
+	return buf.String(), nil
+}
+
+// SchemeMapping returns a resolver that maps sources to a template (e.g. rootfs://{{ .Path }} to oci://...).
+func (r *DriveSourceRegistry) SchemeMapping(target string) DriveSourceResolver {
+	return DriveSourceResolverFunc(func(_ context.Context, path string, data DriveSourceData) (string, error) {
+		data.Path = path
+
+		return r.render("mapping", target, data)
+	})
+}
+
+// OCIDriveSourceResolver pins OCI images to their digest.
+type OCIDriveSourceResolver struct {
+	// Images of this repository (in <repo>/<kinds>/<name>:<channel> form) are used when the source is empty.
+	DefaultImageRepo string
+
+	// Images with these prefixes are not pinned (e.g. images updated by the platform).
+	NoPinPrefixes []string
+
+	// Registries configures credentials, mirrors and timeouts of digest lookups.
+	Registries RegistryConfig
+}
+
+func (r OCIDriveSourceResolver) ResolveDriveSource(ctx context.Context, path string, data DriveSourceData) (string, error) {
+	// Fallback to default source
+	if path == "" {
+		if data.Name == "" || data.Kinds == "" {
+			return "", errors.New("cannot determine the default image: the content has no name (set the channel name or slug)")
+		}
+
+		path = fmt.Sprintf("%s/%s/%s:%s", r.DefaultImageRepo, data.Kinds, data.Name, data.Channel)
+	}
+
+	for _, prefix := range r.NoPinPrefixes {
+		if strings.HasPrefix(path, prefix) {
+			return "oci://" + path, nil
+		}
+	}
+
+	ref, err := r.Registries.ParseReference(path)
+	if err != nil {
+		return "", err
+	}
+
+	// Already pinned to a digest
+	if _, ok := ref.(name.Digest); ok {
+		return "oci://" + path, nil
+	}
+
+	digest, err := r.Registries.Digest(ctx, ref)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("oci://%s@%s", ref.String(), digest.String()), nil
+}
+
+// DriveSourceConfig configures drive source resolution.
+type DriveSourceConfig struct {
+	// Schemes maps custom schemes to source templates (with .Path set to the source without the scheme).
+	Schemes map[string]string `yaml:"schemes" json:"schemes"`
+
+	// NoPin lists image prefixes that are not pinned to a digest.
+	NoPin []string `yaml:"noPin" json:"noPin"`
+
+	// Registries configures access to registries when pinning images.
+	Registries RegistryConfig `yaml:"registries" json:"registries"`
+}
+
+// Override returns the configuration with other taking precedence (no-pin prefixes of both are kept).
+func (c DriveSourceConfig) Override(other DriveSourceConfig) DriveSourceConfig {
+	schemes := maps.Clone(c.Schemes)
+	if schemes == nil {
+		schemes = map[string]string{}
+	}
+
+	maps.Copy(schemes, other.Schemes)
+
+	return DriveSourceConfig{
+		Schemes:    schemes,
+		NoPin:      lo.Uniq(append(slices.Clone(c.NoPin), other.NoPin...)),
+		Registries: c.Registries.Override(other.Registries),
+	}
+}
+
+// defaultDriveSourceConfig returns the built-in drive source configuration.
+func defaultDriveSourceConfig() DriveSourceConfig {
+	return DriveSourceConfig{
+		Schemes: map[string]string{
+			// rootfs://ubuntu-24.04 -> oci://ghcr.io/iximiuz/labs/rootfs:ubuntu-24-04
+			"rootfs": `oci://ghcr.io/iximiuz/labs/rootfs:{{ .Path | replace "." "-" }}`,
+		},
+		NoPin: []string{
+			// Official images are updated by the platform
+			"ghcr.io/iximiuz/labs/rootfs",
+		},
+		Registries: RegistryConfig{
+			Timeout: lo.ToPtr(defaultRegistryTimeout),
+			Retries: lo.ToPtr(defaultRegistryRetries),
+		},
+	}
+}
+
+// newDriveSourceRegistry creates a drive source registry from a configuration (on top of the built-in one).
+func newDriveSourceRegistry(config DriveSourceConfig, defaultImageRepo string, funcs template.FuncMap) *DriveSourceRegistry {
+	config = defaultDriveSourceConfig().Override(config)
+
+	registry := NewDriveSourceRegistry(funcs)
+
+	registry.Register("oci", OCIDriveSourceResolver{
+		DefaultImageRepo: defaultImageRepo,
+		NoPinPrefixes:    config.NoPin,
+		Registries:       config.Registries,
+	})
+
+	for scheme, target := range config.Schemes {
+		registry.Register(scheme, registry.SchemeMapping(target))
+	}
+
+	return registry
+}
+
+// loadDriveSourceConfig loads the drive source configuration from template directories, library directories and the root
+// (in increasing order of precedence).
+func loadDriveSourceConfig(rootFS fs.FS, templateFSs []fs.FS, libraryFSs []fs.FS) (DriveSourceConfig, error) {
+	var config DriveSourceConfig
+
+	for _, fsys := range append(append(append([]fs.FS{}, templateFSs...), libraryFSs...), rootFS) {
+		libraryFile, err := fsys.Open(driveSourceLibraryFile)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return DriveSourceConfig{}, err
+		}
+
+		var library DriveSourceConfig
+
+		err = yaml.NewDecoder(libraryFile).Decode(&library)
+		libraryFile.Close()
+		if err != nil {
+			return DriveSourceConfig{}, fmt.Errorf("decode drive source library: %w", err)
+		}
+
+		config = config.Override(library)
+	}
+
+	return config, nil
+}
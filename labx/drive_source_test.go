@@ -0,0 +1,191 @@
+package labx
+
+import (
+	"io/fs"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDriveSourceRegistry_Resolve(t *testing.T) {
+	sources := newDriveSourceRegistry(DriveSourceConfig{
+		Schemes: map[string]string{
+			"tools":  "oci://ghcr.io/my-org/tools/{{ .Path }}:{{ .Channel }}",
+			"ubuntu": "rootfs://ubuntu-{{ .Path }}",
+		},
+		NoPin: []string{"ghcr.io/my-org"},
+	}, defaultImageRepo, createTemplateFuncs(fstest.MapFS{}, nil))
+
+	data := DriveSourceData{
+		Channel: "dev",
+		Name:    "my-challenge",
+		Extra:   map[string]any{"image": "k8s"},
+		Kind:    "challenge",
+		Kinds:   "challenges",
+	}
+
+	tests := []struct {
+		source string
+		want   string
+	}{
+		{
+			source: "rootfs://ubuntu-24.04",
+			want:   "oci://ghcr.io/iximiuz/labs/rootfs:ubuntu-24-04",
+		},
+		{
+			source: "ubuntu://24.04",
+			want:   "oci://ghcr.io/iximiuz/labs/rootfs:ubuntu-24-04",
+		},
+		{
+			source: "tools://kubectl",
+			want:   "oci://ghcr.io/my-org/tools/kubectl:dev",
+		},
+		{
+			source: "oci://ghcr.io/my-org/{{ .Extra.image }}:__CHANNEL__",
+			want:   "oci://ghcr.io/my-org/k8s:dev",
+		},
+		{
+			source: "oci://ghcr.io/other/image@sha256:0000000000000000000000000000000000000000000000000000000000000000",
+			want:   "oci://ghcr.io/other/image@sha256:0000000000000000000000000000000000000000000000000000000000000000",
+		},
+		{
+			source: "https://example.com/{{ .Kinds }}/{{ .Name }}.img",
+			want:   "https://example.com/challenges/my-challenge.img",
+		},
+		{
+			source: "/dev/vdb",
+			want:   "/dev/vdb",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.source, func(t *testing.T) {
+			got, err := sources.Resolve(t.Context(), tt.source, data)
+			require.NoError(t, err)
+
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestDriveSourceRegistry_Resolve_Errors(t *testing.T) {
+	sources := newDriveSourceRegistry(DriveSourceConfig{
+		Schemes: map[string]string{
+			"loop": "loop://{{ .Path }}x",
+		},
+	}, defaultImageRepo, createTemplateFuncs(fstest.MapFS{}, nil))
+
+	_, err := sources.Resolve(t.Context(), "oci://ghcr.io/my-org/{{ .Extra.missing }}", DriveSourceData{Extra: map[string]any{}})
+	assert.Error(t, err)
+
+	_, err = sources.Resolve(t.Context(), "rootfs://{{ .Unknown }}", DriveSourceData{})
+	assert.Error(t, err)
+
+	// Same scheme stops resolution
+	got, err := sources.Resolve(t.Context(), "loop://a", DriveSourceData{})
+	require.NoError(t, err)
+	assert.Equal(t, "loop://ax", got)
+}
+
+func TestLoadDriveSourceConfig(t *testing.T) {
+	templateFS := fstest.MapFS{
+		"drives.yaml": &fstest.MapFile{Data: []byte("schemes:\n  tools: oci://ghcr.io/a/{{ .Path }}\nnoPin:\n  - ghcr.io/a\n")},
+	}
+
+	rootFS := fstest.MapFS{
+		"drives.yaml": &fstest.MapFile{Data: []byte("schemes:\n  tools: oci://ghcr.io/b/{{ .Path }}\nnoPin:\n  - ghcr.io/a\n  - ghcr.io/b\n")},
+	}
+
+	config, err := loadDriveSourceConfig(rootFS, []fs.FS{templateFS}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"tools": "oci://ghcr.io/b/{{ .Path }}"}, config.Schemes)
+	assert.Equal(t, []string{"ghcr.io/a", "ghcr.io/b"}, config.NoPin)
+}
+
+func TestLoadContentManifest_DefaultDriveImage(t *testing.T) {
+	playgrounds := fstest.MapFS{
+		"docker/manifest.yaml": &fstest.MapFile{Data: []byte(`kind: playground
+name: docker
+base: flexbox
+title: Docker
+channels:
+  dev:
+    name: docker-1234-5678
+playground:
+  machines:
+    - name: docker
+`)},
+	}
+
+	manifest := func(channels string) fstest.MapFS {
+		return fstest.MapFS{
+			"manifest.yaml": &fstest.MapFile{Data: []byte(`kind: challenge
+title: Challenge
+` + channels + `
+playground:
+  name: docker
+  machines:
+    - name: docker
+      drives:
+        - source: oci://
+          mount: /
+`)},
+		}
+	}
+
+	opts := manifestOptions{
+		Channel:        "dev",
+		PlaygroundDirs: []fs.FS{playgrounds},
+
+		// Don't look up digests
+		DriveSources: DriveSourceConfig{NoPin: []string{defaultImageRepo}},
+	}
+
+	tests := []struct {
+		name   string
+		fsys   fstest.MapFS
+		opts   manifestOptions
+		source string
+	}{
+		{
+			name:   "channel name",
+			fsys:   manifest("channels:\n  dev:\n    name: my-challenge-1234"),
+			opts:   opts,
+			source: "oci://ghcr.io/sagikazarmark/iximiuz-labs/challenges/my-challenge-1234:dev",
+		},
+		{
+			name:   "slug",
+			fsys:   manifest("slug: my-challenge"),
+			opts:   opts,
+			source: "oci://ghcr.io/sagikazarmark/iximiuz-labs/challenges/my-challenge:dev",
+		},
+		{
+			name:   "lesson",
+			fsys:   manifest(""),
+			opts:   lessonOptions(opts, "my-course", "module-1/lesson-1"),
+			source: "oci://ghcr.io/sagikazarmark/iximiuz-labs/courses/my-course/module-1/lesson-1:dev",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			manifest, err := loadContentManifest(tt.fsys, tt.opts)
+			require.NoError(t, err)
+
+			require.Len(t, manifest.Playground.Machines, 1)
+			assert.Equal(t, tt.source, manifest.Playground.Machines[0].Drives[0].Source)
+		})
+	}
+
+	t.Run("no name", func(t *testing.T) {
+		_, err := loadContentManifest(manifest(""), opts)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "cannot determine the default image")
+
+		_, err = loadContentManifest(manifest(""), lessonOptions(opts, "", "lesson-1"))
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "cannot determine the default image")
+	})
+}
@@ -0,0 +1,206 @@
+package labx
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/goccy/go-yaml"
+	"github.com/iximiuz/labctl/api"
+
+	"github.com/sagikazarmark/labx/extended"
+)
+
+// ExplainOpts contains options for the Explain function
+type ExplainOpts struct {
+	Root    *os.Root
+	Channel string
+
+	// Directories to load templates, extra data, machine presets and local playgrounds from (see [GenerateOpts]).
+	TemplateDirs   []fs.FS
+	DataDirs       []fs.FS
+	LibraryDirs    []fs.FS
+	PlaygroundDirs []fs.FS
+	RemoteBases    bool
+
+	// Secrets referenced by the manifest (see [GenerateOpts]).
+	Secrets *Secrets
+
+	// Context cancels processing (defaults to context.Background()).
+	Context context.Context
+}
+
+func (o ExplainOpts) loadOptions() loadOptions {
+	return loadOptions{
+		Channel:        o.Channel,
+		TemplateDirs:   o.TemplateDirs,
+		DataDirs:       o.DataDirs,
+		LibraryDirs:    o.LibraryDirs,
+		PlaygroundDirs: o.PlaygroundDirs,
+		RemoteBases:    o.RemoteBases,
+		Secrets:        o.Secrets,
+		Context:        o.Context,
+	}
+}
+
+// FieldOrigin describes which playground layers a field of the generated playground comes from.
+type FieldOrigin struct {
+	Path   string
+	Layers []string
+}
+
+func (o FieldOrigin) String() string {
+	return fmt.Sprintf("%s: %s", o.Path, strings.Join(o.Layers, " + "))
+}
+
+// Explain shows which layer of the inheritance chain each field of a playground comes from.
+func Explain(opts ExplainOpts) ([]FieldOrigin, error) {
+	fsys := opts.Root.FS()
+
+	manifestFile, err := fsys.Open("manifest.yaml")
+	if err != nil {
+		return nil, err
+	}
+	defer manifestFile.Close()
+
+	var kind manifestKind
+
+	err = yaml.NewDecoder(manifestFile).Decode(&kind)
+	if err != nil {
+		return nil, err
+	}
+
+	if kind.Kind != "playground" {
+		return nil, fmt.Errorf("explain: unsupported kind %q (only playgrounds inherit from base playgrounds)", kind.Kind)
+	}
+
+	manifestOpts, err := loadManifestOptions(fsys, opts.loadOptions())
+	if err != nil {
+		return nil, err
+	}
+
+	_, layers, err := convertPlaygroundLayers(fsys, manifestOpts)
+	if err != nil {
+		return nil, err
+	}
+
+	return explainLayers(layers), nil
+}
+
+// fieldOrigins collects field origins in the order fields are first seen.
+type fieldOrigins struct {
+	origins []FieldOrigin
+}
+
+// set records a layer as the only origin of a field.
+func (o *fieldOrigins) set(path string, layer string) {
+	i := o.index(path)
+	o.origins[i].Layers = []string{layer}
+}
+
+// add records a layer as an additional origin of a field.
+func (o *fieldOrigins) add(path string, layer string) {
+	i := o.index(path)
+	o.origins[i].Layers = append(o.origins[i].Layers, layer)
+}
+
+func (o *fieldOrigins) index(path string) int {
+	i := slices.IndexFunc(o.origins, func(origin FieldOrigin) bool { return origin.Path == path })
+	if i < 0 {
+		o.origins = append(o.origins, FieldOrigin{Path: path})
+		i = len(o.origins) - 1
+	}
+
+	return i
+}
+
+// explainLayers walks the layers from the base up, recording which layers define (or merge into) each field.
+func explainLayers(layers []playgroundLayer) []FieldOrigin {
+	var origins fieldOrigins
+
+	for _, layer := range slices.Backward(layers) {
+		name := layer.String()
+		spec := layer.Spec
+
+		for _, network := range spec.Networks {
+			origins.set("playground.networks."+network.Name, name)
+		}
+
+		for _, machine := range spec.Machines {
+			explainMachine(&origins, name, machine, layer.Merge[machine.Name].Strategies)
+		}
+
+		for _, tab := range spec.Tabs {
+			origins.set("playground.tabs."+extended.TabKey(tab), name)
+		}
+
+		for _, task := range sortedKeys(spec.InitTasks) {
+			origins.set("playground.initTasks."+task, name)
+		}
+
+		for _, value := range spec.InitConditions.Values {
+			origins.set("playground.initConditions."+value.Key, name)
+		}
+
+		if spec.RegistryAuth != "" {
+			origins.set("playground.registryAuth", name)
+		}
+
+		if len(spec.PortForwards) > 0 {
+			origins.set("playground.portForwards", name)
+		}
+	}
+
+	return origins.origins
+}
+
+func explainMachine(
+	origins *fieldOrigins,
+	layer string,
+	machine api.PlaygroundMachine,
+	strategies extended.MachineMergeStrategies,
+) {
+	path := "playground.machines." + machine.Name
+
+	exists := slices.ContainsFunc(origins.origins, func(origin FieldOrigin) bool { return origin.Path == path })
+	if exists {
+		origins.add(path, layer)
+	} else {
+		origins.set(path, layer)
+	}
+
+	s := strategies
+	d := extended.DefaultMachineMergeStrategies
+
+	fields := []struct {
+		name     string
+		defined  bool
+		strategy extended.MergeStrategy
+	}{
+		{"users", len(machine.Users) > 0, cmp.Or(s.Users, d.Users)},
+		{"kernel", machine.Kernel != nil, cmp.Or(s.Kernel, d.Kernel)},
+		{"drives", len(machine.Drives) > 0, cmp.Or(s.Drives, d.Drives)},
+		{"network", machine.Network != nil, cmp.Or(s.Network, d.Network)},
+		{"resources", machine.Resources != nil, cmp.Or(s.Resources, d.Resources)},
+		{"startupFiles", len(machine.StartupFiles) > 0, cmp.Or(s.StartupFiles, d.StartupFiles)},
+		{"noSSH", machine.NoSSH, cmp.Or(s.NoSSH, d.NoSSH)},
+	}
+
+	for _, field := range fields {
+		if !field.defined {
+			continue
+		}
+
+		fieldPath := path + "." + field.name
+
+		if exists && field.strategy != extended.MergeReplace {
+			origins.add(fieldPath, layer)
+		} else {
+			origins.set(fieldPath, layer)
+		}
+	}
+}
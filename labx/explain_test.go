@@ -0,0 +1,187 @@
+package labx
+
+import (
+	"io/fs"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConvertPlaygroundLayers(t *testing.T) {
+	playgrounds := fstest.MapFS{
+		"base/manifest.yaml": &fstest.MapFile{Data: []byte(`kind: playground
+name: base
+base: flexbox
+title: Base
+channels:
+  dev:
+    name: base-1234
+playground:
+  networks:
+    - name: local
+      subnet: 172.16.0.0/24
+  machines:
+    - name: dev
+      users:
+        - name: laborant
+          default: true
+      resources:
+        cpuCount: 2
+        ramSize: 4Gi
+  tabs:
+    - kind: terminal
+      name: dev
+      machine: dev
+  initTasks:
+    init_base:
+      machine: dev
+      user: root
+      run: "true"
+`)},
+	}
+
+	fsys := fstest.MapFS{
+		"manifest.yaml": &fstest.MapFile{Data: []byte(`kind: playground
+name: child
+base: base
+title: Child
+channels:
+  dev:
+    name: child-5678
+playground:
+  machines:
+    - name: dev
+      resources:
+        ramSize: 8Gi
+      merge:
+        resources: merge
+    - name: db
+  tabs:
+    - kind: terminal
+      name: db
+      machine: db
+  initTasks:
+    init_all:
+      machine: "*"
+      user: root
+      run: "true"
+`)},
+	}
+
+	manifest, layers, err := convertPlaygroundLayers(fsys, manifestOptions{
+		Channel:        "dev",
+		PlaygroundDirs: []fs.FS{playgrounds},
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, "child-5678", manifest.Name)
+	assert.Equal(t, "flexbox", manifest.Base)
+
+	spec := manifest.Playground
+
+	require.Len(t, spec.Networks, 1)
+	require.Len(t, spec.Machines, 2)
+	assert.Equal(t, "dev", spec.Machines[0].Name)
+	assert.Equal(t, 2, spec.Machines[0].Resources.CPUCount)
+	assert.Equal(t, "8Gi", spec.Machines[0].Resources.RAMSize)
+	assert.Len(t, spec.Machines[0].Users, 1)
+	assert.Equal(t, "db", spec.Machines[1].Name)
+	assert.Len(t, spec.Tabs, 2)
+	assert.Contains(t, spec.InitTasks, "init_base")
+	assert.Contains(t, spec.InitTasks, "init_all_dev")
+	assert.Contains(t, spec.InitTasks, "init_all_db")
+
+	expected := []FieldOrigin{
+		{Path: "playground.networks.local", Layers: []string{"base (local)"}},
+		{Path: "playground.machines.dev", Layers: []string{"base (local)", "child (local)"}},
+		{Path: "playground.machines.dev.users", Layers: []string{"base (local)"}},
+		{Path: "playground.machines.dev.resources", Layers: []string{"base (local)", "child (local)"}},
+		{Path: "playground.tabs.dev", Layers: []string{"base (local)"}},
+		{Path: "playground.initTasks.init_base", Layers: []string{"base (local)"}},
+		{Path: "playground.machines.db", Layers: []string{"child (local)"}},
+		{Path: "playground.tabs.db", Layers: []string{"child (local)"}},
+		{Path: "playground.initTasks.init_all_db", Layers: []string{"child (local)"}},
+		{Path: "playground.initTasks.init_all_dev", Layers: []string{"child (local)"}},
+	}
+
+	assert.Equal(t, expected, explainLayers(layers))
+}
+
+func TestConvertPlaygroundLayers_Cycle(t *testing.T) {
+	playgrounds := fstest.MapFS{
+		"a/manifest.yaml": &fstest.MapFile{Data: []byte(`kind: playground
+name: a
+base: b
+channels:
+  dev:
+    name: a-1234
+`)},
+		"b/manifest.yaml": &fstest.MapFile{Data: []byte(`kind: playground
+name: b
+base: a
+channels:
+  dev:
+    name: b-1234
+`)},
+	}
+
+	fsys, err := fs.Sub(playgrounds, "a")
+	require.NoError(t, err)
+
+	_, _, err = convertPlaygroundLayers(fsys, manifestOptions{
+		Channel:        "dev",
+		PlaygroundDirs: []fs.FS{playgrounds},
+	})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "playground inheritance cycle: a -> b -> a")
+}
+
+func TestConvertPlaygroundLayers_RemoteBase(t *testing.T) {
+	playgrounds := fstest.MapFS{
+		"base/manifest.yaml": &fstest.MapFile{Data: []byte(`kind: playground
+name: base
+base: k3s
+channels:
+  dev:
+    name: base-1234
+playground:
+  machines:
+    - name: dev
+      resources:
+        cpuCount: 2
+`)},
+	}
+
+	fsys := fstest.MapFS{
+		"manifest.yaml": &fstest.MapFile{Data: []byte(`kind: playground
+name: child
+base: base
+channels:
+  dev:
+    name: child-5678
+playground:
+  machines:
+    - name: db
+`)},
+	}
+
+	// Published base playgrounds are not fetched (labctl is not called)
+	manifest, layers, err := convertPlaygroundLayers(fsys, manifestOptions{
+		Channel:        "dev",
+		PlaygroundDirs: []fs.FS{playgrounds},
+	})
+	require.NoError(t, err)
+
+	// The local base playground is flattened: the generated manifest uses its base instead
+	assert.Equal(t, "k3s", manifest.Base)
+
+	require.Len(t, manifest.Playground.Machines, 2)
+	assert.Equal(t, "dev", manifest.Playground.Machines[0].Name)
+	assert.Equal(t, "db", manifest.Playground.Machines[1].Name)
+
+	require.Len(t, layers, 2)
+	assert.Equal(t, "child (local)", layers[0].String())
+	assert.Equal(t, "base (local)", layers[1].String())
+}
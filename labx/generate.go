@@ -1,12 +1,17 @@
 package labx
 
 import (
+	"context"
 	"fmt"
 	"io/fs"
 	"os"
 	"text/template"
 
 	"github.com/goccy/go-yaml"
+	"github.com/iximiuz/labctl/content"
+
+	"github.com/sagikazarmark/labx/extended"
+	"github.com/sagikazarmark/labx/pkg/fsx"
 )
 
 // manifestKind represents a minimal manifest structure to determine routing
@@ -17,23 +22,215 @@ type manifestKind struct {
 // GenerateOpts contains options for the Generate function
 type GenerateOpts struct {
 	Root         *os.Root
-	Output       *os.Root
+	Output       fsx.WriteFS
 	Channel      string
 	TemplateDirs []fs.FS
 	DataDirs     []fs.FS
+
+	// Directories to load shared libraries (e.g. machines.yaml) from.
+	LibraryDirs []fs.FS
+
+	// Directories containing local playgrounds (resolved before the ones published on the server).
+	PlaygroundDirs []fs.FS
+
+	// RemoteBases fetches base playgrounds that are not found locally from the server to inherit their spec.
+	// Otherwise they are inherited on the server (without fetching them).
+	RemoteBases bool
+
+	// Secrets referenced by ${env:NAME} and {{ secret "NAME" }}.
+	// Defaults to the process environment.
+	Secrets *Secrets
+
+	// Context cancels generation (defaults to context.Background()).
+	Context context.Context
+
+	// Maximum number of lessons, units, machines and drives processed at the same time (defaults to 8).
+	Concurrency int
 }
 
 // GenerateContext contains the parsed state for content generation
 type GenerateContext struct {
 	Root         *os.Root
-	Output       *os.Root
+	Output       fsx.WriteFS
 	Channel      string
 	BaseTemplate *template.Template
 	ExtraData    map[string]any
+
+	MachinePresets map[string]extended.PlaygroundMachine
+	Resources      extended.ResourceLibrary
+	DriveSources   DriveSourceConfig
+	PlaygroundDirs []fs.FS
+	RemoteBases    bool
+	Secrets        *Secrets
+
+	Context context.Context
+	Limiter *Limiter
+}
+
+func (c GenerateContext) manifestOptions() manifestOptions {
+	return manifestOptions{
+		Channel:        c.Channel,
+		BaseTemplate:   c.BaseTemplate,
+		ExtraData:      c.ExtraData,
+		MachinePresets: c.MachinePresets,
+		Resources:      c.Resources,
+		DriveSources:   c.DriveSources,
+		PlaygroundDirs: c.PlaygroundDirs,
+		RemoteBases:    c.RemoteBases,
+		Secrets:        c.Secrets,
+		Context:        c.Context,
+		Limiter:        c.Limiter,
+	}
+}
+
+// manifestOptions contains the shared state for loading and processing manifests
+type manifestOptions struct {
+	Channel        string
+	BaseTemplate   *template.Template
+	ExtraData      map[string]any
+	MachinePresets map[string]extended.PlaygroundMachine
+	PlaygroundDirs []fs.FS
+
+	// RemoteBases fetches base playgrounds published on the server (see [GenerateOpts.RemoteBases]).
+	RemoteBases bool
+
+	// Resources contains the platform limits (zero limits are not checked) and resource presets.
+	Resources extended.ResourceLibrary
+
+	// DriveSources configures custom drive source schemes and images that are not pinned.
+	DriveSources DriveSourceConfig
+
+	// Images verifies drive images (nil disables verification).
+	Images *ImageVerifier
+
+	// Content the default drive images belong to when it's not the manifest itself (e.g. the course of a lesson).
+	ContentKind content.ContentKind
+	ContentName string
+
+	// Secrets used for interpolation (nil disables interpolation).
+	Secrets *Secrets
+
+	// BaseChain contains the playgrounds being processed (used to detect inheritance cycles).
+	BaseChain []string
+
+	// Context cancels processing (see [manifestOptions.ctx]).
+	Context context.Context
+
+	// Limiter bounds the number of lessons, units, machines and drives processed at the same time
+	// (shared by every level, so nested limits don't multiply).
+	Limiter *Limiter
+}
+
+// ctx returns the context of processing (defaults to context.Background()).
+func (o manifestOptions) ctx() context.Context {
+	if o.Context == nil {
+		return context.Background()
+	}
+
+	return o.Context
+}
+
+// interpolateSecrets replaces ${env:NAME} references in a decoded manifest.
+func interpolateSecrets(secrets *Secrets, manifest any) error {
+	if secrets == nil {
+		return nil
+	}
+
+	err := secrets.InterpolateAll(manifest)
+	if err != nil {
+		return fmt.Errorf("interpolate manifest: %w", err)
+	}
+
+	return nil
+}
+
+func (o GenerateOpts) loadOptions() loadOptions {
+	return loadOptions{
+		Channel:        o.Channel,
+		TemplateDirs:   o.TemplateDirs,
+		DataDirs:       o.DataDirs,
+		LibraryDirs:    o.LibraryDirs,
+		PlaygroundDirs: o.PlaygroundDirs,
+		RemoteBases:    o.RemoteBases,
+		Secrets:        o.Secrets,
+		Context:        o.Context,
+		Concurrency:    o.Concurrency,
+	}
+}
+
+// loadOptions contains the inputs shared by every entry point (Generate, Explain, Validate and RunHarness).
+type loadOptions struct {
+	Channel        string
+	TemplateDirs   []fs.FS
+	DataDirs       []fs.FS
+	LibraryDirs    []fs.FS
+	PlaygroundDirs []fs.FS
+	RemoteBases    bool
+	Secrets        *Secrets
+	Context        context.Context
+	Concurrency    int
+}
+
+// loadManifestOptions loads the shared state of processing manifests in fsys:
+// global templates, extra data and libraries (machine presets, resources and drive sources).
+func loadManifestOptions(fsys fs.FS, opts loadOptions) (manifestOptions, error) {
+	// Parse global templates
+	baseTemplate, err := createBaseTemplate(fsys, opts.TemplateDirs, opts.Secrets)
+	if err != nil {
+		return manifestOptions{}, fmt.Errorf("create global templates: %w", err)
+	}
+
+	// Load extra template data once
+	extraData, err := loadAllExtraData(fsys, opts.DataDirs)
+	if err != nil {
+		return manifestOptions{}, fmt.Errorf("load extra template data: %w", err)
+	}
+
+	// Load machine presets once
+	machinePresets, err := loadMachinePresets(fsys, opts.TemplateDirs, opts.LibraryDirs)
+	if err != nil {
+		return manifestOptions{}, fmt.Errorf("load machine presets: %w", err)
+	}
+
+	// Load platform limits and resource presets once
+	resources, err := loadResourceLibrary(fsys, opts.TemplateDirs, opts.LibraryDirs)
+	if err != nil {
+		return manifestOptions{}, fmt.Errorf("load resource library: %w", err)
+	}
+
+	// Load drive source schemes once
+	driveSources, err := loadDriveSourceConfig(fsys, opts.TemplateDirs, opts.LibraryDirs)
+	if err != nil {
+		return manifestOptions{}, fmt.Errorf("load drive source library: %w", err)
+	}
+
+	// Registry credentials may reference secrets
+	err = interpolateSecrets(opts.Secrets, &driveSources)
+	if err != nil {
+		return manifestOptions{}, fmt.Errorf("load drive source library: %w", err)
+	}
+
+	return manifestOptions{
+		Channel:        opts.Channel,
+		BaseTemplate:   baseTemplate,
+		ExtraData:      extraData,
+		MachinePresets: machinePresets,
+		Resources:      resources,
+		DriveSources:   driveSources,
+		PlaygroundDirs: opts.PlaygroundDirs,
+		RemoteBases:    opts.RemoteBases,
+		Secrets:        opts.Secrets,
+		Context:        opts.Context,
+		Limiter:        NewLimiter(opts.Concurrency),
+	}, nil
 }
 
 // Generate processes content based on the manifest kind, routing to appropriate handlers
 func Generate(opts GenerateOpts) error {
+	if opts.Context == nil {
+		opts.Context = context.Background()
+	}
+
 	// Read and parse just the kind field from manifest.yaml
 	manifestFile, err := opts.Root.FS().Open("manifest.yaml")
 	if err != nil {
@@ -49,25 +246,34 @@ func Generate(opts GenerateOpts) error {
 		return err
 	}
 
-	// Parse global templates
-	baseTemplate, err := createBaseTemplate(opts.Root.FS(), opts.TemplateDirs)
-	if err != nil {
-		return fmt.Errorf("create global templates: %w", err)
+	// Secrets default to the process environment
+	if opts.Secrets == nil {
+		opts.Secrets, err = NewSecrets(nil)
+		if err != nil {
+			return err
+		}
 	}
 
-	// Load extra template data once
-	extraData, err := loadAllExtraData(opts.Root.FS(), opts.DataDirs)
+	manifestOpts, err := loadManifestOptions(opts.Root.FS(), opts.loadOptions())
 	if err != nil {
-		return fmt.Errorf("load extra template data: %w", err)
+		return err
 	}
 
 	// Create the context with shared state
 	ctx := GenerateContext{
-		Root:         opts.Root,
-		Output:       opts.Output,
-		Channel:      opts.Channel,
-		BaseTemplate: baseTemplate,
-		ExtraData:    extraData,
+		Root:           opts.Root,
+		Output:         opts.Output,
+		Channel:        manifestOpts.Channel,
+		BaseTemplate:   manifestOpts.BaseTemplate,
+		ExtraData:      manifestOpts.ExtraData,
+		MachinePresets: manifestOpts.MachinePresets,
+		Resources:      manifestOpts.Resources,
+		DriveSources:   manifestOpts.DriveSources,
+		PlaygroundDirs: manifestOpts.PlaygroundDirs,
+		RemoteBases:    manifestOpts.RemoteBases,
+		Secrets:        manifestOpts.Secrets,
+		Context:        manifestOpts.Context,
+		Limiter:        manifestOpts.Limiter,
 	}
 
 	// Route based on kind
@@ -0,0 +1,81 @@
+package labx
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sagikazarmark/labx/pkg/fsx"
+)
+
+func TestGenerate_CourseSlug(t *testing.T) {
+	dir := t.TempDir()
+
+	files := map[string]string{
+		"manifest.yaml": `kind: course
+title: Course
+slug: my-course
+channels:
+  dev: {}
+`,
+		"index.md":                  "Welcome to the course.\n",
+		"lessons/lesson-1/index.md": "Welcome to the lesson.\n",
+
+		// Don't look up digests
+		"drives.yaml": "noPin:\n  - " + defaultImageRepo + "\n",
+		"lessons/lesson-1/manifest.yaml": `kind: lesson
+title: Lesson
+playground:
+  name: docker
+  machines:
+    - name: dev
+      drives:
+        - source: oci://
+          mount: /
+`,
+	}
+
+	for name, content := range files {
+		require.NoError(t, os.MkdirAll(filepath.Join(dir, filepath.Dir(name)), 0o755))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644))
+	}
+
+	root, err := os.OpenRoot(dir)
+	require.NoError(t, err)
+	defer root.Close()
+
+	playgrounds := fstest.MapFS{
+		"docker/manifest.yaml": &fstest.MapFile{Data: []byte(`kind: playground
+name: docker
+base: flexbox
+title: Docker
+channels:
+  dev:
+    name: docker-1234-5678
+playground:
+  machines:
+    - name: dev
+`)},
+	}
+
+	output := fsx.NewMemFS()
+
+	err = Generate(GenerateOpts{
+		Root:           root,
+		Output:         output,
+		Channel:        "dev",
+		PlaygroundDirs: []fs.FS{playgrounds},
+		Context:        t.Context(),
+	})
+	require.NoError(t, err)
+
+	lesson, err := fs.ReadFile(output.MapFS(), "lesson-1/00-index.md")
+	require.NoError(t, err)
+
+	assert.Contains(t, string(lesson), "oci://"+defaultImageRepo+"/courses/my-course/lesson-1:dev")
+}
@@ -0,0 +1,625 @@
+package labx
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/iximiuz/labctl/api"
+
+	"github.com/sagikazarmark/labx/core"
+)
+
+const defaultSolutionScript = "solution/solve.sh"
+
+const defaultHarnessTimeout = 60 * time.Second
+
+const defaultPollInterval = time.Second
+
+// HarnessOpts contains options for the RunHarness function
+type HarnessOpts struct {
+	Root    *os.Root
+	Channel string
+
+	// Directories to load templates, extra data and machine presets from (see [GenerateOpts]).
+	TemplateDirs []fs.FS
+	DataDirs     []fs.FS
+	LibraryDirs  []fs.FS
+
+	// Directories containing local playgrounds (see [GenerateOpts]).
+	PlaygroundDirs []fs.FS
+	RemoteBases    bool
+
+	// Secrets referenced by the manifest (see [GenerateOpts]).
+	Secrets *Secrets
+
+	// Local stand-in for the playground machines.
+	StandIn StandIn
+
+	// Image overrides for machines (machine name -> image reference).
+	Images map[string]string
+
+	// Solution script (relative to the root). Defaults to solution/solve.sh if it exists.
+	Solution string
+
+	// Machine and user to run the solution as.
+	// Defaults to the first machine and its default user.
+	SolutionMachine string
+	SolutionUser    string
+
+	// Timeout for tasks that don't specify timeout_seconds.
+	DefaultTimeout time.Duration
+
+	// Interval between attempts of verification tasks (retried until they pass or time out).
+	PollInterval time.Duration
+}
+
+func (o HarnessOpts) loadOptions() loadOptions {
+	return loadOptions{
+		Channel:        o.Channel,
+		TemplateDirs:   o.TemplateDirs,
+		DataDirs:       o.DataDirs,
+		LibraryDirs:    o.LibraryDirs,
+		PlaygroundDirs: o.PlaygroundDirs,
+		RemoteBases:    o.RemoteBases,
+		Secrets:        o.Secrets,
+	}
+}
+
+// StandIn runs scripts on a local stand-in for playground machines.
+type StandIn interface {
+	// Start prepares the stand-in for a machine.
+	Start(ctx context.Context, machine string, image string) error
+
+	// Exec runs a script on a machine as a user and returns its combined output.
+	Exec(ctx context.Context, machine string, user string, env []string, script string) ([]byte, error)
+
+	// Stop tears down every machine started by the stand-in.
+	Stop(ctx context.Context) error
+}
+
+// TaskStatus is the outcome of running a task.
+type TaskStatus string
+
+const (
+	TaskPassed  TaskStatus = "PASS"
+	TaskFailed  TaskStatus = "FAIL"
+	TaskHung    TaskStatus = "HANG"
+	TaskSkipped TaskStatus = "SKIP"
+)
+
+// TaskResult is the outcome of a single task (or the solution script).
+type TaskResult struct {
+	Name     string
+	Machine  string
+	User     string
+	Init     bool
+	Status   TaskStatus
+	Duration time.Duration
+	Output   string
+
+	// Reason explains why a task did not pass.
+	Reason string
+}
+
+// HarnessReport contains the results of a harness run in execution order.
+type HarnessReport struct {
+	Results []TaskResult
+}
+
+// Passed reports whether every task passed.
+func (r HarnessReport) Passed() bool {
+	return !slices.ContainsFunc(r.Results, func(result TaskResult) bool {
+		return result.Status != TaskPassed
+	})
+}
+
+// harnessTask is a task from either the content or the (base) playground.
+type harnessTask struct {
+	name    string
+	machine string
+	user    string
+	init    bool
+	needs   []string
+	env     []string
+	run     string
+	timeout time.Duration
+
+	// retry until the task passes or times out (verification tasks)
+	retry bool
+}
+
+// RunHarness runs the init tasks, the solution and the verification tasks on a local stand-in.
+func RunHarness(ctx context.Context, opts HarnessOpts) (HarnessReport, error) {
+	return runHarness(ctx, opts.Root.FS(), opts)
+}
+
+func runHarness(ctx context.Context, fsys fs.FS, opts HarnessOpts) (HarnessReport, error) {
+	loadOpts := opts.loadOptions()
+	loadOpts.Context = ctx
+
+	manifestOpts, err := loadManifestOptions(fsys, loadOpts)
+	if err != nil {
+		return HarnessReport{}, err
+	}
+
+	extendedManifest, err := loadContentManifest(fsys, manifestOpts)
+	if err != nil {
+		return HarnessReport{}, err
+	}
+
+	manifest := extendedManifest.Convert()
+
+	machines := manifest.Playground.Machines
+	if len(machines) == 0 {
+		machines = contentMachines(extendedManifest.Playground.Base.Machines)
+	}
+
+	if len(machines) == 0 {
+		return HarnessReport{}, errors.New("no machines to run tasks on")
+	}
+
+	defaultTimeout := cmp.Or(opts.DefaultTimeout, defaultHarnessTimeout)
+
+	tasks := defaultTaskTargets(
+		harnessTasks(extendedManifest.Playground.Base.InitTasks, manifest.Tasks, defaultTimeout),
+		machines,
+	)
+
+	pollInterval := cmp.Or(opts.PollInterval, defaultPollInterval)
+
+	solution, err := solutionScript(fsys, opts.Solution)
+	if err != nil {
+		return HarnessReport{}, err
+	}
+
+	defer opts.StandIn.Stop(context.WithoutCancel(ctx))
+
+	for _, machine := range machines {
+		image, ok := opts.Images[machine.Name]
+		if !ok {
+			image = machineImage(machine)
+		}
+
+		if image == "" {
+			return HarnessReport{}, fmt.Errorf("no image found for machine %s", machine.Name)
+		}
+
+		err := opts.StandIn.Start(ctx, machine.Name, image)
+		if err != nil {
+			return HarnessReport{}, fmt.Errorf("start machine %s: %w", machine.Name, err)
+		}
+	}
+
+	var report HarnessReport
+
+	statuses := map[string]TaskStatus{}
+
+	runTasks := func(tasks []harnessTask) {
+		for _, task := range tasks {
+			result := runHarnessTask(ctx, opts.StandIn, task, statuses, pollInterval)
+			statuses[task.name] = result.Status
+
+			report.Results = append(report.Results, result)
+		}
+	}
+
+	initTasks, verifyTasks := splitInitTasks(tasks)
+
+	orderedInitTasks, err := orderTasks(initTasks)
+	if err != nil {
+		return HarnessReport{}, err
+	}
+
+	orderedVerifyTasks, err := orderTasks(verifyTasks)
+	if err != nil {
+		return HarnessReport{}, err
+	}
+
+	runTasks(orderedInitTasks)
+
+	if solution != "" {
+		machine := cmp.Or(opts.SolutionMachine, machines[0].Name)
+		user := cmp.Or(opts.SolutionUser, defaultUser(machines, machine))
+
+		runTasks([]harnessTask{{
+			name:    "solution",
+			machine: machine,
+			user:    user,
+			run:     solution,
+			timeout: defaultTimeout,
+		}})
+	}
+
+	runTasks(orderedVerifyTasks)
+
+	return report, nil
+}
+
+// runHarnessTask runs a task once its dependencies passed.
+//
+// Verification tasks are retried every pollInterval until they pass or time out (like on the platform).
+func runHarnessTask(
+	ctx context.Context,
+	standIn StandIn,
+	task harnessTask,
+	statuses map[string]TaskStatus,
+	pollInterval time.Duration,
+) TaskResult {
+	result := TaskResult{
+		Name:    task.name,
+		Machine: task.machine,
+		User:    task.user,
+		Init:    task.init,
+	}
+
+	for _, need := range task.needs {
+		if status := statuses[need]; status != TaskPassed {
+			result.Status = TaskSkipped
+			result.Reason = fmt.Sprintf("dependency %s did not pass", need)
+
+			return result
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, task.timeout)
+	defer cancel()
+
+	start := time.Now()
+
+	output, err := standIn.Exec(ctx, task.machine, task.user, task.env, task.run)
+	hung := errors.Is(ctx.Err(), context.DeadlineExceeded)
+
+	for err != nil && !hung && task.retry && sleep(ctx, pollInterval) {
+		retryOutput, retryErr := standIn.Exec(ctx, task.machine, task.user, task.env, task.run)
+
+		// The attempt was cut short by the timeout: report the previous one
+		if ctx.Err() != nil {
+			break
+		}
+
+		output, err = retryOutput, retryErr
+	}
+
+	result.Duration = time.Since(start)
+	result.Output = string(output)
+
+	switch {
+	case hung:
+		result.Status = TaskHung
+		result.Reason = fmt.Sprintf("timed out after %s", task.timeout)
+
+	case err != nil && task.retry:
+		result.Status = TaskFailed
+		result.Reason = fmt.Sprintf("did not pass within %s: %s", task.timeout, err)
+
+	case err != nil:
+		result.Status = TaskFailed
+		result.Reason = err.Error()
+
+	default:
+		result.Status = TaskPassed
+	}
+
+	return result
+}
+
+// sleep waits for d (it returns false if the context is done first).
+func sleep(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+func harnessTasks(
+	initTasks map[string]api.InitTask,
+	tasks map[string]core.Task,
+	defaultTimeout time.Duration,
+) []harnessTask {
+	timeout := func(seconds int) time.Duration {
+		if seconds > 0 {
+			return time.Duration(seconds) * time.Second
+		}
+
+		return defaultTimeout
+	}
+
+	var result []harnessTask
+
+	for name, task := range initTasks {
+		result = append(result, harnessTask{
+			name:    name,
+			machine: task.Machine,
+			user:    task.User,
+			init:    true,
+			needs:   task.Needs,
+			run:     task.Run,
+			timeout: timeout(task.TimeoutSeconds),
+		})
+	}
+
+	for name, task := range tasks {
+		result = append(result, harnessTask{
+			name:    name,
+			machine: task.Machine,
+			user:    task.User,
+			init:    task.Init,
+			needs:   task.Needs,
+			env:     task.Env,
+			run:     task.Run,
+			timeout: timeout(task.TimeoutSeconds),
+			retry:   !task.Init,
+		})
+	}
+
+	return result
+}
+
+// defaultTaskTargets runs tasks without a machine (or user) on the first machine (as the default user of the machine).
+func defaultTaskTargets(tasks []harnessTask, machines []core.ContentPlaygroundMachine) []harnessTask {
+	for i, task := range tasks {
+		tasks[i].machine = cmp.Or(task.machine, machines[0].Name)
+		tasks[i].user = cmp.Or(task.user, defaultUser(machines, tasks[i].machine))
+	}
+
+	return tasks
+}
+
+func splitInitTasks(tasks []harnessTask) ([]harnessTask, []harnessTask) {
+	var initTasks, verifyTasks []harnessTask
+
+	for _, task := range tasks {
+		if task.init {
+			initTasks = append(initTasks, task)
+		} else {
+			verifyTasks = append(verifyTasks, task)
+		}
+	}
+
+	return initTasks, verifyTasks
+}
+
+// orderTasks sorts tasks in dependency order (alphabetically among independent tasks).
+//
+// Dependencies outside the list are assumed to have run already.
+func orderTasks(tasks []harnessTask) ([]harnessTask, error) {
+	byName := map[string]harnessTask{}
+	for _, task := range tasks {
+		byName[task.name] = task
+	}
+
+	names := sortedKeys(byName)
+
+	var ordered []harnessTask
+
+	done := map[string]bool{}
+
+	for len(ordered) < len(names) {
+		progress := false
+
+		for _, name := range names {
+			if done[name] {
+				continue
+			}
+
+			ready := !slices.ContainsFunc(byName[name].needs, func(need string) bool {
+				_, inList := byName[need]
+
+				return inList && !done[need]
+			})
+
+			if !ready {
+				continue
+			}
+
+			ordered = append(ordered, byName[name])
+			done[name] = true
+			progress = true
+
+			// Restart from the beginning to keep the order stable
+			break
+		}
+
+		if !progress {
+			return nil, errors.New("dependency cycle between tasks")
+		}
+	}
+
+	return ordered, nil
+}
+
+func solutionScript(fsys fs.FS, solution string) (string, error) {
+	if solution == "" {
+		exists, err := fileExists(fsys, defaultSolutionScript)
+		if err != nil {
+			return "", err
+		}
+
+		if !exists {
+			return "", nil
+		}
+
+		solution = defaultSolutionScript
+	}
+
+	script, err := fs.ReadFile(fsys, solution)
+	if err != nil {
+		return "", fmt.Errorf("read solution: %w", err)
+	}
+
+	return string(script), nil
+}
+
+// machineImage returns the image of the first drive of a machine.
+func machineImage(machine core.ContentPlaygroundMachine) string {
+	for _, drive := range machine.Drives {
+		for _, scheme := range []string{"oci://", "docker-image://"} {
+			if image, ok := strings.CutPrefix(drive.Source, scheme); ok && image != "" {
+				return image
+			}
+		}
+	}
+
+	return ""
+}
+
+func defaultUser(machines []core.ContentPlaygroundMachine, name string) string {
+	for _, machine := range machines {
+		if machine.Name != name {
+			continue
+		}
+
+		for _, user := range machine.Users {
+			if user.Default {
+				return user.Name
+			}
+		}
+	}
+
+	return "root"
+}
+
+func contentMachines(machines []api.PlaygroundMachine) []core.ContentPlaygroundMachine {
+	result := make([]core.ContentPlaygroundMachine, 0, len(machines))
+
+	for _, machine := range machines {
+		result = append(result, core.ContentPlaygroundMachine{
+			Name:   machine.Name,
+			Users:  machine.Users,
+			Drives: machine.Drives,
+		})
+	}
+
+	return result
+}
+
+// ContainerStandIn runs machines as Docker or Podman containers.
+type ContainerStandIn struct {
+	// Container engine binary (docker or podman).
+	Engine string
+
+	containers map[string]string
+}
+
+// NewContainerStandIn returns a new [ContainerStandIn].
+func NewContainerStandIn(engine string) *ContainerStandIn {
+	return &ContainerStandIn{
+		Engine:     engine,
+		containers: map[string]string{},
+	}
+}
+
+// Implements [StandIn].
+func (s *ContainerStandIn) Start(ctx context.Context, machine string, image string) error {
+	output, err := runCommand(ctx, nil, s.Engine,
+		"run", "--detach", "--rm", "--privileged",
+		"--hostname", machine,
+		"--entrypoint", "sleep",
+		image, "infinity",
+	)
+	if err != nil {
+		return fmt.Errorf("%w: %s", err, output)
+	}
+
+	s.containers[machine] = strings.TrimSpace(string(output))
+
+	return nil
+}
+
+// Implements [StandIn].
+func (s *ContainerStandIn) Exec(
+	ctx context.Context,
+	machine string,
+	user string,
+	env []string,
+	script string,
+) ([]byte, error) {
+	container, ok := s.containers[machine]
+	if !ok {
+		return nil, fmt.Errorf("unknown machine: %s", machine)
+	}
+
+	args := []string{"exec", "--interactive", "--user", user}
+	for _, e := range env {
+		args = append(args, "--env", e)
+	}
+
+	args = append(args, container, "bash", "-s")
+
+	return runCommand(ctx, strings.NewReader(script), s.Engine, args...)
+}
+
+// Implements [StandIn].
+func (s *ContainerStandIn) Stop(ctx context.Context) error {
+	var errs []error
+
+	for _, container := range s.containers {
+		_, err := runCommand(ctx, nil, s.Engine, "rm", "--force", container)
+		errs = append(errs, err)
+	}
+
+	clear(s.containers)
+
+	return errors.Join(errs...)
+}
+
+// ChrootStandIn runs every machine in the same unpacked root filesystem using chroot.
+type ChrootStandIn struct {
+	// Directory containing the root filesystem.
+	Dir string
+}
+
+// Implements [StandIn].
+func (s ChrootStandIn) Start(_ context.Context, _ string, _ string) error {
+	return nil
+}
+
+// Implements [StandIn].
+func (s ChrootStandIn) Exec(
+	ctx context.Context,
+	_ string,
+	user string,
+	env []string,
+	script string,
+) ([]byte, error) {
+	args := []string{"--userspec", user, s.Dir, "env"}
+	args = append(args, env...)
+	args = append(args, "bash", "-s")
+
+	return runCommand(ctx, strings.NewReader(script), "chroot", args...)
+}
+
+// Implements [StandIn].
+func (s ChrootStandIn) Stop(_ context.Context) error {
+	return nil
+}
+
+func runCommand(ctx context.Context, stdin *strings.Reader, name string, args ...string) ([]byte, error) {
+	var output bytes.Buffer
+
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+
+	if stdin != nil {
+		cmd.Stdin = stdin
+	}
+
+	err := cmd.Run()
+
+	return output.Bytes(), err
+}
@@ -0,0 +1,182 @@
+package labx
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/iximiuz/labctl/api"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sagikazarmark/labx/core"
+)
+
+type fakeStandIn struct {
+	started map[string]string
+	stopped bool
+
+	// attempts of the "flaky" script
+	attempts int
+}
+
+func (s *fakeStandIn) Start(_ context.Context, machine string, image string) error {
+	s.started[machine] = image
+
+	return nil
+}
+
+func (s *fakeStandIn) Exec(
+	ctx context.Context,
+	_ string,
+	_ string,
+	_ []string,
+	script string,
+) ([]byte, error) {
+	switch strings.TrimSpace(script) {
+	case "hang":
+		<-ctx.Done()
+
+		return nil, ctx.Err()
+	case "fail":
+		return []byte("boom"), errors.New("exit status 1")
+	case "flaky":
+		s.attempts++
+		if s.attempts < 3 {
+			return nil, errors.New("exit status 1")
+		}
+	}
+
+	return nil, nil
+}
+
+func (s *fakeStandIn) Stop(_ context.Context) error {
+	s.stopped = true
+
+	return nil
+}
+
+func TestRunHarness(t *testing.T) {
+	fsys := fstest.MapFS{
+		"manifest.yaml": &fstest.MapFile{Data: []byte(`kind: challenge
+
+playground:
+  machines:
+    - name: node
+      users:
+        - name: laborant
+          default: true
+      drives:
+        - source: oci://ghcr.io/example/rootfs:latest
+
+tasks:
+  init_setup:
+    init: true
+    machine: node
+    user: root
+    run: ok
+  verify_b:
+    machine: node
+    user: laborant
+    needs:
+      - verify_a
+    run: ok
+  verify_a:
+    machine: node
+    user: laborant
+    run: ok
+  verify_hang:
+    machine: node
+    user: laborant
+    timeout_seconds: 1
+    run: hang
+  verify_fail:
+    machine: node
+    user: laborant
+    run: fail
+  verify_flaky:
+    machine: node
+    user: laborant
+    run: flaky
+  verify_after_fail:
+    machine: node
+    user: laborant
+    needs:
+      - verify_fail
+    run: ok
+`)},
+		"solution/solve.sh": &fstest.MapFile{Data: []byte("ok")},
+	}
+
+	standIn := &fakeStandIn{started: map[string]string{}}
+
+	report, err := runHarness(t.Context(), fsys, HarnessOpts{
+		Channel:        "live",
+		StandIn:        standIn,
+		DefaultTimeout: time.Second,
+		PollInterval:   time.Millisecond,
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]string{"node": "ghcr.io/example/rootfs:latest"}, standIn.started)
+	assert.True(t, standIn.stopped)
+
+	var (
+		names    []string
+		statuses []TaskStatus
+	)
+
+	for _, result := range report.Results {
+		names = append(names, result.Name)
+		statuses = append(statuses, result.Status)
+	}
+
+	assert.Equal(t, []string{
+		"init_setup",
+		"solution",
+		"verify_a",
+		"verify_b",
+		"verify_fail",
+		"verify_after_fail",
+		"verify_flaky",
+		"verify_hang",
+	}, names)
+
+	assert.Equal(t, []TaskStatus{
+		TaskPassed,
+		TaskPassed,
+		TaskPassed,
+		TaskPassed,
+		TaskFailed,
+		TaskSkipped,
+		TaskPassed,
+		TaskHung,
+	}, statuses)
+
+	assert.Equal(t, "laborant", report.Results[1].User)
+	assert.Contains(t, report.Results[4].Reason, "did not pass within 1s")
+	assert.Equal(t, 3, standIn.attempts)
+	assert.False(t, report.Passed())
+}
+
+func TestDefaultTaskTargets(t *testing.T) {
+	machines := []core.ContentPlaygroundMachine{
+		{Name: "node-01", Users: []api.MachineUser{{Name: "laborant", Default: true}}},
+		{Name: "node-02"},
+	}
+
+	tasks := defaultTaskTargets([]harnessTask{
+		{name: "no_machine"},
+		{name: "no_user", machine: "node-02"},
+		{name: "explicit", machine: "node-02", user: "laborant"},
+	}, machines)
+
+	assert.Equal(t, []harnessTask{
+		{name: "no_machine", machine: "node-01", user: "laborant"},
+		{name: "no_user", machine: "node-02", user: "root"},
+		{name: "explicit", machine: "node-02", user: "laborant"},
+	}, tasks)
+}
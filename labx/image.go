@@ -0,0 +1,240 @@
+package labx
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/iximiuz/labctl/api"
+
+	"github.com/sagikazarmark/labx/extended"
+)
+
+// DrivePlatform is the platform drive images must be built for.
+var DrivePlatform = v1.Platform{OS: "linux", Architecture: "amd64"}
+
+// ImageReport describes a drive image and the problems found with it.
+type ImageReport struct {
+	Machine string
+	Mount   string
+	Source  string
+
+	// Platform of the image (empty if unknown).
+	Platform string
+
+	// Compressed size of the image layers.
+	Size int64
+
+	// Size of the drive (empty if the platform default is used).
+	DriveSize string
+
+	Problems []string
+}
+
+func (r ImageReport) String() string {
+	s := fmt.Sprintf("machine %s: drive %s: %s", r.Machine, r.Mount, r.Source)
+
+	var details []string
+
+	if r.Platform != "" {
+		details = append(details, r.Platform)
+	}
+
+	if r.Size > 0 {
+		details = append(details, extended.FormatSize(r.Size)+" compressed")
+	}
+
+	if r.DriveSize != "" {
+		details = append(details, r.DriveSize+" drive")
+	}
+
+	if len(details) > 0 {
+		s += " (" + strings.Join(details, ", ") + ")"
+	}
+
+	for _, problem := range r.Problems {
+		s += "\n  " + problem
+	}
+
+	return s
+}
+
+// ImageVerifier inspects drive images and collects reports about them (it is safe for concurrent use).
+type ImageVerifier struct {
+	Registries RegistryConfig
+
+	mu      sync.Mutex
+	reports []ImageReport
+}
+
+// NewImageVerifier creates a new [ImageVerifier].
+func NewImageVerifier(registries RegistryConfig) *ImageVerifier {
+	return &ImageVerifier{
+		Registries: registries,
+	}
+}
+
+// Reports returns the reports of the verified images.
+func (v *ImageVerifier) Reports() []ImageReport {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	return append([]ImageReport(nil), v.reports...)
+}
+
+// Verify fetches the manifest and config of an OCI drive image and checks
+// that it is built for [DrivePlatform], has layers and fits the drive.
+// Other drives are ignored.
+func (v *ImageVerifier) Verify(ctx context.Context, machine string, drive api.MachineDrive) {
+	source, ok := strings.CutPrefix(drive.Source, "oci://")
+	if !ok {
+		return
+	}
+
+	report := ImageReport{
+		Machine:   machine,
+		Mount:     drive.Mount,
+		Source:    drive.Source,
+		DriveSize: drive.Size,
+	}
+
+	err := v.inspect(ctx, source, &report)
+	if err != nil {
+		report.Problems = append(report.Problems, fmt.Sprintf("inspect image: %s", err))
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	v.reports = append(v.reports, report)
+}
+
+func (v *ImageVerifier) inspect(ctx context.Context, source string, report *ImageReport) error {
+	ref, err := v.Registries.ParseReference(source)
+	if err != nil {
+		return err
+	}
+
+	return v.Registries.lookup(ctx, ref, func(ref name.Reference, opts ...remote.Option) error {
+		// Failed attempts (e.g. mirrors) don't leave problems behind
+		attempt := *report
+
+		desc, err := remote.Get(ref, opts...)
+		if err != nil {
+			return err
+		}
+
+		image, err := platformImage(desc, &attempt)
+		if err != nil {
+			return err
+		}
+
+		if image != nil {
+			err = inspectImage(image, &attempt)
+			if err != nil {
+				return err
+			}
+		}
+
+		*report = attempt
+
+		return nil
+	})
+}
+
+// platformImage returns the image of an index for [DrivePlatform] (nil if there is none).
+func platformImage(desc *remote.Descriptor, report *ImageReport) (v1.Image, error) {
+	if !desc.MediaType.IsIndex() {
+		return desc.Image()
+	}
+
+	index, err := desc.ImageIndex()
+	if err != nil {
+		return nil, err
+	}
+
+	manifest, err := index.IndexManifest()
+	if err != nil {
+		return nil, err
+	}
+
+	var platforms []string
+
+	for _, m := range manifest.Manifests {
+		if m.Platform == nil {
+			continue
+		}
+
+		if m.Platform.Satisfies(DrivePlatform) {
+			return index.Image(m.Digest)
+		}
+
+		platforms = append(platforms, m.Platform.String())
+	}
+
+	if len(platforms) == 0 {
+		report.Problems = append(report.Problems, fmt.Sprintf("image index has no platforms (want %s)", DrivePlatform.String()))
+	} else {
+		report.Problems = append(report.Problems, fmt.Sprintf(
+			"image index has no %s image (found %s)",
+			DrivePlatform.String(), strings.Join(platforms, ", "),
+		))
+	}
+
+	return nil, nil
+}
+
+func inspectImage(image v1.Image, report *ImageReport) error {
+	config, err := image.ConfigFile()
+	if err != nil {
+		return err
+	}
+
+	if platform := config.Platform(); platform != nil && platform.OS != "" {
+		report.Platform = platform.String()
+
+		if !platform.Satisfies(DrivePlatform) {
+			report.Problems = append(report.Problems, fmt.Sprintf(
+				"image is built for %s (want %s)",
+				platform.String(), DrivePlatform.String(),
+			))
+		}
+	}
+
+	manifest, err := image.Manifest()
+	if err != nil {
+		return err
+	}
+
+	if len(manifest.Layers) == 0 {
+		report.Problems = append(report.Problems, "image has no layers (no root filesystem)")
+	}
+
+	for _, layer := range manifest.Layers {
+		report.Size += layer.Size
+	}
+
+	if report.DriveSize == "" {
+		return nil
+	}
+
+	driveSize, err := extended.ParseSize(report.DriveSize)
+	if err != nil {
+		report.Problems = append(report.Problems, fmt.Sprintf("drive size: %s", err))
+
+		return nil
+	}
+
+	if report.Size > driveSize {
+		report.Problems = append(report.Problems, fmt.Sprintf(
+			"compressed image size %s exceeds the drive size %s",
+			extended.FormatSize(report.Size), report.DriveSize,
+		))
+	}
+
+	return nil
+}
@@ -0,0 +1,126 @@
+package labx
+
+import (
+	"testing"
+	"testing/fstest"
+	"time"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/iximiuz/labctl/api"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestImageVerifier(t *testing.T) {
+	reg := newTestRegistry(t)
+
+	arm64 := v1.Platform{OS: "linux", Architecture: "arm64"}
+
+	tests := []struct {
+		name     string
+		source   string
+		size     string
+		platform string
+		problems []string
+	}{
+		{
+			name:     "amd64",
+			source:   reg.image("rootfs:amd64", DrivePlatform, 2),
+			size:     "10GiB",
+			platform: "linux/amd64",
+		},
+		{
+			name:     "arm64",
+			source:   reg.image("rootfs:arm64", arm64, 1),
+			platform: "linux/arm64",
+			problems: []string{"image is built for linux/arm64 (want linux/amd64)"},
+		},
+		{
+			name:     "no layers",
+			source:   reg.image("rootfs:empty", DrivePlatform, 0),
+			platform: "linux/amd64",
+			problems: []string{"image has no layers (no root filesystem)"},
+		},
+		{
+			name:     "too large",
+			source:   reg.image("rootfs:large", DrivePlatform, 2),
+			size:     "1KiB",
+			platform: "linux/amd64",
+			problems: []string{"compressed image size"},
+		},
+		{
+			name:     "index",
+			source:   reg.index("rootfs:multi", arm64, DrivePlatform),
+			platform: "linux/amd64",
+		},
+		{
+			name:     "index without platform",
+			source:   reg.index("rootfs:arm-only", arm64),
+			problems: []string{"image index has no linux/amd64 image (found linux/arm64)"},
+		},
+		{
+			name:     "missing",
+			source:   reg.host + "/rootfs:missing",
+			problems: []string{"inspect image:"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			verifier := NewImageVerifier(RegistryConfig{Timeout: lo.ToPtr(5 * time.Second)})
+
+			verifier.Verify(t.Context(), "dev", api.MachineDrive{Source: "oci://" + tt.source, Mount: "/", Size: tt.size})
+
+			reports := verifier.Reports()
+			require.Len(t, reports, 1)
+
+			report := reports[0]
+
+			assert.Equal(t, "dev", report.Machine)
+			assert.Equal(t, tt.platform, report.Platform)
+			require.Len(t, report.Problems, len(tt.problems))
+
+			for i, problem := range tt.problems {
+				assert.Contains(t, report.Problems[i], problem)
+			}
+		})
+	}
+}
+
+func TestImageVerifier_NotOCI(t *testing.T) {
+	verifier := NewImageVerifier(RegistryConfig{})
+
+	verifier.Verify(t.Context(), "dev", api.MachineDrive{Source: "https://example.com/rootfs.img", Mount: "/"})
+
+	assert.Empty(t, verifier.Reports())
+}
+
+func TestValidate(t *testing.T) {
+	reg := newTestRegistry(t)
+
+	source := reg.image("rootfs:arm64", v1.Platform{OS: "linux", Architecture: "arm64"}, 1)
+
+	fsys := fstest.MapFS{
+		"manifest.yaml": &fstest.MapFile{Data: []byte(`kind: playground
+name: my-playground
+channels:
+  dev:
+    name: my-playground-dev
+playground:
+  machines:
+    - name: dev
+      drives:
+        - source: oci://` + source + `
+          mount: /
+`)},
+	}
+
+	report, err := validateFS(fsys, ValidateOpts{Channel: "dev"})
+	require.NoError(t, err)
+
+	require.Len(t, report.Images, 1)
+	assert.False(t, report.Passed())
+	assert.Equal(t, "dev", report.Images[0].Machine)
+	assert.Contains(t, report.Images[0].Source, "@sha256:")
+}
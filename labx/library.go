@@ -0,0 +1,131 @@
+package labx
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+
+	"github.com/goccy/go-yaml"
+
+	"github.com/sagikazarmark/labx/extended"
+)
+
+const machineLibraryFile = "machines.yaml"
+
+const resourceLibraryFile = "limits.yaml"
+
+// loadResourceLibrary loads platform limits and resource presets from template directories, library directories and the root
+// (in increasing order of precedence) on top of the built-in ones.
+func loadResourceLibrary(
+	rootFS fs.FS,
+	templateFSs []fs.FS,
+	libraryFSs []fs.FS,
+) (extended.ResourceLibrary, error) {
+	resources := extended.DefaultResourceLibrary()
+
+	for _, fsys := range append(append(append([]fs.FS{}, templateFSs...), libraryFSs...), rootFS) {
+		libraryFile, err := fsys.Open(resourceLibraryFile)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return extended.ResourceLibrary{}, err
+		}
+
+		var library extended.ResourceLibrary
+
+		err = yaml.NewDecoder(libraryFile).Decode(&library)
+		libraryFile.Close()
+		if err != nil {
+			return extended.ResourceLibrary{}, fmt.Errorf("decode resource library: %w", err)
+		}
+
+		resources = resources.Override(library)
+	}
+
+	return resources, nil
+}
+
+// loadMachinePresets loads machine presets from template directories, library directories and the root
+// (in increasing order of precedence).
+func loadMachinePresets(
+	rootFS fs.FS,
+	templateFSs []fs.FS,
+	libraryFSs []fs.FS,
+) (map[string]extended.PlaygroundMachine, error) {
+	presets := map[string]extended.PlaygroundMachine{}
+
+	for _, fsys := range append(append(append([]fs.FS{}, templateFSs...), libraryFSs...), rootFS) {
+		library, err := loadMachineLibrary(fsys)
+		if err != nil {
+			return nil, err
+		}
+
+		maps.Copy(presets, library)
+	}
+
+	return presets, nil
+}
+
+// loadMachineLibrary loads the machine library from a filesystem (if there is one).
+//
+// Files referenced by presets are resolved relative to the library,
+// so presets can be used from any content.
+func loadMachineLibrary(fsys fs.FS) (map[string]extended.PlaygroundMachine, error) {
+	libraryFile, err := fsys.Open(machineLibraryFile)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer libraryFile.Close()
+
+	var library extended.MachineLibrary
+
+	err = yaml.NewDecoder(libraryFile).Decode(&library)
+	if err != nil {
+		return nil, fmt.Errorf("decode machine library: %w", err)
+	}
+
+	userProcessor := MachineUserProcessor{
+		Fsys: fsys,
+	}
+
+	startupFileProcessor := MachineStartupFileProcessor{
+		Fsys: fsys,
+	}
+
+	for name, preset := range library.Machines {
+		for i, user := range preset.Users {
+			user, err := userProcessor.Process(user)
+			if err != nil {
+				return nil, fmt.Errorf("preset %s: processing user %s: %w", name, preset.Users[i].Name, err)
+			}
+
+			user.WelcomeFile = ""
+			preset.Users[i] = user
+		}
+
+		var startupFiles extended.MachineStartupFiles
+
+		for i, startupFile := range preset.StartupFiles {
+			processed, err := startupFileProcessor.Process(startupFile)
+			if err != nil {
+				return nil, fmt.Errorf("preset %s: processing startup file %d: %w", name, i, err)
+			}
+
+			for _, startupFile := range processed {
+				startupFile.FromFile = ""
+				startupFiles = append(startupFiles, startupFile)
+			}
+		}
+
+		preset.StartupFiles = startupFiles
+
+		library.Machines[name] = preset
+	}
+
+	return library.Machines, nil
+}
@@ -0,0 +1,363 @@
+package labx
+
+import (
+	"cmp"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/goccy/go-yaml"
+	"github.com/goccy/go-yaml/ast"
+	"github.com/goccy/go-yaml/parser"
+	"github.com/iximiuz/labctl/content"
+
+	"github.com/sagikazarmark/labx/extended"
+)
+
+// LintOpts contains options for the Lint function
+type LintOpts struct {
+	Root *os.Root
+
+	// Commands that are known to exist on the machines (in addition to the built-in list).
+	KnownCommands []string
+}
+
+// Severity describes how serious a lint finding is.
+type Severity string
+
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+)
+
+// Finding is a problem found in a task script.
+type Finding struct {
+	// Manifest file the script was found in (relative to the root).
+	File string
+
+	// Position of the problem in the manifest file.
+	Line   int
+	Column int
+
+	// Path of the script in the manifest (e.g. tasks.verify_something.run).
+	Path string
+
+	Severity Severity
+	Message  string
+}
+
+func (f Finding) String() string {
+	return fmt.Sprintf("%s:%d:%d: %s: %s: %s", f.File, f.Line, f.Column, f.Severity, f.Path, f.Message)
+}
+
+// Lint parses every task script in the manifests found under the root and reports problems.
+func Lint(opts LintOpts) ([]Finding, error) {
+	return lintFS(opts.Root.FS(), opts.KnownCommands)
+}
+
+func lintFS(fsys fs.FS, knownCommands []string) ([]Finding, error) {
+	manifests := []string{"manifest.yaml"}
+
+	// Lessons carry their own tasks
+	for _, pattern := range []string{"lessons/*/manifest.yaml", "modules/*/*/manifest.yaml"} {
+		matches, err := fs.Glob(fsys, pattern)
+		if err != nil {
+			return nil, err
+		}
+
+		manifests = append(manifests, matches...)
+	}
+
+	var findings []Finding
+
+	for _, manifest := range manifests {
+		manifestFindings, err := lintManifest(fsys, manifest, knownCommands)
+		if err != nil {
+			return nil, fmt.Errorf("lint %s: %w", manifest, err)
+		}
+
+		findings = append(findings, manifestFindings...)
+	}
+
+	slices.SortStableFunc(findings, func(a, b Finding) int {
+		return cmp.Or(
+			cmp.Compare(a.File, b.File),
+			cmp.Compare(a.Line, b.Line),
+			cmp.Compare(a.Column, b.Column),
+		)
+	})
+
+	return findings, nil
+}
+
+// lintScript is a single script extracted from a manifest.
+type lintScript struct {
+	// YAML path segments of the script
+	path []string
+
+	script string
+	env    []string
+}
+
+func lintManifest(fsys fs.FS, manifestPath string, knownCommands []string) ([]Finding, error) {
+	src, err := fs.ReadFile(fsys, manifestPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var kind manifestKind
+
+	err = yaml.Unmarshal(src, &kind)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		scripts  []lintScript
+		machines extended.PlaygroundMachines
+	)
+
+	if kind.Kind == "playground" {
+		var manifest extended.PlaygroundManifest
+
+		err = yaml.Unmarshal(src, &manifest)
+		if err != nil {
+			return nil, err
+		}
+
+		machines = manifest.Playground.Machines
+
+		for _, name := range sortedKeys(manifest.Playground.InitTasks) {
+			scripts = append(scripts, lintScript{
+				path:   []string{"playground", "initTasks", name, "run"},
+				script: manifest.Playground.InitTasks[name].Run,
+			})
+		}
+	} else {
+		var manifest extended.ContentManifest
+
+		err = yaml.Unmarshal(src, &manifest)
+		if err != nil {
+			return nil, err
+		}
+
+		if manifest.Kind != content.KindTraining && manifest.Kind != content.KindCourse {
+			machines = manifest.Playground.Machines
+		}
+
+		for _, name := range sortedKeys(manifest.Tasks) {
+			task := manifest.Tasks[name]
+
+			for _, script := range [][2]string{
+				{"run", task.Run},
+				{"hintcheck", task.HintCheck},
+				{"failcheck", task.FailCheck},
+			} {
+				scripts = append(scripts, lintScript{
+					path:   []string{"tasks", name, script[0]},
+					script: script[1],
+					env:    task.Env,
+				})
+			}
+		}
+	}
+
+	file, err := parser.ParseBytes(src, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	provided := providedByStartupFiles(fsys, path.Dir(manifestPath), machines)
+
+	linter := scriptLinter{
+		commands: mergeSets(defaultKnownCommands, provided.commands, knownCommands),
+		vars:     mergeSets(defaultKnownVars, provided.vars),
+	}
+
+	lines := strings.Split(string(src), "\n")
+
+	var findings []Finding
+
+	for _, script := range scripts {
+		if strings.TrimSpace(script.script) == "" {
+			continue
+		}
+
+		node, err := lookupNode(file, script.path)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, problem := range linter.lint(script.script, script.env) {
+			line, column := scriptPosition(node, lines, problem.line, problem.column)
+
+			findings = append(findings, Finding{
+				File:     manifestPath,
+				Line:     line,
+				Column:   column,
+				Path:     strings.Join(script.path, "."),
+				Severity: problem.severity,
+				Message:  problem.message,
+			})
+		}
+	}
+
+	return findings, nil
+}
+
+func lookupNode(file *ast.File, segments []string) (ast.Node, error) {
+	builder := (&yaml.PathBuilder{}).Root()
+	for _, segment := range segments {
+		builder = builder.Child(segment)
+	}
+
+	return builder.Build().FilterFile(file)
+}
+
+// scriptPosition maps a position inside a script to a position in the manifest file.
+func scriptPosition(node ast.Node, lines []string, line int, column int) (int, int) {
+	token := node.GetToken()
+
+	// Block scalars start on the line after the indicator
+	if _, ok := node.(*ast.LiteralNode); ok {
+		manifestLine := token.Position.Line + line
+
+		indent := 0
+		if manifestLine-1 < len(lines) {
+			text := lines[manifestLine-1]
+			indent = len(text) - len(strings.TrimLeft(text, " "))
+		}
+
+		return manifestLine, indent + column
+	}
+
+	// Multi-line flow scalars are folded, so only the first line can be mapped reliably
+	if line > 1 {
+		return token.Position.Line, token.Position.Column
+	}
+
+	return token.Position.Line, token.Position.Column + column - 1
+}
+
+// providedItems holds the commands and variables provided by startup files.
+type providedItems struct {
+	commands []string
+	vars     []string
+}
+
+func providedByStartupFiles(
+	fsys fs.FS,
+	dir string,
+	machines extended.PlaygroundMachines,
+) providedItems {
+	var provided providedItems
+
+	for _, machine := range machines {
+		var startupFiles extended.MachineStartupFiles
+
+		for _, startupFile := range machine.StartupFiles {
+			if startupFile.FromDir == "" {
+				startupFiles = append(startupFiles, startupFile)
+
+				continue
+			}
+
+			startupFileProcessor := MachineStartupFileProcessor{
+				Fsys: subFS(fsys, dir),
+			}
+
+			// Missing directories are reported during generation
+			expanded, _ := startupFileProcessor.Process(startupFile)
+			startupFiles = append(startupFiles, expanded...)
+		}
+
+		for _, startupFile := range startupFiles {
+			// Executables placed in a bin directory become commands
+			if base := path.Base(path.Dir(startupFile.Path)); base == "bin" || base == "sbin" {
+				provided.commands = append(provided.commands, path.Base(startupFile.Path))
+			}
+
+			fileContent := startupFile.Content
+			if startupFile.FromFile != "" {
+				b, err := fs.ReadFile(fsys, path.Join(dir, startupFile.FromFile))
+				if err != nil {
+					// Missing files are reported during generation
+					continue
+				}
+
+				fileContent = string(b)
+			}
+
+			if startupFile.Path == "/etc/environment" {
+				for line := range strings.Lines(fileContent) {
+					name, _, ok := strings.Cut(strings.TrimSpace(line), "=")
+					if ok && !strings.HasPrefix(name, "#") {
+						provided.vars = append(provided.vars, name)
+					}
+				}
+
+				continue
+			}
+
+			if !isShellStartupFile(startupFile.Path) {
+				continue
+			}
+
+			commands, vars := shellDefinitions(fileContent)
+			provided.commands = append(provided.commands, commands...)
+			provided.vars = append(provided.vars, vars...)
+		}
+	}
+
+	return provided
+}
+
+// isShellStartupFile reports whether a file is sourced by shells (or is a shell script).
+func isShellStartupFile(filePath string) bool {
+	switch path.Base(filePath) {
+	case ".bashrc", ".profile", ".bash_profile", "bash.bashrc", "profile":
+		return true
+	}
+
+	return strings.HasPrefix(filePath, "/etc/profile.d/") || strings.HasSuffix(filePath, ".sh")
+}
+
+// subFS returns the subtree of a filesystem (or the filesystem itself for the root directory).
+func subFS(fsys fs.FS, dir string) fs.FS {
+	if dir == "." {
+		return fsys
+	}
+
+	sub, err := fs.Sub(fsys, dir)
+	if err != nil {
+		return fsys
+	}
+
+	return sub
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+
+	slices.Sort(keys)
+
+	return keys
+}
+
+func mergeSets(sets ...[]string) map[string]bool {
+	result := map[string]bool{}
+
+	for _, set := range sets {
+		for _, item := range set {
+			result[item] = true
+		}
+	}
+
+	return result
+}
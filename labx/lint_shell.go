@@ -0,0 +1,220 @@
+package labx
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"mvdan.cc/sh/v3/syntax"
+)
+
+// Shell builtins and commands commonly found on iximiuz Labs rootfs images.
+var defaultKnownCommands = []string{
+	// Shell builtins and keywords
+	".", ":", "[", "alias", "bg", "bind", "break", "builtin", "caller", "cd", "command", "compgen",
+	"complete", "continue", "declare", "dirs", "disown", "echo", "enable", "eval", "exec", "exit",
+	"export", "false", "fc", "fg", "getopts", "hash", "help", "history", "jobs", "kill", "let",
+	"local", "logout", "mapfile", "popd", "printf", "pushd", "pwd", "read", "readarray", "readonly",
+	"return", "set", "shift", "shopt", "source", "suspend", "test", "times", "trap", "true", "type",
+	"typeset", "ulimit", "umask", "unalias", "unset", "wait",
+
+	// Core utilities
+	"awk", "base64", "basename", "bash", "cat", "chgrp", "chmod", "chown", "cmp", "comm", "cp",
+	"cut", "date", "dd", "df", "diff", "dirname", "du", "env", "expr", "file", "find", "grep",
+	"gunzip", "gzip", "head", "hostname", "id", "install", "ln", "ls", "md5sum", "mkdir", "mktemp",
+	"mv", "nohup", "od", "paste", "ps", "readlink", "realpath", "rm", "rmdir", "sed", "seq", "sh",
+	"sha256sum", "sleep", "sort", "stat", "su", "sudo", "tail", "tar", "tee", "timeout", "touch",
+	"tr", "truncate", "uname", "uniq", "unzip", "wc", "which", "whoami", "xargs", "yes", "zcat",
+
+	// System and networking tools
+	"apt", "apt-get", "curl", "dpkg", "getent", "git", "groupadd", "ip", "jq", "journalctl",
+	"mount", "nc", "ping", "pgrep", "pkill", "ss", "systemctl", "umount", "useradd", "usermod",
+	"wget",
+}
+
+// Environment variables that are always set (or managed by the shell).
+var defaultKnownVars = []string{
+	"BASH", "BASHPID", "BASH_REMATCH", "BASH_SOURCE", "BASH_VERSION", "EUID", "FUNCNAME", "GROUPS",
+	"HOME", "HOSTNAME", "IFS", "LANG", "LINENO", "LOGNAME", "OLDPWD", "OPTARG", "OPTIND", "PATH",
+	"PIPESTATUS", "PPID", "PWD", "RANDOM", "REPLY", "SECONDS", "SHELL", "SHLVL", "TERM", "UID",
+	"USER",
+}
+
+// scriptLinter checks shell scripts for problems.
+type scriptLinter struct {
+	commands map[string]bool
+	vars     map[string]bool
+}
+
+// scriptProblem is a problem found in a script (positions are relative to the script).
+type scriptProblem struct {
+	line     int
+	column   int
+	severity Severity
+	message  string
+}
+
+func (l scriptLinter) lint(script string, env []string) []scriptProblem {
+	file, err := parseShell(script)
+	if err != nil {
+		var parseErr syntax.ParseError
+		if errors.As(err, &parseErr) {
+			return []scriptProblem{{
+				line:     int(parseErr.Pos.Line()),
+				column:   int(parseErr.Pos.Col()),
+				severity: SeverityError,
+				message:  "syntax error: " + parseErr.Text,
+			}}
+		}
+
+		return []scriptProblem{{line: 1, column: 1, severity: SeverityError, message: err.Error()}}
+	}
+
+	commands, vars := definitions(file)
+
+	for _, e := range env {
+		name, _, _ := strings.Cut(e, "=")
+		vars = append(vars, name)
+	}
+
+	knownCommands := mergeSets(commands)
+	knownVars := mergeSets(vars)
+
+	var problems []scriptProblem
+
+	syntax.Walk(file, func(node syntax.Node) bool {
+		switch n := node.(type) {
+		case *syntax.CallExpr:
+			if len(n.Args) == 0 {
+				return true
+			}
+
+			name := n.Args[0].Lit()
+
+			// Dynamic commands and paths cannot be checked
+			if name == "" || strings.Contains(name, "/") {
+				return true
+			}
+
+			if !l.commands[name] && !knownCommands[name] {
+				problems = append(problems, scriptProblem{
+					line:     int(n.Args[0].Pos().Line()),
+					column:   int(n.Args[0].Pos().Col()),
+					severity: SeverityWarning,
+					message:  fmt.Sprintf("unknown command %q (not provided by startup files)", name),
+				})
+			}
+
+		case *syntax.ParamExp:
+			if n.Param == nil || n.Excl || n.Length || n.Names != 0 || hasDefault(n) {
+				return true
+			}
+
+			name := n.Param.Value
+			if isSpecialParam(name) || l.vars[name] || knownVars[name] {
+				return true
+			}
+
+			problems = append(problems, scriptProblem{
+				line:     int(n.Pos().Line()),
+				column:   int(n.Pos().Col()),
+				severity: SeverityWarning,
+				message:  fmt.Sprintf("undefined environment variable %q (not listed in env)", name),
+			})
+		}
+
+		return true
+	})
+
+	return problems
+}
+
+func parseShell(script string) (*syntax.File, error) {
+	return syntax.NewParser(syntax.Variant(syntax.LangBash)).
+		Parse(strings.NewReader(script), "")
+}
+
+// shellDefinitions returns the functions and variables defined by a shell script.
+func shellDefinitions(script string) ([]string, []string) {
+	file, err := parseShell(script)
+	if err != nil {
+		return nil, nil
+	}
+
+	return definitions(file)
+}
+
+func definitions(file *syntax.File) ([]string, []string) {
+	var commands, vars []string
+
+	syntax.Walk(file, func(node syntax.Node) bool {
+		switch n := node.(type) {
+		case *syntax.FuncDecl:
+			commands = append(commands, n.Name.Value)
+
+		case *syntax.Assign:
+			if n.Name != nil {
+				vars = append(vars, n.Name.Value)
+			}
+
+		case *syntax.WordIter:
+			vars = append(vars, n.Name.Value)
+
+		case *syntax.CallExpr:
+			if len(n.Args) == 0 {
+				return true
+			}
+
+			// Commands that assign variables by name
+			switch n.Args[0].Lit() {
+			case "read", "mapfile", "readarray", "getopts":
+				for _, arg := range n.Args[1:] {
+					if lit := arg.Lit(); lit != "" && !strings.HasPrefix(lit, "-") {
+						vars = append(vars, lit)
+					}
+				}
+
+			case "printf":
+				if len(n.Args) <= 2 {
+					break
+				}
+
+				for i, arg := range n.Args[1 : len(n.Args)-1] {
+					if arg.Lit() == "-v" {
+						vars = append(vars, n.Args[i+2].Lit())
+					}
+				}
+			}
+		}
+
+		return true
+	})
+
+	return commands, vars
+}
+
+// hasDefault reports whether a parameter expansion handles unset variables.
+func hasDefault(exp *syntax.ParamExp) bool {
+	if exp.Exp == nil {
+		return false
+	}
+
+	switch exp.Exp.Op {
+	case syntax.AlternateUnset, syntax.AlternateUnsetOrNull,
+		syntax.DefaultUnset, syntax.DefaultUnsetOrNull,
+		syntax.ErrorUnset, syntax.ErrorUnsetOrNull,
+		syntax.AssignUnset, syntax.AssignUnsetOrNull:
+		return true
+	}
+
+	return false
+}
+
+func isSpecialParam(name string) bool {
+	switch name {
+	case "@", "*", "#", "?", "-", "$", "!", "0":
+		return true
+	}
+
+	return strings.Trim(name, "0123456789") == ""
+}
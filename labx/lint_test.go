@@ -0,0 +1,127 @@
+package labx
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLint(t *testing.T) {
+	fsys := fstest.MapFS{
+		"manifest.yaml": &fstest.MapFile{Data: []byte(`kind: challenge
+
+playground:
+  name: docker
+  machines:
+    - name: docker
+      startupFiles:
+        - path: /etc/profile.d/helpers.sh
+          fromFile: helpers.sh
+        - path: /usr/local/bin/check-ready
+          content: "#!/bin/sh"
+
+tasks:
+  verify_ok:
+    machine: docker
+    user: root
+    env:
+      - GREETING=hello
+    run: |
+      for f in a b; do echo "$f $GREETING $HELPER_DIR"; done
+      is_running && check-ready
+  verify_broken:
+    machine: docker
+    user: root
+    run: |
+      echo ok
+      echo "unterminated
+  verify_unknown:
+    machine: docker
+    user: root
+    run: frobnicate "${MISSING}" "${OPTIONAL:-}"
+`)},
+		"helpers.sh": &fstest.MapFile{Data: []byte(`HELPER_DIR=/opt/helpers
+
+is_running() {
+  true
+}
+`)},
+	}
+
+	findings, err := lintFS(fsys, nil)
+	require.NoError(t, err)
+
+	expected := []Finding{
+		{
+			File:     "manifest.yaml",
+			Line:     27,
+			Column:   12,
+			Path:     "tasks.verify_broken.run",
+			Severity: SeverityError,
+			Message:  `syntax error: reached EOF without closing quote "`,
+		},
+		{
+			File:     "manifest.yaml",
+			Line:     31,
+			Column:   10,
+			Path:     "tasks.verify_unknown.run",
+			Severity: SeverityWarning,
+			Message:  `unknown command "frobnicate" (not provided by startup files)`,
+		},
+		{
+			File:     "manifest.yaml",
+			Line:     31,
+			Column:   22,
+			Path:     "tasks.verify_unknown.run",
+			Severity: SeverityWarning,
+			Message:  `undefined environment variable "MISSING" (not listed in env)`,
+		},
+	}
+
+	assert.Equal(t, expected, findings)
+}
+
+func TestShellDefinitions(t *testing.T) {
+	tests := []struct {
+		name     string
+		script   string
+		commands []string
+		vars     []string
+	}{
+		{
+			name:     "functions and variables",
+			script:   "greet() { true; }\nNAME=world\nfor f in a; do :; done\n",
+			commands: []string{"greet"},
+			vars:     []string{"NAME", "f"},
+		},
+		{
+			name:   "read",
+			script: "read -r LINE REST",
+			vars:   []string{"LINE", "REST"},
+		},
+		{
+			name:   "printf -v",
+			script: `printf -v OUT "%s" hello`,
+			vars:   []string{"OUT"},
+		},
+		{
+			name:   "bare printf",
+			script: "printf",
+		},
+		{
+			name:   "printf without format",
+			script: "printf -v",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			commands, vars := shellDefinitions(test.script)
+
+			assert.Equal(t, test.commands, commands)
+			assert.Equal(t, test.vars, vars)
+		})
+	}
+}
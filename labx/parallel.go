@@ -0,0 +1,111 @@
+package labx
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// defaultConcurrency is the number of lessons, units, machines or drives processed at the same time by default.
+const defaultConcurrency = 8
+
+// Limiter bounds the work done at the same time by nested [forEach] calls
+// (e.g. the drives of the machines of the lessons of a course) sharing it.
+type Limiter struct {
+	slots chan struct{}
+}
+
+// NewLimiter returns a limiter allowing limit calls at the same time (defaultConcurrency if limit is not positive).
+func NewLimiter(limit int) *Limiter {
+	if limit <= 0 {
+		limit = defaultConcurrency
+	}
+
+	// The caller of forEach always does work itself
+	return &Limiter{slots: make(chan struct{}, limit-1)}
+}
+
+func (l *Limiter) tryAcquire() bool {
+	select {
+	case l.slots <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+func (l *Limiter) release() {
+	<-l.slots
+}
+
+// forEach calls fn for every index concurrently (limited by limiter, defaultConcurrency if it is nil).
+//
+// Calls never wait for the limiter: when no slot is free, fn is called by the caller instead.
+// That way nested calls sharing the limiter can't deadlock waiting for slots held by their parents.
+//
+// The first failure cancels the context passed to the other calls.
+// The returned error is the error of the lowest index (ignoring cancellations caused by other failures),
+// so errors are reported deterministically.
+func forEach(ctx context.Context, limiter *Limiter, n int, fn func(ctx context.Context, i int) error) error {
+	if limiter == nil {
+		limiter = NewLimiter(defaultConcurrency)
+	}
+
+	groupCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	errs := make([]error, n)
+
+	call := func(i int) {
+		// Don't start new work after a failure
+		if err := groupCtx.Err(); err != nil {
+			errs[i] = err
+
+			return
+		}
+
+		errs[i] = fn(groupCtx, i)
+		if errs[i] != nil {
+			cancel()
+		}
+	}
+
+	var wg sync.WaitGroup
+
+	for i := range n {
+		if !limiter.tryAcquire() {
+			call(i)
+
+			continue
+		}
+
+		wg.Go(func() {
+			defer limiter.release()
+
+			call(i)
+		})
+	}
+
+	wg.Wait()
+
+	// Cancelled by the caller
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	var canceled error
+
+	for _, err := range errs {
+		switch {
+		case err == nil:
+		case errors.Is(err, context.Canceled):
+			if canceled == nil {
+				canceled = err
+			}
+		default:
+			return err
+		}
+	}
+
+	return canceled
+}
@@ -0,0 +1,115 @@
+package labx
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestForEach(t *testing.T) {
+	results := make([]int, 20)
+
+	var running, maxRunning atomic.Int32
+
+	err := forEach(t.Context(), NewLimiter(3), len(results), func(_ context.Context, i int) error {
+		n := running.Add(1)
+		defer running.Add(-1)
+
+		for {
+			m := maxRunning.Load()
+			if n <= m || maxRunning.CompareAndSwap(m, n) {
+				break
+			}
+		}
+
+		time.Sleep(time.Millisecond)
+
+		results[i] = i * i
+
+		return nil
+	})
+	require.NoError(t, err)
+
+	for i, result := range results {
+		assert.Equal(t, i*i, result)
+	}
+
+	assert.LessOrEqual(t, maxRunning.Load(), int32(3))
+}
+
+func TestForEach_Nested(t *testing.T) {
+	limiter := NewLimiter(4)
+
+	var running, maxRunning atomic.Int32
+
+	// Nested calls share the limit instead of multiplying it (and don't deadlock)
+	err := forEach(t.Context(), limiter, 8, func(ctx context.Context, _ int) error {
+		return forEach(ctx, limiter, 8, func(_ context.Context, _ int) error {
+			n := running.Add(1)
+			defer running.Add(-1)
+
+			for {
+				m := maxRunning.Load()
+				if n <= m || maxRunning.CompareAndSwap(m, n) {
+					break
+				}
+			}
+
+			time.Sleep(time.Millisecond)
+
+			return nil
+		})
+	})
+	require.NoError(t, err)
+
+	assert.LessOrEqual(t, maxRunning.Load(), int32(4))
+}
+
+func TestForEach_Error(t *testing.T) {
+	errFirst := errors.New("first")
+	errSecond := errors.New("second")
+
+	started := make(chan struct{})
+
+	// The error of the lowest index is returned, even if it fails last
+	err := forEach(t.Context(), nil, 3, func(ctx context.Context, i int) error {
+		switch i {
+		case 0:
+			close(started)
+			time.Sleep(10 * time.Millisecond)
+
+			return errFirst
+		case 1:
+			<-started
+
+			return errSecond
+		default:
+			<-ctx.Done()
+
+			return ctx.Err()
+		}
+	})
+
+	assert.ErrorIs(t, err, errFirst)
+}
+
+func TestForEach_Canceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+
+	var calls atomic.Int32
+
+	err := forEach(ctx, NewLimiter(1), 5, func(_ context.Context, _ int) error {
+		calls.Add(1)
+
+		return nil
+	})
+
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Zero(t, calls.Load())
+}
@@ -2,9 +2,11 @@ package labx
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"io/fs"
 	"os/exec"
+	"slices"
 	"strings"
 	"text/template"
 
@@ -17,12 +19,7 @@ import (
 )
 
 func Playground(ctx GenerateContext) error {
-	manifest, err := convertPlaygroundManifest(
-		ctx.Root.FS(),
-		ctx.Channel,
-		ctx.BaseTemplate,
-		ctx.ExtraData,
-	)
+	manifest, err := convertPlaygroundManifest(ctx.Root.FS(), ctx.manifestOptions())
 	if err != nil {
 		return err
 	}
@@ -53,15 +50,35 @@ func Playground(ctx GenerateContext) error {
 	return nil
 }
 
-func convertPlaygroundManifest(
-	fsys fs.FS,
-	channel string,
-	baseTemplate *template.Template,
-	extraData map[string]any,
-) (api.PlaygroundManifest, error) {
+func convertPlaygroundManifest(fsys fs.FS, opts manifestOptions) (api.PlaygroundManifest, error) {
+	manifest, _, err := convertPlaygroundLayers(fsys, opts)
+
+	return manifest, err
+}
+
+// playgroundLayer is a playground of an inheritance chain.
+type playgroundLayer struct {
+	Name   string
+	Remote bool
+
+	// Spec is the own spec of the playground (without inherited fields).
+	Spec  api.PlaygroundSpec
+	Merge map[string]extended.MachineMerge
+}
+
+func (l playgroundLayer) String() string {
+	if l.Remote {
+		return l.Name + " (remote)"
+	}
+
+	return l.Name + " (local)"
+}
+
+// convertPlaygroundLayers converts a playground manifest and returns the layers it inherits from (starting with itself).
+func convertPlaygroundLayers(fsys fs.FS, opts manifestOptions) (api.PlaygroundManifest, []playgroundLayer, error) {
 	manifestFile, err := fsys.Open("manifest.yaml")
 	if err != nil {
-		return api.PlaygroundManifest{}, err
+		return api.PlaygroundManifest{}, nil, err
 	}
 	defer manifestFile.Close()
 
@@ -71,54 +88,130 @@ func convertPlaygroundManifest(
 
 	err = decoder.Decode(&extendedManifest)
 	if err != nil {
-		return api.PlaygroundManifest{}, err
+		return api.PlaygroundManifest{}, nil, err
+	}
+
+	err = interpolateSecrets(opts.Secrets, &extendedManifest)
+	if err != nil {
+		return api.PlaygroundManifest{}, nil, err
+	}
+
+	layer := playgroundLayer{
+		Name: extendedManifest.Name,
 	}
 
-	// basePlayground, err := getPlaygroundManifest(extendedManifest.Base)
-	// if err != nil {
-	// 	return api.PlaygroundManifest{}, err
-	// }
+	if slices.Contains(opts.BaseChain, extendedManifest.Name) {
+		return api.PlaygroundManifest{}, nil, fmt.Errorf(
+			"playground inheritance cycle: %s",
+			strings.Join(append(opts.BaseChain, extendedManifest.Name), " -> "),
+		)
+	}
+
+	opts.BaseChain = append(slices.Clone(opts.BaseChain), extendedManifest.Name)
 
 	extendedManifest.Playground.BaseName = extendedManifest.Base
 
+	var baseLayers []playgroundLayer
+
+	// Published base playgrounds are inherited on the server unless fetching them is enabled
+	if inheritsPlayground(extendedManifest.Base) {
+		basePlayground, layers, ok, err := resolveBasePlayground(extendedManifest.Base, opts, opts.RemoteBases)
+		if err != nil {
+			return api.PlaygroundManifest{}, nil, err
+		}
+
+		if ok {
+			extendedManifest.Playground.Base = basePlayground.Playground
+
+			// The base playground is flattened into this one: use its own base on the server
+			// (local playgrounds may not be published and published ones would be inherited twice)
+			if basePlayground.Base != "" {
+				extendedManifest.Base = basePlayground.Base
+			}
+
+			baseLayers = layers
+		}
+	}
+
+	machines, err := extendedManifest.Playground.Machines.ApplyPresets(opts.MachinePresets)
+	if err != nil {
+		return api.PlaygroundManifest{}, nil, err
+	}
+
+	machines, err = machines.ApplyResourcePresets(opts.Resources.Presets)
+	if err != nil {
+		return api.PlaygroundManifest{}, nil, err
+	}
+
+	extendedManifest.Playground.Machines = machines
+
+	channel := opts.Channel
+
+	renderer := &TemplateRenderer{
+		Funcs:   createTemplateFuncs(fsys, opts.Secrets),
+		Channel: channel,
+		Extra:   opts.ExtraData,
+	}
+
 	playgroundProcessor := PlaygroundProcessor{
 		Channel: channel,
 		Fsys:    fsys,
+		Limits:  opts.Resources.Limits,
 		MachinesProcessor: MachinesProcessor{
 			MachineProcessor: MachineProcessor{
 				UserProcessor: MachineUserProcessor{
-					Fsys: fsys,
+					Fsys:     fsys,
+					Renderer: renderer,
 				},
 				DriveProcessor: MachineDriveProcessor{
 					ContentKind:      content.KindPlayground,
 					ContentName:      extendedManifest.Name,
 					Channel:          channel,
+					Extra:            opts.ExtraData,
 					DefaultImageRepo: defaultImageRepo,
+					Sources:          newDriveSourceRegistry(opts.DriveSources, defaultImageRepo, renderer.Funcs),
+					Images:           opts.Images,
 				},
 				StartupFileProcessor: MachineStartupFileProcessor{
-					Fsys: fsys,
+					Fsys:     fsys,
+					Renderer: renderer,
 				},
 			},
+			Limiter: opts.Limiter,
 		},
 	}
 
-	extendedManifest, err = playgroundProcessor.Process(extendedManifest)
+	extendedManifest, err = playgroundProcessor.Process(opts.ctx(), extendedManifest)
 	if err != nil {
-		return api.PlaygroundManifest{}, err
+		return api.PlaygroundManifest{}, nil, err
 	}
 
+	ownSpec := extendedManifest.Playground
+	ownSpec.Base = api.PlaygroundSpec{}
+
+	layer.Spec = ownSpec.Convert()
+	layer.Merge = extendedManifest.Playground.Machines.Merges()
+
+	layers := append([]playgroundLayer{layer}, baseLayers...)
+
 	manifest := extendedManifest.Convert()
 
 	if manifest.Markdown == "" {
-		markdown, err := readAndRenderMarkdown(fsys, channel, manifest, baseTemplate, extraData)
+		markdown, err := readAndRenderMarkdown(
+			fsys,
+			channel,
+			manifest,
+			opts.BaseTemplate,
+			opts.ExtraData,
+		)
 		if err != nil {
-			return manifest, err
+			return manifest, layers, err
 		}
 
 		manifest.Markdown = markdown
 	}
 
-	return manifest, err
+	return manifest, layers, err
 }
 
 func readAndRenderMarkdown(
@@ -197,10 +290,10 @@ func createPlaygroundTemplate(
 	return parseTemplatePatterns(tpl, fsys, patterns)
 }
 
-func getPlaygroundManifest(name string) (api.PlaygroundManifest, error) {
+func getPlaygroundManifest(ctx context.Context, name string) (api.PlaygroundManifest, error) {
 	var b bytes.Buffer
 
-	cmd := exec.Command("labctl", "playground", "manifest", name)
+	cmd := exec.CommandContext(ctx, "labctl", "playground", "manifest", name)
 	cmd.Stdout = &b
 
 	if err := cmd.Run(); err != nil {
@@ -0,0 +1,118 @@
+package labx
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+
+	"github.com/goccy/go-yaml"
+	"github.com/iximiuz/labctl/api"
+
+	"github.com/sagikazarmark/labx/extended"
+)
+
+// resolvePlaygroundManifest returns the manifest of a base playground.
+//
+// Playgrounds found in local playground directories take precedence over the ones published on the server.
+func resolvePlaygroundManifest(name string, opts manifestOptions) (api.PlaygroundManifest, error) {
+	manifest, _, _, err := resolveBasePlayground(name, opts, true)
+
+	return manifest, err
+}
+
+// resolveBasePlayground returns the manifest of a base playground and the layers it consists of.
+//
+// Playgrounds published on the server are only fetched if remote is true (ok is false otherwise).
+func resolveBasePlayground(name string, opts manifestOptions, remote bool) (api.PlaygroundManifest, []playgroundLayer, bool, error) {
+	fsys, ok, err := findLocalPlayground(name, opts.PlaygroundDirs)
+	if err != nil {
+		return api.PlaygroundManifest{}, nil, false, fmt.Errorf("find local playground %s: %w", name, err)
+	}
+
+	if !ok {
+		if !remote {
+			return api.PlaygroundManifest{}, nil, false, nil
+		}
+
+		manifest, err := getPlaygroundManifest(opts.ctx(), name)
+		if err != nil {
+			return api.PlaygroundManifest{}, nil, false, fmt.Errorf("get playground %s: %w", name, err)
+		}
+
+		layer := playgroundLayer{
+			Name:   name,
+			Remote: true,
+			Spec:   manifest.Playground,
+		}
+
+		return manifest, []playgroundLayer{layer}, true, nil
+	}
+
+	manifest, layers, err := convertPlaygroundLayers(fsys, opts)
+	if err != nil {
+		return api.PlaygroundManifest{}, nil, false, fmt.Errorf("convert local playground %s: %w", name, err)
+	}
+
+	return manifest, layers, true, nil
+}
+
+// inheritsPlayground reports whether a playground inherits the spec of its base playground.
+//
+// Flexbox is an empty playground: there is nothing to inherit.
+func inheritsPlayground(base string) bool {
+	return base != "" && base != "flexbox"
+}
+
+// findLocalPlayground looks for a playground in the given playground directories.
+//
+// A playground matches if its directory name, its name or the name of any of its channels matches.
+func findLocalPlayground(name string, playgroundDirs []fs.FS) (fs.FS, bool, error) {
+	for _, playgroundDir := range playgroundDirs {
+		entries, err := fs.ReadDir(playgroundDir, ".")
+		if err != nil {
+			return nil, false, err
+		}
+
+		for _, entry := range entries {
+			if !entry.IsDir() {
+				continue
+			}
+
+			fsys, err := fs.Sub(playgroundDir, entry.Name())
+			if err != nil {
+				return nil, false, err
+			}
+
+			manifestFile, err := fsys.Open("manifest.yaml")
+			if errors.Is(err, fs.ErrNotExist) {
+				continue
+			} else if err != nil {
+				return nil, false, err
+			}
+
+			var manifest extended.PlaygroundManifest
+
+			err = yaml.NewDecoder(manifestFile).Decode(&manifest)
+			manifestFile.Close()
+			if err != nil {
+				return nil, false, fmt.Errorf("decode %s/manifest.yaml: %w", entry.Name(), err)
+			}
+
+			if manifest.Kind != "playground" {
+				continue
+			}
+
+			if entry.Name() == name || manifest.Name == name {
+				return fsys, true, nil
+			}
+
+			for _, channel := range manifest.Channels {
+				if channel.Name == name {
+					return fsys, true, nil
+				}
+			}
+		}
+	}
+
+	return nil, false, nil
+}
@@ -0,0 +1,106 @@
+package labx
+
+import (
+	"io/fs"
+	"testing"
+	"testing/fstest"
+
+	"github.com/iximiuz/labctl/api"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFindLocalPlayground(t *testing.T) {
+	playgrounds := fstest.MapFS{
+		"docker/manifest.yaml": &fstest.MapFile{Data: []byte(`kind: playground
+name: docker-local
+channels:
+  live:
+    name: docker-1234
+  dev:
+    name: docker-1234-5678
+`)},
+		"notes/README.md": &fstest.MapFile{Data: []byte("not a playground")},
+	}
+
+	for _, name := range []string{"docker", "docker-local", "docker-1234", "docker-1234-5678"} {
+		t.Run(name, func(t *testing.T) {
+			fsys, ok, err := findLocalPlayground(name, []fs.FS{playgrounds})
+			require.NoError(t, err)
+			require.True(t, ok)
+
+			_, err = fs.Stat(fsys, "manifest.yaml")
+			assert.NoError(t, err)
+		})
+	}
+
+	t.Run("missing", func(t *testing.T) {
+		_, ok, err := findLocalPlayground("k8s-omni", []fs.FS{playgrounds})
+		require.NoError(t, err)
+		assert.False(t, ok)
+	})
+}
+
+func TestLoadContentManifest_LocalPlayground(t *testing.T) {
+	playgrounds := fstest.MapFS{
+		"docker/manifest.yaml": &fstest.MapFile{Data: []byte(`kind: playground
+name: docker
+base: flexbox
+title: Docker
+channels:
+  live:
+    name: docker-1234
+  dev:
+    name: docker-1234-5678
+playground:
+  machines:
+    - name: docker
+      users:
+        - name: laborant
+          default: true
+      resources:
+        cpuCount: 2
+        ramSize: 4Gi
+      startupFiles:
+        - path: /etc/base
+          content: base
+`)},
+	}
+
+	fsys := fstest.MapFS{
+		"manifest.yaml": &fstest.MapFile{Data: []byte(`kind: challenge
+title: Challenge
+playground:
+  name: docker
+  machines:
+    - name: docker
+      startupFiles:
+        - path: /etc/content
+          content: content
+tasks:
+  verify:
+    machine: docker
+    run: "true"
+`)},
+	}
+
+	manifest, err := loadContentManifest(fsys, manifestOptions{
+		Channel:        "dev",
+		PlaygroundDirs: []fs.FS{playgrounds},
+	})
+	require.NoError(t, err)
+
+	converted := manifest.Convert()
+
+	assert.Equal(t, "docker-1234-5678", converted.Playground.Name)
+	require.Len(t, converted.Playground.Machines, 1)
+
+	machine := converted.Playground.Machines[0]
+
+	assert.Equal(t, []api.MachineUser{{Name: "laborant", Default: true}}, machine.Users)
+	assert.Equal(t, &api.MachineResources{CPUCount: 2, RAMSize: "4Gi"}, machine.Resources)
+	assert.Equal(t, []api.MachineStartupFile{
+		{Path: "/etc/base", Content: "base"},
+		{Path: "/etc/content", Content: "content"},
+	}, machine.StartupFiles)
+}
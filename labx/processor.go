@@ -1,16 +1,24 @@
 package labx
 
 import (
+	"cmp"
+	"context"
+	"encoding/base64"
 	"errors"
 	"fmt"
 	"io"
 	"io/fs"
+	"log/slog"
+	"maps"
+	"path"
+	"regexp"
+	"slices"
 	"strings"
+	"unicode/utf8"
 
-	"github.com/google/go-containerregistry/pkg/name"
-	"github.com/google/go-containerregistry/pkg/v1/remote"
 	"github.com/iximiuz/labctl/api"
 	"github.com/iximiuz/labctl/content"
+	"github.com/samber/lo"
 
 	"github.com/sagikazarmark/labx/extended"
 )
@@ -20,10 +28,14 @@ type PlaygroundProcessor struct {
 
 	Channel string
 
+	// Limits the playground is checked against (including the machines of the base playground).
+	Limits extended.PlatformLimits
+
 	MachinesProcessor MachinesProcessor
 }
 
 func (p PlaygroundProcessor) Process(
+	ctx context.Context,
 	playground extended.PlaygroundManifest,
 ) (extended.PlaygroundManifest, error) {
 	if p.Channel != "live" {
@@ -45,60 +57,239 @@ func (p PlaygroundProcessor) Process(
 		}
 	}
 
-	machines, err := p.MachinesProcessor.Process(playground.Playground.Machines)
+	machines, err := playground.Playground.Machines.Expand()
+	if err != nil {
+		return extended.PlaygroundManifest{}, err
+	}
+
+	networks, machines, addresses, err := allocateNetworks(
+		playground.Playground.NetworkPool,
+		playground.Playground.Networks,
+		machines,
+		playground.Playground.Base,
+	)
+	if err != nil {
+		return extended.PlaygroundManifest{}, err
+	}
+
+	playground.Playground.Networks = networks
+
+	machines = configureHosts(
+		playground.Playground.Domain,
+		playground.Playground.ClusterHosts,
+		machines,
+		playground.Playground.Base,
+	)
+
+	machinesProcessor := p.MachinesProcessor
+	machinesProcessor.MachineProcessor.Addresses = addresses
+	machinesProcessor.MachineProcessor.TaskNames = slices.Concat(
+		slices.Collect(maps.Keys(playground.Playground.InitTasks)),
+		slices.Collect(maps.Keys(playground.Playground.Base.InitTasks)),
+	)
+
+	machines, err = machinesProcessor.Process(ctx, machines)
+	if err != nil {
+		return extended.PlaygroundManifest{}, err
+	}
+
+	machines, err = checkLimits(p.Limits, machines, playground.Playground.Base)
 	if err != nil {
 		return extended.PlaygroundManifest{}, err
 	}
 
 	playground.Playground.Machines = machines
 
+	// Init tasks may run on machines inherited from the base playground
+	names := lo.Uniq(append(
+		lo.Map(machines, func(machine extended.PlaygroundMachine, _ int) string {
+			return machine.Name
+		}),
+		lo.Map(playground.Playground.Base.Machines, func(machine api.PlaygroundMachine, _ int) string {
+			return machine.Name
+		})...,
+	))
+
+	initTasks, err := playground.Playground.InitTasks.ExpandMachines(names)
+	if err != nil {
+		return extended.PlaygroundManifest{}, err
+	}
+
+	playground.Playground.InitTasks = initTasks
+
+	tabs, err := extended.GenerateTabs(
+		playground.Playground.AutoTabs,
+		extended.InheritMachines(
+			playground.Playground.Base.Machines,
+			machines.Convert(),
+			machines.Merges(),
+		),
+		playground.Playground.Tabs,
+	)
+	if err != nil {
+		return extended.PlaygroundManifest{}, err
+	}
+
+	playground.Playground.Tabs = tabs
+
 	return playground, nil
 }
 
+// allocateNetworks allocates subnets and addresses (see [extended.AllocateNetworks])
+// and adds startup files exposing the addresses of every machine to scripts (only if anything was allocated).
+func allocateNetworks(
+	pool string,
+	networks []api.PlaygroundNetwork,
+	machines extended.PlaygroundMachines,
+	base api.PlaygroundSpec,
+) ([]api.PlaygroundNetwork, extended.PlaygroundMachines, map[string]map[string]string, error) {
+	allocates := extended.AllocatesNetworks(networks, machines)
+
+	networks, machines, err := extended.AllocateNetworks(cmp.Or(pool, extended.DefaultNetworkPool), networks, machines, base)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	addresses := extended.MachineAddresses(
+		extended.InheritMachines(base.Machines, machines.Convert(), machines.Merges()),
+	)
+
+	if len(addresses) == 0 {
+		return networks, machines, nil, nil
+	}
+
+	// Manifests with hand-set addresses are left as they are
+	if !allocates {
+		return networks, machines, addresses, nil
+	}
+
+	envFiles := extended.NetworkEnvFiles(addresses)
+
+	for i := range machines {
+		machines[i].StartupFiles = append(slices.Clone(machines[i].StartupFiles), envFiles...)
+	}
+
+	return networks, machines, addresses, nil
+}
+
+// configureHosts applies the default domain to machines and adds cluster-wide /etc/hosts entries (if enabled).
+func configureHosts(
+	domain string,
+	clusterHosts bool,
+	machines extended.PlaygroundMachines,
+	base api.PlaygroundSpec,
+) extended.PlaygroundMachines {
+	machines = slices.Clone(machines)
+
+	for i, machine := range machines {
+		machines[i].Domain = cmp.Or(machine.Domain, domain)
+	}
+
+	if clusterHosts {
+		machines = extended.ClusterHosts(machines, base.Machines)
+	}
+
+	return machines
+}
+
+// checkLimits fits drives without a size into the disk limit and checks the playground (merged with the base playground) against the limits.
+func checkLimits(
+	limits extended.PlatformLimits,
+	machines extended.PlaygroundMachines,
+	base api.PlaygroundSpec,
+) (extended.PlaygroundMachines, error) {
+	machines, err := limits.FitDrives(machines, base.Machines, machines.Merges())
+	if err != nil {
+		return nil, err
+	}
+
+	err = limits.Check(extended.InheritMachines(base.Machines, machines.Convert(), machines.Merges()))
+	if err != nil {
+		return nil, err
+	}
+
+	return machines, nil
+}
+
 type MachinesProcessor struct {
 	MachineProcessor MachineProcessor
+
+	// Limiter bounds the number of machines and drives processed at the same time.
+	Limiter *Limiter
 }
 
 func (p MachinesProcessor) Process(
+	ctx context.Context,
 	machines []extended.PlaygroundMachine,
 ) ([]extended.PlaygroundMachine, error) {
 	machineProcessor := p.MachineProcessor
+	machineProcessor.Limiter = p.Limiter
 
-	// Set a default drive size to make sure we don't exceed the limit
-	// This is best effort: you should set the size instead
-	if machineProcessor.DriveProcessor.DefaultSize == "" {
-		if len(machines) > 3 {
-			machineProcessor.DriveProcessor.DefaultSize = "30GiB"
-		}
-	}
+	processed := make([]extended.PlaygroundMachine, len(machines))
 
-	for i, machine := range machines {
-		machine, err := machineProcessor.Process(machine)
+	err := forEach(ctx, p.Limiter, len(machines), func(ctx context.Context, i int) error {
+		machine, err := machineProcessor.Process(ctx, machines[i])
 		if err != nil {
-			return nil, fmt.Errorf("processing machine %s: %w", machine.Name, err)
+			return fmt.Errorf("processing machine %s: %w", machines[i].Name, err)
 		}
 
-		machines[i] = machine
+		processed[i] = machine
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	return machines, nil
+	return processed, nil
 }
 
 type MachineProcessor struct {
 	UserProcessor        MachineUserProcessor
 	DriveProcessor       MachineDriveProcessor
 	StartupFileProcessor MachineStartupFileProcessor
+
+	// Addresses of all machines by network (exposed to templates).
+	Addresses map[string]map[string]string
+
+	// TaskNames are the names of tasks defined by the playground or content (not reused by generated init tasks).
+	TaskNames []string
+
+	// Limiter bounds the number of drives processed at the same time.
+	Limiter *Limiter
 }
 
 func (p MachineProcessor) Process(
+	ctx context.Context,
 	machine extended.PlaygroundMachine,
 ) (extended.PlaygroundMachine, error) {
+	err := machine.Merge.Validate()
+	if err != nil {
+		return extended.PlaygroundMachine{}, err
+	}
+
+	// Templates are rendered in the context of the current machine
+	userProcessor := p.UserProcessor
+	userProcessor.Machine = machine
+	userProcessor.Renderer = userProcessor.Renderer.withAddresses(p.Addresses)
+
+	startupFileProcessor := p.StartupFileProcessor
+	startupFileProcessor.Machine = machine
+	startupFileProcessor.Renderer = startupFileProcessor.Renderer.withAddresses(p.Addresses)
+
+	driveProcessor := p.DriveProcessor
+	driveProcessor.Machine = machine.Name
+
+	// Machines may share items with other machines (e.g. replicas)
+	machine.Users = slices.Clone(machine.Users)
+	machine.Drives = slices.Clone(machine.Drives)
+
 	for i, user := range machine.Users {
-		user, err := p.UserProcessor.Process(user)
+		user, err := userProcessor.Process(user)
 		if err != nil {
 			return extended.PlaygroundMachine{}, fmt.Errorf(
 				"processing user %s: %w",
-				user.Name,
+				machine.Users[i].Name,
 				err,
 			)
 		}
@@ -106,17 +297,24 @@ func (p MachineProcessor) Process(
 		machine.Users[i] = user
 	}
 
-	for i, drive := range machine.Drives {
-		drive, err := p.DriveProcessor.Process(drive)
+	err = forEach(ctx, p.Limiter, len(machine.Drives), func(ctx context.Context, i int) error {
+		drive, err := driveProcessor.Process(ctx, machine.Drives[i])
 		if err != nil {
-			return extended.PlaygroundMachine{}, fmt.Errorf("processing drive %d: %w", i, err)
+			return fmt.Errorf("processing drive %d: %w", i, err)
 		}
 
 		machine.Drives[i] = drive
+
+		return nil
+	})
+	if err != nil {
+		return extended.PlaygroundMachine{}, err
 	}
 
+	var startupFiles extended.MachineStartupFiles
+
 	for i, startupFile := range machine.StartupFiles {
-		startupFile, err := p.StartupFileProcessor.Process(startupFile)
+		processed, err := startupFileProcessor.Process(startupFile)
 		if err != nil {
 			return extended.PlaygroundMachine{}, fmt.Errorf(
 				"processing startup file %d: %w",
@@ -125,14 +323,37 @@ func (p MachineProcessor) Process(
 			)
 		}
 
-		machine.StartupFiles[i] = startupFile
+		startupFiles = append(startupFiles, processed...)
 	}
 
+	machine.StartupFiles = startupFiles
+
+	// Binary startup files are decoded by init tasks
+	for _, startupFile := range machine.StartupFiles {
+		if startupFile.Encoding != extended.EncodingBase64 {
+			continue
+		}
+
+		err := validateDecodedStartupFile(startupFile)
+		if err != nil {
+			return extended.PlaygroundMachine{}, fmt.Errorf("startup file %s: %w", startupFile.Path, err)
+		}
+	}
+
+	machine = machine.DecodeStartupFiles(p.TaskNames)
+
+	// Users are configured by startup files and init tasks
+	machine = machine.ConfigureUsers()
+
 	return machine, nil
 }
 
 type MachineUserProcessor struct {
 	Fsys fs.FS
+
+	// Renderer renders welcome messages marked as templates (left as is when nil).
+	Renderer *TemplateRenderer
+	Machine  extended.PlaygroundMachine
 }
 
 func (p MachineUserProcessor) Process(user extended.MachineUser) (extended.MachineUser, error) {
@@ -150,24 +371,127 @@ func (p MachineUserProcessor) Process(user extended.MachineUser) (extended.Machi
 		user.Welcome = string(welcome)
 	}
 
+	if user.Template && p.Renderer != nil {
+		welcome, err := p.Renderer.Render("welcome", user.Welcome, p.Machine, user)
+		if err != nil {
+			return extended.MachineUser{}, err
+		}
+
+		user.Welcome = welcome
+		user.Template = false
+	}
+
+	if user.AuthorizedKeysFile != "" {
+		if user.AuthorizedKeys != "" {
+			return extended.MachineUser{}, errors.New("authorizedKeys and authorizedKeysFile are mutually exclusive")
+		}
+
+		keys, err := fs.ReadFile(p.Fsys, user.AuthorizedKeysFile)
+		if err != nil {
+			return extended.MachineUser{}, fmt.Errorf("read authorized keys: %w", err)
+		}
+
+		user.AuthorizedKeys = string(keys)
+		user.AuthorizedKeysFile = ""
+	}
+
+	err := validateMachineUser(user)
+	if err != nil {
+		return extended.MachineUser{}, err
+	}
+
 	return user, nil
 }
 
+// groupNamePattern matches valid (portable) group names.
+var groupNamePattern = regexp.MustCompile(`^[a-z_][a-z0-9_-]*$`)
+
+// userNamePattern matches valid (portable) user names (at most 32 characters).
+//
+// User names are used in generated scripts and sudoers files without quoting.
+var userNamePattern = regexp.MustCompile(`^[a-z_][a-z0-9_.-]{0,31}$`)
+
+// fileModePattern matches octal file modes.
+var fileModePattern = regexp.MustCompile(`^[0-7]{3,4}$`)
+
+// validateDecodedStartupFile validates the fields of a binary startup file used in its decode task.
+func validateDecodedStartupFile(startupFile extended.MachineStartupFile) error {
+	if startupFile.Mode != "" && !fileModePattern.MatchString(startupFile.Mode) {
+		return fmt.Errorf("invalid mode %q: must be octal", startupFile.Mode)
+	}
+
+	if startupFile.Owner == "" {
+		return nil
+	}
+
+	user, group, hasGroup := strings.Cut(startupFile.Owner, ":")
+
+	if !userNamePattern.MatchString(user) || (hasGroup && !groupNamePattern.MatchString(group)) {
+		return fmt.Errorf("invalid owner %q: must be user[:group]", startupFile.Owner)
+	}
+
+	return nil
+}
+
+func validateMachineUser(user extended.MachineUser) error {
+	if !userNamePattern.MatchString(user.Name) {
+		return fmt.Errorf("invalid user name %q", user.Name)
+	}
+
+	if user.Sudo && user.Name == "root" {
+		return errors.New("sudo can't be enabled for root")
+	}
+
+	for _, group := range user.Groups {
+		if !groupNamePattern.MatchString(group) {
+			return fmt.Errorf("invalid group name %q", group)
+		}
+	}
+
+	if user.Shell != "" && !path.IsAbs(user.Shell) {
+		return fmt.Errorf("shell must be an absolute path: %s", user.Shell)
+	}
+
+	for i, line := range strings.Split(user.AuthorizedKeys, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		// Lines are "[options] keytype base64-key [comment]"
+		if len(strings.Fields(line)) < 2 {
+			return fmt.Errorf("authorized keys line %d: invalid public key", i+1)
+		}
+	}
+
+	return nil
+}
+
 type MachineDriveProcessor struct {
-	// TODO: this won' work for course modules and lessons
+	// Content the default image belongs to (for lessons: the course and the path of the lesson in the course).
 	ContentKind content.ContentKind
 	ContentName string
 	Channel     string
 
+	// Extra data available in drive source templates.
+	Extra map[string]any
+
 	// Use this image repository if the image is not specified.
 	DefaultImageRepo string
 
 	// Use this size if the size is missing from the drive.
 	DefaultSize string
+
+	// Sources resolves drive sources (defaults to pinning oci:// sources).
+	Sources *DriveSourceRegistry
+
+	// Images verifies resolved drive images (not verified when nil).
+	Images  *ImageVerifier
+	Machine string
 }
 
-func (p MachineDriveProcessor) Process(drive api.MachineDrive) (api.MachineDrive, error) {
-	source, err := p.processSource(drive.Source)
+func (p MachineDriveProcessor) Process(ctx context.Context, drive api.MachineDrive) (api.MachineDrive, error) {
+	source, err := p.processSource(ctx, drive.Source)
 	if err != nil {
 		return api.MachineDrive{}, err
 	}
@@ -178,75 +502,95 @@ func (p MachineDriveProcessor) Process(drive api.MachineDrive) (api.MachineDrive
 		drive.Size = p.DefaultSize
 	}
 
-	return drive, nil
-}
-
-func (p MachineDriveProcessor) processSource(source string) (string, error) {
-	// Not an OCI source, stop processing
-	if !strings.HasPrefix(source, "oci://") {
-		return source, nil
-	}
-
-	source = strings.TrimPrefix(source, "oci://")
-
-	// Fallback to default source
-	if source == "" {
-		source = fmt.Sprintf(
-			"%s/%s/%s:%s",
-			p.DefaultImageRepo,
-			p.ContentKind.Plural(),
-			p.ContentName,
-			p.Channel,
-		)
+	if p.Images != nil {
+		p.Images.Verify(ctx, p.Machine, drive)
 	}
 
-	// Replace channel placeholder
-	source = strings.ReplaceAll(source, "__CHANNEL__", p.Channel)
-
-	if strings.HasPrefix(source, "ghcr.io/iximiuz/labs/rootfs") {
-		return fmt.Sprintf("oci://%s", source), nil
-	}
+	return drive, nil
+}
 
-	ref, err := name.ParseReference(source)
-	if err != nil {
-		return "", err
+func (p MachineDriveProcessor) processSource(ctx context.Context, source string) (string, error) {
+	sources := p.Sources
+	if sources == nil {
+		sources = newDriveSourceRegistry(DriveSourceConfig{}, p.DefaultImageRepo, createTemplateFuncs(nil, nil))
 	}
 
-	// Already pinned to a digest
-	if _, ok := ref.(name.Digest); ok {
-		return source, nil
-	}
+	return sources.Resolve(ctx, source, DriveSourceData{
+		Channel: p.Channel,
+		Name:    p.ContentName,
+		Extra:   p.Extra,
+		Kind:    string(p.ContentKind),
+		Kinds:   p.ContentKind.Plural(),
+	})
+}
 
-	desc, err := remote.Get(ref)
-	if err != nil {
-		return "", err
-	}
+// Startup files are embedded into the playground manifest: warn about directories that bloat it.
+const startupFilesSizeWarningThreshold = 256 << 10
 
-	return fmt.Sprintf("oci://%s@%s", ref.String(), desc.Digest.String()), nil
-}
+// Maximum size of a single startup file (after encoding).
+const maxStartupFileSize = 1 << 20
 
 type MachineStartupFileProcessor struct {
 	Fsys fs.FS
 
 	DefaultOwner string
 	DefaultMode  string
+
+	// Renderer renders startup files marked as templates (left as is when nil).
+	Renderer *TemplateRenderer
+	Machine  extended.PlaygroundMachine
 }
 
+// Process processes a startup file.
+//
+// Startup files with fromDir are expanded into a startup file per file in the directory.
 func (p MachineStartupFileProcessor) Process(
 	startupFile extended.MachineStartupFile,
-) (extended.MachineStartupFile, error) {
+) ([]extended.MachineStartupFile, error) {
+	if startupFile.FromDir != "" {
+		return p.processDir(startupFile)
+	}
+
+	switch startupFile.Encoding {
+	case "":
+	case extended.EncodingBase64:
+		if startupFile.FromFile == "" {
+			// Inline content is already encoded
+			content := strings.Join(strings.Fields(startupFile.Content), "")
+
+			_, err := base64.StdEncoding.DecodeString(content)
+			if err != nil {
+				return nil, fmt.Errorf("invalid base64 content: %w", err)
+			}
+
+			startupFile.Content = content
+		}
+	default:
+		return nil, fmt.Errorf("unsupported encoding: %s", startupFile.Encoding)
+	}
+
 	if startupFile.FromFile != "" {
 		contentFile, err := p.Fsys.Open(startupFile.FromFile)
 		if err != nil {
-			return extended.MachineStartupFile{}, err
+			return nil, err
 		}
 
 		content, err := io.ReadAll(contentFile)
 		if err != nil {
-			return extended.MachineStartupFile{}, err
+			return nil, err
 		}
 
-		startupFile.Content = string(content)
+		startupFile = setStartupFileContent(startupFile, content)
+	}
+
+	startupFile, err := p.render(startupFile)
+	if err != nil {
+		return nil, err
+	}
+
+	err = checkStartupFileSize(startupFile)
+	if err != nil {
+		return nil, err
 	}
 
 	if startupFile.Owner == "" {
@@ -257,5 +601,192 @@ func (p MachineStartupFileProcessor) Process(
 		startupFile.Mode = p.DefaultMode
 	}
 
+	return []extended.MachineStartupFile{startupFile}, nil
+}
+
+func (p MachineStartupFileProcessor) processDir(
+	startupFile extended.MachineStartupFile,
+) ([]extended.MachineStartupFile, error) {
+	if startupFile.FromFile != "" || startupFile.Content != "" {
+		return nil, errors.New("fromDir cannot be combined with fromFile or content")
+	}
+
+	err := validatePatterns(startupFile.Include, startupFile.Exclude)
+	if err != nil {
+		return nil, err
+	}
+
+	var startupFiles []extended.MachineStartupFile
+
+	var size int
+
+	err = fs.WalkDir(p.Fsys, path.Clean(startupFile.FromDir), func(filePath string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if d.IsDir() {
+			return nil
+		}
+
+		relPath := filePath
+		if dir := path.Clean(startupFile.FromDir); dir != "." {
+			relPath = strings.TrimPrefix(filePath, dir+"/")
+		}
+
+		if len(startupFile.Include) > 0 && !matchesAny(startupFile.Include, relPath) {
+			return nil
+		}
+
+		if matchesAny(startupFile.Exclude, relPath) {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		content, err := fs.ReadFile(p.Fsys, filePath)
+		if err != nil {
+			return err
+		}
+
+		size += len(content)
+
+		file := extended.MachineStartupFile{
+			Path:     path.Join(startupFile.Path, relPath),
+			Mode:     startupFile.Mode,
+			Owner:    startupFile.Owner,
+			Append:   startupFile.Append,
+			Template: startupFile.Template,
+			Encoding: startupFile.Encoding,
+		}
+
+		file = setStartupFileContent(file, content)
+
+		file, err = p.render(file)
+		if err != nil {
+			return err
+		}
+
+		err = checkStartupFileSize(file)
+		if err != nil {
+			return err
+		}
+
+		// Preserve executable bits
+		if file.Mode == "" {
+			file.Mode = "644"
+
+			if info.Mode().Perm()&0o111 != 0 {
+				file.Mode = "755"
+			}
+		}
+
+		if file.Owner == "" {
+			file.Owner = p.DefaultOwner
+		}
+
+		startupFiles = append(startupFiles, file)
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("expanding directory %s: %w", startupFile.FromDir, err)
+	}
+
+	if size > startupFilesSizeWarningThreshold {
+		slog.Warn(
+			"startup files directory is large: consider moving files to a drive",
+			slog.String("dir", startupFile.FromDir),
+			slog.Int("files", len(startupFiles)),
+			slog.Int("size", size),
+		)
+	}
+
+	return startupFiles, nil
+}
+
+func (p MachineStartupFileProcessor) render(
+	startupFile extended.MachineStartupFile,
+) (extended.MachineStartupFile, error) {
+	if !startupFile.Template || p.Renderer == nil {
+		return startupFile, nil
+	}
+
+	if startupFile.Encoding == extended.EncodingBase64 {
+		return extended.MachineStartupFile{}, fmt.Errorf("binary startup file %s cannot be a template", startupFile.Path)
+	}
+
+	content, err := p.Renderer.Render(startupFile.Path, startupFile.Content, p.Machine, extended.MachineUser{})
+	if err != nil {
+		return extended.MachineStartupFile{}, err
+	}
+
+	startupFile.Content = content
+	startupFile.Template = false
+
 	return startupFile, nil
 }
+
+// setStartupFileContent sets the content of a startup file, encoding binary (non UTF-8) content with base64.
+func setStartupFileContent(startupFile extended.MachineStartupFile, content []byte) extended.MachineStartupFile {
+	if startupFile.Encoding == extended.EncodingBase64 || !utf8.Valid(content) {
+		startupFile.Content = base64.StdEncoding.EncodeToString(content)
+		startupFile.Encoding = extended.EncodingBase64
+
+		return startupFile
+	}
+
+	startupFile.Content = string(content)
+
+	return startupFile
+}
+
+func checkStartupFileSize(startupFile extended.MachineStartupFile) error {
+	if len(startupFile.Content) <= maxStartupFileSize {
+		return nil
+	}
+
+	encoding := ""
+	if startupFile.Encoding == extended.EncodingBase64 {
+		encoding = " (base64 encoded)"
+	}
+
+	return fmt.Errorf(
+		"startup file %s is too large: %d bytes%s, the limit is %d bytes (ship it in a drive image instead)",
+		startupFile.Path,
+		len(startupFile.Content),
+		encoding,
+		maxStartupFileSize,
+	)
+}
+
+// validatePatterns makes sure glob patterns are valid (matching ignores invalid patterns).
+func validatePatterns(patterns ...[]string) error {
+	for _, pattern := range slices.Concat(patterns...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid glob pattern %q: %w", pattern, err)
+		}
+	}
+
+	return nil
+}
+
+// matchesAny reports whether a relative path or its file name matches any of the glob patterns.
+//
+// Patterns must be validated with [validatePatterns] first.
+func matchesAny(patterns []string, relPath string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, relPath); ok {
+			return true
+		}
+
+		if ok, _ := path.Match(pattern, path.Base(relPath)); ok {
+			return true
+		}
+	}
+
+	return false
+}
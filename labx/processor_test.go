@@ -0,0 +1,422 @@
+package labx
+
+import (
+	"fmt"
+	"testing"
+	"testing/fstest"
+
+	"github.com/iximiuz/labctl/api"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sagikazarmark/labx/extended"
+)
+
+func TestMachineStartupFileProcessor_FromDir(t *testing.T) {
+	fsys := fstest.MapFS{
+		"scripts/check.sh":        &fstest.MapFile{Data: []byte("#!/bin/sh"), Mode: 0o755},
+		"scripts/lib/helpers.sh":  &fstest.MapFile{Data: []byte("helpers() { true; }"), Mode: 0o644},
+		"scripts/README.md":       &fstest.MapFile{Data: []byte("# Scripts"), Mode: 0o644},
+		"scripts/tmp/scratch.txt": &fstest.MapFile{Data: []byte("scratch"), Mode: 0o644},
+	}
+
+	processor := MachineStartupFileProcessor{
+		Fsys:         fsys,
+		DefaultOwner: "root:root",
+	}
+
+	t.Run("expand", func(t *testing.T) {
+		startupFiles, err := processor.Process(extended.MachineStartupFile{
+			Path:    "/opt/scripts",
+			FromDir: "scripts/",
+			Exclude: []string{"*.md", "tmp/*"},
+		})
+		require.NoError(t, err)
+
+		expected := []extended.MachineStartupFile{
+			{Path: "/opt/scripts/check.sh", Content: "#!/bin/sh", Mode: "755", Owner: "root:root"},
+			{Path: "/opt/scripts/lib/helpers.sh", Content: "helpers() { true; }", Mode: "644", Owner: "root:root"},
+		}
+
+		assert.Equal(t, expected, startupFiles)
+	})
+
+	t.Run("include", func(t *testing.T) {
+		startupFiles, err := processor.Process(extended.MachineStartupFile{
+			Path:    "/usr/local/bin",
+			FromDir: "scripts",
+			Include: []string{"check.sh"},
+			Mode:    "700",
+			Owner:   "laborant:laborant",
+		})
+		require.NoError(t, err)
+
+		expected := []extended.MachineStartupFile{
+			{Path: "/usr/local/bin/check.sh", Content: "#!/bin/sh", Mode: "700", Owner: "laborant:laborant"},
+		}
+
+		assert.Equal(t, expected, startupFiles)
+	})
+
+	t.Run("invalid pattern", func(t *testing.T) {
+		_, err := processor.Process(extended.MachineStartupFile{
+			Path:    "/opt/scripts",
+			FromDir: "scripts",
+			Exclude: []string{"*.md", "[tmp"},
+		})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), `invalid glob pattern "[tmp"`)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		_, err := processor.Process(extended.MachineStartupFile{
+			Path:     "/opt/scripts",
+			FromDir:  "scripts",
+			FromFile: "scripts/check.sh",
+		})
+		require.Error(t, err)
+	})
+}
+
+func TestMachineProcessor_Templates(t *testing.T) {
+	fsys := fstest.MapFS{
+		"motd.tpl":    &fstest.MapFile{Data: []byte(`Welcome to {{ .Machine.Hostname }} ({{ .Channel }})`)},
+		"welcome.tpl": &fstest.MapFile{Data: []byte(`Hi {{ .User.Name | toUpper }}, Dagger {{ .Extra.bake.dagger }}`)},
+		"raw.sh":      &fstest.MapFile{Data: []byte(`echo "{{ not rendered }}"`)},
+	}
+
+	renderer := &TemplateRenderer{
+		Funcs:   createTemplateFuncs(fsys, nil),
+		Channel: "dev",
+		Extra:   map[string]any{"bake": map[string]any{"dagger": "v0.18.0"}},
+	}
+
+	processor := MachineProcessor{
+		UserProcessor: MachineUserProcessor{
+			Fsys:     fsys,
+			Renderer: renderer,
+		},
+		StartupFileProcessor: MachineStartupFileProcessor{
+			Fsys:     fsys,
+			Renderer: renderer,
+		},
+	}
+
+	machine, err := processor.Process(t.Context(), extended.PlaygroundMachine{
+		Name:     "dev",
+		Hostname: "devbox",
+		Users: extended.MachineUsers{
+			{Name: "laborant", WelcomeFile: "welcome.tpl", Template: true},
+		},
+		StartupFiles: extended.MachineStartupFiles{
+			{Path: "/etc/motd", FromFile: "motd.tpl", Template: true},
+			{Path: "/etc/channel", Content: "{{ .Channel }}-{{ .Machine.Name }}", Template: true},
+			{Path: "/opt/raw.sh", FromFile: "raw.sh"},
+		},
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, "Hi LABORANT, Dagger v0.18.0", machine.Users[0].Welcome)
+	assert.Equal(t, "Welcome to devbox (dev)", machine.StartupFiles[0].Content)
+	assert.Equal(t, "dev-dev", machine.StartupFiles[1].Content)
+	assert.Equal(t, `echo "{{ not rendered }}"`, machine.StartupFiles[2].Content)
+}
+
+func TestPlaygroundProcessor_Replicas(t *testing.T) {
+	renderer := &TemplateRenderer{
+		Funcs:   createTemplateFuncs(fstest.MapFS{}, nil),
+		Channel: "dev",
+	}
+
+	processor := PlaygroundProcessor{
+		Channel: "dev",
+		MachinesProcessor: MachinesProcessor{
+			MachineProcessor: MachineProcessor{
+				StartupFileProcessor: MachineStartupFileProcessor{
+					Renderer: renderer,
+				},
+			},
+		},
+	}
+
+	playground, err := processor.Process(t.Context(), extended.PlaygroundManifest{
+		Name:     "cluster",
+		Channels: map[string]extended.Channel{"dev": {Name: "cluster-dev"}},
+		Playground: extended.PlaygroundSpec{
+			Machines: extended.PlaygroundMachines{
+				{
+					Name:     "node",
+					Replicas: 2,
+					StartupFiles: extended.MachineStartupFiles{
+						{Path: "/etc/node", Content: "{{ .Name }}={{ .Index }} ({{ .Channel }})", Template: true},
+						{Path: "/usr/local/bin/containers", Content: "docker ps --format '{{ .Names }}'"},
+					},
+				},
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	machines := playground.Playground.Machines
+	require.Len(t, machines, 2)
+
+	for i, machine := range machines {
+		require.Len(t, machine.StartupFiles, 2)
+		assert.Equal(t, fmt.Sprintf("node-%02d=%d (dev)", i+1, i+1), machine.StartupFiles[0].Content)
+		assert.Equal(t, "docker ps --format '{{ .Names }}'", machine.StartupFiles[1].Content)
+	}
+}
+
+func TestMachineStartupFileProcessor_Binary(t *testing.T) {
+	fsys := fstest.MapFS{
+		"tool":      &fstest.MapFile{Data: []byte{0x7f, 'E', 'L', 'F', 0xff, 0xfe}},
+		"cert.pem":  &fstest.MapFile{Data: []byte("-----BEGIN CERTIFICATE-----")},
+		"large.bin": &fstest.MapFile{Data: make([]byte, maxStartupFileSize+1)},
+	}
+
+	processor := MachineStartupFileProcessor{
+		Fsys: fsys,
+	}
+
+	t.Run("detect", func(t *testing.T) {
+		startupFiles, err := processor.Process(extended.MachineStartupFile{Path: "/usr/local/bin/tool", FromFile: "tool"})
+		require.NoError(t, err)
+
+		assert.Equal(t, "f0VMRv/+", startupFiles[0].Content)
+		assert.Equal(t, extended.EncodingBase64, startupFiles[0].Encoding)
+	})
+
+	t.Run("explicit", func(t *testing.T) {
+		startupFiles, err := processor.Process(extended.MachineStartupFile{
+			Path:     "/etc/cert.pem",
+			FromFile: "cert.pem",
+			Encoding: extended.EncodingBase64,
+		})
+		require.NoError(t, err)
+
+		assert.Equal(t, "LS0tLS1CRUdJTiBDRVJUSUZJQ0FURS0tLS0t", startupFiles[0].Content)
+	})
+
+	t.Run("inline", func(t *testing.T) {
+		startupFiles, err := processor.Process(extended.MachineStartupFile{
+			Path:     "/etc/cert.pem",
+			Content:  "LS0tLS1CRUdJTiBD\nRVJUSUZJQ0FURS0tLS0t\n",
+			Encoding: extended.EncodingBase64,
+		})
+		require.NoError(t, err)
+
+		assert.Equal(t, "LS0tLS1CRUdJTiBDRVJUSUZJQ0FURS0tLS0t", startupFiles[0].Content)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		_, err := processor.Process(extended.MachineStartupFile{
+			Path:     "/etc/cert.pem",
+			Content:  "not base64!",
+			Encoding: extended.EncodingBase64,
+		})
+		require.Error(t, err)
+	})
+
+	t.Run("oversize", func(t *testing.T) {
+		_, err := processor.Process(extended.MachineStartupFile{Path: "/opt/large.bin", FromFile: "large.bin"})
+		require.ErrorContains(t, err, "startup file /opt/large.bin is too large")
+	})
+}
+
+func TestMachineProcessor_Binary(t *testing.T) {
+	processor := MachineProcessor{TaskNames: []string{"init_dev_decode_opt_tool"}}
+
+	machine, err := processor.Process(t.Context(), extended.PlaygroundMachine{
+		Name: "dev",
+		StartupFiles: extended.MachineStartupFiles{
+			{Path: "/opt/tool", Content: "f0VMRv/+", Mode: "0755", Owner: "laborant:docker", Encoding: extended.EncodingBase64},
+		},
+	})
+	require.NoError(t, err)
+
+	assert.NotContains(t, machine.InitTasks, "init_dev_decode_opt_tool")
+	assert.Contains(t, machine.InitTasks, "init_dev_decode_opt_tool_2")
+
+	tests := []struct {
+		name        string
+		startupFile extended.MachineStartupFile
+		err         string
+	}{
+		{"invalid mode", extended.MachineStartupFile{Mode: "755; reboot"}, `invalid mode "755; reboot"`},
+		{"symbolic mode", extended.MachineStartupFile{Mode: "u+x"}, `invalid mode "u+x"`},
+		{"invalid owner", extended.MachineStartupFile{Owner: "root$(reboot)"}, `invalid owner "root$(reboot)"`},
+		{"invalid group", extended.MachineStartupFile{Owner: "root:Wheel Users"}, `invalid owner "root:Wheel Users"`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			startupFile := test.startupFile
+			startupFile.Path = "/opt/tool"
+			startupFile.Content = "f0VMRv/+"
+			startupFile.Encoding = extended.EncodingBase64
+
+			_, err := processor.Process(t.Context(), extended.PlaygroundMachine{
+				Name:         "dev",
+				StartupFiles: extended.MachineStartupFiles{startupFile},
+			})
+			require.ErrorContains(t, err, test.err)
+		})
+	}
+}
+
+func TestMachineProcessor_Users(t *testing.T) {
+	fsys := fstest.MapFS{
+		"keys/laborant.pub": &fstest.MapFile{Data: []byte("# laptop\nssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIDummy laborant@laptop\n")},
+		"keys/invalid.pub":  &fstest.MapFile{Data: []byte("not-a-key\n")},
+	}
+
+	processor := MachineProcessor{
+		UserProcessor: MachineUserProcessor{
+			Fsys: fsys,
+		},
+	}
+
+	machine, err := processor.Process(t.Context(), extended.PlaygroundMachine{
+		Name: "dev",
+		Users: extended.MachineUsers{
+			{Name: "root"},
+			{
+				Name:               "laborant",
+				Default:            true,
+				Groups:             []string{"docker"},
+				Sudo:               true,
+				AuthorizedKeysFile: "keys/laborant.pub",
+				Shell:              "/bin/zsh",
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, extended.MachineStartupFiles{
+		{
+			Path:    "/etc/sudoers.d/90-labx-laborant",
+			Content: "laborant ALL=(ALL) NOPASSWD:ALL\n",
+			Mode:    "440",
+			Owner:   "root:root",
+		},
+		{
+			Path:    "/home/laborant/.ssh/authorized_keys",
+			Content: "# laptop\nssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIDummy laborant@laptop\n",
+			Append:  true,
+		},
+	}, machine.StartupFiles)
+
+	require.Contains(t, machine.InitTasks, "init_dev_user_laborant")
+	assert.Equal(t, `getent group docker >/dev/null || groupadd docker
+usermod -aG docker laborant
+usermod -s '/bin/zsh' laborant
+chmod 700 '/home/laborant/.ssh'
+chmod 600 '/home/laborant/.ssh/authorized_keys'
+chown -R laborant: '/home/laborant/.ssh'
+`, machine.InitTasks["init_dev_user_laborant"].Run)
+	assert.NotContains(t, machine.InitTasks, "init_dev_user_root")
+
+	tests := []struct {
+		name string
+		user extended.MachineUser
+		err  string
+	}{
+		{"invalid name", extended.MachineUser{Name: "laborant; reboot"}, `invalid user name "laborant; reboot"`},
+		{"sudo for root", extended.MachineUser{Name: "root", Sudo: true}, "sudo can't be enabled for root"},
+		{"invalid group", extended.MachineUser{Name: "laborant", Groups: []string{"Docker Users"}}, `invalid group name "Docker Users"`},
+		{"relative shell", extended.MachineUser{Name: "laborant", Shell: "zsh"}, "shell must be an absolute path"},
+		{"invalid key", extended.MachineUser{Name: "laborant", AuthorizedKeysFile: "keys/invalid.pub"}, "authorized keys line 1"},
+		{"missing keys file", extended.MachineUser{Name: "laborant", AuthorizedKeysFile: "keys/missing.pub"}, "read authorized keys"},
+		{
+			"both keys and keys file",
+			extended.MachineUser{Name: "laborant", AuthorizedKeys: "ssh-ed25519 AAAA", AuthorizedKeysFile: "keys/laborant.pub"},
+			"mutually exclusive",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := processor.Process(t.Context(), extended.PlaygroundMachine{
+				Name:  "dev",
+				Users: extended.MachineUsers{test.user},
+			})
+			require.ErrorContains(t, err, test.err)
+		})
+	}
+}
+
+func TestPlaygroundProcessor_Networks(t *testing.T) {
+	renderer := &TemplateRenderer{
+		Funcs: createTemplateFuncs(fstest.MapFS{}, nil),
+	}
+
+	processor := PlaygroundProcessor{
+		Channel: "dev",
+		MachinesProcessor: MachinesProcessor{
+			MachineProcessor: MachineProcessor{
+				StartupFileProcessor: MachineStartupFileProcessor{
+					Renderer: renderer,
+				},
+			},
+		},
+	}
+
+	iface := func() *api.MachineNetwork {
+		return &api.MachineNetwork{Interfaces: []api.MachineNetworkInterface{{Network: "local"}}}
+	}
+
+	playground, err := processor.Process(t.Context(), extended.PlaygroundManifest{
+		Name:     "cluster",
+		Channels: map[string]extended.Channel{"dev": {Name: "cluster-dev"}},
+		Playground: extended.PlaygroundSpec{
+			Networks: []api.PlaygroundNetwork{{Name: "local"}},
+			Machines: extended.PlaygroundMachines{
+				{
+					Name:    "node-01",
+					Network: iface(),
+					StartupFiles: extended.MachineStartupFiles{
+						{
+							Path:     "/etc/peer",
+							Content:  `{{ .Machine.Address "local" }} -> {{ index .Addresses "node-02" "local" }}`,
+							Template: true,
+						},
+					},
+				},
+				{Name: "node-02", Network: iface()},
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, "10.100.0.0/24", playground.Playground.Networks[0].Subnet)
+
+	startupFiles := playground.Playground.Machines[0].StartupFiles
+	require.Len(t, startupFiles, 3)
+	assert.Equal(t, "10.100.0.2 -> 10.100.0.3", startupFiles[0].Content)
+	assert.Equal(t, "LABX_NODE_01_LOCAL_IP=10.100.0.2\nLABX_NODE_02_LOCAL_IP=10.100.0.3\n", startupFiles[1].Content)
+	assert.Equal(t, "/etc/profile.d/labx-network.sh", startupFiles[2].Path)
+}
+
+func TestPlaygroundProcessor_Networks_Unchanged(t *testing.T) {
+	processor := PlaygroundProcessor{Channel: "dev"}
+
+	playground, err := processor.Process(t.Context(), extended.PlaygroundManifest{
+		Name:     "cluster",
+		Channels: map[string]extended.Channel{"dev": {Name: "cluster-dev"}},
+		Playground: extended.PlaygroundSpec{
+			Networks: []api.PlaygroundNetwork{{Name: "local", Subnet: "172.16.0.0/24"}},
+			Machines: extended.PlaygroundMachines{
+				{
+					Name: "node-01",
+					Network: &api.MachineNetwork{
+						Interfaces: []api.MachineNetworkInterface{{Network: "local", Address: "172.16.0.2/24"}},
+					},
+				},
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, "172.16.0.0/24", playground.Playground.Networks[0].Subnet)
+	assert.Empty(t, playground.Playground.Machines[0].StartupFiles)
+}
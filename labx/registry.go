@@ -0,0 +1,244 @@
+package labx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/samber/lo"
+)
+
+const (
+	defaultRegistryTimeout = 30 * time.Second
+	defaultRegistryRetries = 3
+)
+
+// RegistryConfig configures access to OCI registries when resolving image digests.
+type RegistryConfig struct {
+	// Credentials by registry (e.g. ghcr.io). Registries without credentials use the Docker keychain.
+	Credentials map[string]RegistryCredentials `yaml:"credentials" json:"credentials"`
+
+	// Mirrors by registry (e.g. docker.io), tried in order before the registry itself.
+	Mirrors map[string][]string `yaml:"mirrors" json:"mirrors"`
+
+	// Insecure registries are accessed over plain HTTP (e.g. local registries).
+	Insecure []string `yaml:"insecure" json:"insecure"`
+
+	// Timeout of a single lookup (including retries, 0 disables the timeout).
+	// Nil means unset: the default is 30s.
+	Timeout *time.Duration `yaml:"timeout" json:"timeout"`
+
+	// Retries of failed lookups (temporary errors only, 0 disables retries).
+	// Nil means unset: the default is 3.
+	Retries *int `yaml:"retries" json:"retries"`
+}
+
+// RegistryCredentials are the credentials of a registry (a token or a username and password).
+type RegistryCredentials struct {
+	Username string `yaml:"username" json:"username"`
+	Password string `yaml:"password" json:"password"`
+	Token    string `yaml:"token"    json:"token"`
+}
+
+// Override returns the configuration with other taking precedence (insecure registries of both are kept).
+func (c RegistryConfig) Override(other RegistryConfig) RegistryConfig {
+	credentials := maps.Clone(c.Credentials)
+	if credentials == nil {
+		credentials = map[string]RegistryCredentials{}
+	}
+
+	maps.Copy(credentials, other.Credentials)
+
+	mirrors := maps.Clone(c.Mirrors)
+	if mirrors == nil {
+		mirrors = map[string][]string{}
+	}
+
+	maps.Copy(mirrors, other.Mirrors)
+
+	config := RegistryConfig{
+		Credentials: credentials,
+		Mirrors:     mirrors,
+		Insecure:    lo.Uniq(append(slices.Clone(c.Insecure), other.Insecure...)),
+		Timeout:     c.Timeout,
+		Retries:     c.Retries,
+	}
+
+	if other.Timeout != nil {
+		config.Timeout = other.Timeout
+	}
+
+	if other.Retries != nil {
+		config.Retries = other.Retries
+	}
+
+	return config
+}
+
+// ParseReference parses an image reference (accessing insecure registries over plain HTTP).
+func (c RegistryConfig) ParseReference(s string) (name.Reference, error) {
+	ref, err := name.ParseReference(s)
+	if err != nil {
+		return nil, err
+	}
+
+	if !c.isInsecure(ref.Context().Registry) {
+		return ref, nil
+	}
+
+	return name.ParseReference(s, name.Insecure)
+}
+
+// Digest returns the digest of an image, trying the mirrors of its registry first.
+func (c RegistryConfig) Digest(ctx context.Context, ref name.Reference) (v1.Hash, error) {
+	var digest v1.Hash
+
+	err := c.lookup(ctx, ref, func(ref name.Reference, opts ...remote.Option) error {
+		desc, err := remote.Get(ref, opts...)
+		if err != nil {
+			return err
+		}
+
+		digest = desc.Digest
+
+		return nil
+	})
+
+	return digest, err
+}
+
+// lookup calls fn with the reference in each mirror of the registry and then in the registry itself until it succeeds.
+//
+// Each call gets its own timeout (remote options carry the context, credentials and retries).
+func (c RegistryConfig) lookup(
+	ctx context.Context,
+	ref name.Reference,
+	fn func(ref name.Reference, opts ...remote.Option) error,
+) error {
+	var errs []error
+
+	for _, mirror := range c.mirrors(ref.Context().Registry) {
+		mirrorRef, err := c.mirrorReference(ref, mirror)
+		if err == nil {
+			err = c.call(ctx, mirrorRef, fn)
+		}
+
+		// Don't try other sources when cancelled
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		if err != nil {
+			errs = append(errs, fmt.Errorf("mirror %s: %w", mirror, err))
+
+			continue
+		}
+
+		return nil
+	}
+
+	err := c.call(ctx, ref, fn)
+	if err != nil {
+		return errors.Join(append(errs, err)...)
+	}
+
+	return nil
+}
+
+func (c RegistryConfig) call(
+	ctx context.Context,
+	ref name.Reference,
+	fn func(ref name.Reference, opts ...remote.Option) error,
+) error {
+	if timeout := lo.FromPtrOr(c.Timeout, defaultRegistryTimeout); timeout > 0 {
+		var cancel context.CancelFunc
+
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	return fn(
+		ref,
+		remote.WithContext(ctx),
+		remote.WithAuthFromKeychain(c.keychain()),
+		remote.WithRetryBackoff(remote.Backoff{
+			Duration: time.Second,
+			Factor:   2,
+			Jitter:   0.1,
+			Steps:    max(lo.FromPtrOr(c.Retries, defaultRegistryRetries), 0) + 1,
+		}),
+	)
+}
+
+func (c RegistryConfig) keychain() authn.Keychain {
+	return authn.NewMultiKeychain(registryKeychain(c.credentials()), authn.DefaultKeychain)
+}
+
+// credentials returns the credentials keyed by normalized registry names (e.g. docker.io -> index.docker.io).
+func (c RegistryConfig) credentials() map[string]RegistryCredentials {
+	credentials := map[string]RegistryCredentials{}
+
+	for registry, creds := range c.Credentials {
+		credentials[normalizeRegistry(registry)] = creds
+	}
+
+	return credentials
+}
+
+func (c RegistryConfig) mirrors(registry name.Registry) []string {
+	for key, mirrors := range c.Mirrors {
+		if normalizeRegistry(key) == registry.RegistryStr() {
+			return mirrors
+		}
+	}
+
+	return nil
+}
+
+func (c RegistryConfig) isInsecure(registry name.Registry) bool {
+	return slices.ContainsFunc(c.Insecure, func(insecure string) bool {
+		return normalizeRegistry(insecure) == registry.RegistryStr()
+	})
+}
+
+// mirrorReference returns the reference pointing to the same repository and tag in a mirror.
+func (c RegistryConfig) mirrorReference(ref name.Reference, mirror string) (name.Reference, error) {
+	separator := ":"
+	if _, ok := ref.(name.Digest); ok {
+		separator = "@"
+	}
+
+	return c.ParseReference(mirror + "/" + ref.Context().RepositoryStr() + separator + ref.Identifier())
+}
+
+func normalizeRegistry(registry string) string {
+	reg, err := name.NewRegistry(registry)
+	if err != nil {
+		return registry
+	}
+
+	return reg.RegistryStr()
+}
+
+// registryKeychain resolves configured credentials (other registries are resolved by the next keychain).
+type registryKeychain map[string]RegistryCredentials
+
+func (k registryKeychain) Resolve(resource authn.Resource) (authn.Authenticator, error) {
+	creds, ok := k[resource.RegistryStr()]
+	if !ok {
+		return authn.Anonymous, nil
+	}
+
+	return authn.FromConfig(authn.AuthConfig{
+		Username:      creds.Username,
+		Password:      creds.Password,
+		RegistryToken: creds.Token,
+	}), nil
+}
@@ -0,0 +1,238 @@
+package labx
+
+import (
+	"io"
+	"log"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/goccy/go-yaml"
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testRegistry struct {
+	t    *testing.T
+	host string
+}
+
+func newTestRegistry(t *testing.T) testRegistry {
+	t.Helper()
+
+	server := httptest.NewServer(registry.New(registry.Logger(log.New(io.Discard, "", 0))))
+	t.Cleanup(server.Close)
+
+	return testRegistry{t: t, host: strings.TrimPrefix(server.URL, "http://")}
+}
+
+func (r testRegistry) ref(repo string) name.Reference {
+	ref, err := name.ParseReference(r.host+"/"+repo, name.Insecure)
+	require.NoError(r.t, err)
+
+	return ref
+}
+
+// image pushes an image with layers for a platform and returns its reference (without the scheme).
+func (r testRegistry) image(repo string, platform v1.Platform, layers int) string {
+	image := r.platformImage(platform, layers)
+
+	err := remote.Write(r.ref(repo), image)
+	require.NoError(r.t, err)
+
+	return r.host + "/" + repo
+}
+
+// index pushes an index with an image for each platform and returns its reference (without the scheme).
+func (r testRegistry) index(repo string, platforms ...v1.Platform) string {
+	var index v1.ImageIndex = empty.Index
+
+	for _, platform := range platforms {
+		index = mutate.AppendManifests(index, mutate.IndexAddendum{
+			Add:        r.platformImage(platform, 1),
+			Descriptor: v1.Descriptor{Platform: &platform},
+		})
+	}
+
+	err := remote.WriteIndex(r.ref(repo), index)
+	require.NoError(r.t, err)
+
+	return r.host + "/" + repo
+}
+
+func (r testRegistry) platformImage(platform v1.Platform, layers int) v1.Image {
+	var image v1.Image = empty.Image
+
+	if layers > 0 {
+		var err error
+
+		image, err = random.Image(1024, int64(layers))
+		require.NoError(r.t, err)
+	}
+
+	config, err := image.ConfigFile()
+	require.NoError(r.t, err)
+
+	config = config.DeepCopy()
+	config.OS = platform.OS
+	config.Architecture = platform.Architecture
+
+	image, err = mutate.ConfigFile(image, config)
+	require.NoError(r.t, err)
+
+	return image
+}
+
+// startRegistry starts an in-process registry with an image and returns the host of the registry and the image digest.
+func startRegistry(t *testing.T, repo string) (string, string) {
+	t.Helper()
+
+	reg := newTestRegistry(t)
+
+	image, err := random.Image(1024, 1)
+	require.NoError(t, err)
+
+	err = remote.Write(reg.ref(repo), image)
+	require.NoError(t, err)
+
+	digest, err := image.Digest()
+	require.NoError(t, err)
+
+	return reg.host, digest.String()
+}
+
+func TestOCIDriveSourceResolver_Registry(t *testing.T) {
+	host, digest := startRegistry(t, "labs/rootfs:dev")
+
+	resolver := OCIDriveSourceResolver{
+		Registries: RegistryConfig{
+			Insecure: []string{host},
+			Timeout:  lo.ToPtr(5 * time.Second),
+		},
+	}
+
+	got, err := resolver.ResolveDriveSource(t.Context(), host+"/labs/rootfs:dev", DriveSourceData{})
+	require.NoError(t, err)
+
+	assert.Equal(t, "oci://"+host+"/labs/rootfs:dev@"+digest, got)
+}
+
+func TestOCIDriveSourceResolver_Mirror(t *testing.T) {
+	host, digest := startRegistry(t, "labs/rootfs:dev")
+
+	resolver := OCIDriveSourceResolver{
+		Registries: RegistryConfig{
+			Mirrors: map[string][]string{
+				// The first mirror doesn't have the image
+				"registry.example.com": {host + "/missing", host},
+			},
+			Insecure: []string{host},
+			Timeout:  lo.ToPtr(5 * time.Second),
+		},
+	}
+
+	// The image is pinned with its original name
+	got, err := resolver.ResolveDriveSource(t.Context(), "registry.example.com/labs/rootfs:dev", DriveSourceData{})
+	require.NoError(t, err)
+
+	assert.Equal(t, "oci://registry.example.com/labs/rootfs:dev@"+digest, got)
+}
+
+func TestOCIDriveSourceResolver_NotFound(t *testing.T) {
+	host, _ := startRegistry(t, "labs/rootfs:dev")
+
+	resolver := OCIDriveSourceResolver{
+		Registries: RegistryConfig{
+			Insecure: []string{host},
+			Timeout:  lo.ToPtr(5 * time.Second),
+		},
+	}
+
+	_, err := resolver.ResolveDriveSource(t.Context(), host+"/labs/rootfs:missing", DriveSourceData{})
+	assert.Error(t, err)
+}
+
+func TestRegistryConfig_Keychain(t *testing.T) {
+	config := RegistryConfig{
+		Credentials: map[string]RegistryCredentials{
+			"docker.io": {Username: "user", Password: "pass"},
+			"ghcr.io":   {Token: "token"},
+		},
+	}
+
+	keychain := registryKeychain(config.credentials())
+
+	tests := []struct {
+		registry string
+		want     authn.AuthConfig
+	}{
+		{registry: "index.docker.io", want: authn.AuthConfig{Username: "user", Password: "pass"}},
+		{registry: "ghcr.io", want: authn.AuthConfig{RegistryToken: "token"}},
+		{registry: "quay.io", want: authn.AuthConfig{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.registry, func(t *testing.T) {
+			registry, err := name.NewRegistry(tt.registry)
+			require.NoError(t, err)
+
+			authenticator, err := keychain.Resolve(registry)
+			require.NoError(t, err)
+
+			got, err := authenticator.Authorization()
+			require.NoError(t, err)
+
+			assert.Equal(t, tt.want, *got)
+		})
+	}
+}
+
+func TestRegistryConfig_Override(t *testing.T) {
+	config := RegistryConfig{
+		Mirrors:  map[string][]string{"docker.io": {"mirror.gcr.io"}},
+		Insecure: []string{"localhost:5000"},
+		Timeout:  lo.ToPtr(30 * time.Second),
+		Retries:  lo.ToPtr(3),
+	}.Override(RegistryConfig{
+		Credentials: map[string]RegistryCredentials{"ghcr.io": {Token: "token"}},
+		Insecure:    []string{"localhost:5000", "registry.local"},
+		Retries:     lo.ToPtr(1),
+	})
+
+	assert.Equal(t, RegistryConfig{
+		Credentials: map[string]RegistryCredentials{"ghcr.io": {Token: "token"}},
+		Mirrors:     map[string][]string{"docker.io": {"mirror.gcr.io"}},
+		Insecure:    []string{"localhost:5000", "registry.local"},
+		Timeout:     lo.ToPtr(30 * time.Second),
+		Retries:     lo.ToPtr(1),
+	}, config)
+}
+
+func TestRegistryConfig_Override_Zero(t *testing.T) {
+	var other RegistryConfig
+
+	err := yaml.Unmarshal([]byte("timeout: 0s\nretries: 0\n"), &other)
+	require.NoError(t, err)
+
+	// Zero values are set explicitly (they don't fall back to the defaults)
+	config := defaultDriveSourceConfig().Registries.Override(other)
+
+	assert.Equal(t, lo.ToPtr(time.Duration(0)), config.Timeout)
+	assert.Equal(t, lo.ToPtr(0), config.Retries)
+
+	// Unset values are inherited
+	config = defaultDriveSourceConfig().Registries.Override(RegistryConfig{})
+
+	assert.Equal(t, lo.ToPtr(defaultRegistryTimeout), config.Timeout)
+	assert.Equal(t, lo.ToPtr(defaultRegistryRetries), config.Retries)
+}
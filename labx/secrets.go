@@ -0,0 +1,318 @@
+package labx
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Placeholder used in place of secret values.
+const redacted = "[REDACTED]"
+
+// envPattern matches ${env:NAME} references.
+var envPattern = regexp.MustCompile(`\$\{env:([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// Secrets resolves secrets from the process environment and a .env file,
+// and remembers resolved values so they can be redacted from output.
+type Secrets struct {
+	dotEnv map[string]string
+
+	mu     sync.Mutex
+	values []string
+}
+
+// NewSecrets creates a new [Secrets] instance.
+//
+// Values in the process environment take precedence over values in the .env file (if any).
+func NewSecrets(dotEnv io.Reader) (*Secrets, error) {
+	secrets := &Secrets{
+		dotEnv: map[string]string{},
+	}
+
+	if dotEnv == nil {
+		return secrets, nil
+	}
+
+	values, err := parseDotEnv(dotEnv)
+	if err != nil {
+		return nil, fmt.Errorf("parse .env file: %w", err)
+	}
+
+	secrets.dotEnv = values
+
+	return secrets, nil
+}
+
+// LoadSecrets creates a new [Secrets] instance using the given .env file (if it exists).
+func LoadSecrets(dotEnvPath string) (*Secrets, error) {
+	dotEnv, err := os.Open(dotEnvPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return NewSecrets(nil)
+	} else if err != nil {
+		return nil, err
+	}
+	defer dotEnv.Close()
+
+	return NewSecrets(dotEnv)
+}
+
+// Get returns the value of a secret.
+func (s *Secrets) Get(name string) (string, error) {
+	value, ok := os.LookupEnv(name)
+	if !ok {
+		value, ok = s.dotEnv[name]
+	}
+
+	if !ok {
+		return "", fmt.Errorf("secret %s is not set (set it in the environment or in the .env file)", name)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if value != "" && !slices.Contains(s.values, value) {
+		s.values = append(s.values, value)
+	}
+
+	return value, nil
+}
+
+// Interpolate replaces ${env:NAME} references in a string.
+func (s *Secrets) Interpolate(text string) (string, error) {
+	var errs []error
+
+	result := envPattern.ReplaceAllStringFunc(text, func(match string) string {
+		name := envPattern.FindStringSubmatch(match)[1]
+
+		value, err := s.Get(name)
+		if err != nil {
+			errs = append(errs, err)
+		}
+
+		return value
+	})
+
+	return result, errors.Join(errs...)
+}
+
+// InterpolateAll replaces ${env:NAME} references in every string of a value (a pointer to a struct, map or slice).
+func (s *Secrets) InterpolateAll(v any) error {
+	return s.interpolateValue(reflect.ValueOf(v))
+}
+
+func (s *Secrets) interpolateValue(v reflect.Value) error {
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+
+		if v.Kind() == reflect.Interface {
+			// Values stored in interfaces are not addressable
+			elem := reflect.New(v.Elem().Type()).Elem()
+			elem.Set(v.Elem())
+
+			err := s.interpolateValue(elem)
+			if err != nil {
+				return err
+			}
+
+			if v.CanSet() {
+				v.Set(elem)
+			}
+
+			return nil
+		}
+
+		return s.interpolateValue(v.Elem())
+
+	case reflect.Struct:
+		for i := range v.NumField() {
+			if !v.Type().Field(i).IsExported() {
+				continue
+			}
+
+			err := s.interpolateValue(v.Field(i))
+			if err != nil {
+				return err
+			}
+		}
+
+	case reflect.Slice, reflect.Array:
+		for i := range v.Len() {
+			err := s.interpolateValue(v.Index(i))
+			if err != nil {
+				return err
+			}
+		}
+
+	case reflect.Map:
+		iter := v.MapRange()
+		for iter.Next() {
+			// Map values are not addressable
+			value := reflect.New(iter.Value().Type()).Elem()
+			value.Set(iter.Value())
+
+			err := s.interpolateValue(value)
+			if err != nil {
+				return err
+			}
+
+			v.SetMapIndex(iter.Key(), value)
+		}
+
+	case reflect.String:
+		if !v.CanSet() || !strings.Contains(v.String(), "${env:") {
+			return nil
+		}
+
+		value, err := s.Interpolate(v.String())
+		if err != nil {
+			return err
+		}
+
+		v.SetString(value)
+	}
+
+	return nil
+}
+
+// Redact replaces resolved secret values in a string.
+func (s *Secrets) Redact(text string) string {
+	s.mu.Lock()
+	values := slices.Clone(s.values)
+	s.mu.Unlock()
+
+	// Replace longer values first in case a value contains another one
+	slices.SortFunc(values, func(a string, b string) int { return len(b) - len(a) })
+
+	for _, value := range values {
+		text = strings.ReplaceAll(text, value, redacted)
+	}
+
+	return text
+}
+
+// RedactError returns an error with secret values redacted from its message.
+func (s *Secrets) RedactError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	message := s.Redact(err.Error())
+	if message == err.Error() {
+		return err
+	}
+
+	return errors.New(message)
+}
+
+// RedactingHandler is a [slog.Handler] that redacts secret values from log messages and attributes.
+type RedactingHandler struct {
+	handler slog.Handler
+	secrets *Secrets
+}
+
+// NewRedactingHandler creates a new [RedactingHandler].
+func NewRedactingHandler(handler slog.Handler, secrets *Secrets) *RedactingHandler {
+	return &RedactingHandler{
+		handler: handler,
+		secrets: secrets,
+	}
+}
+
+func (h *RedactingHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.handler.Enabled(ctx, level)
+}
+
+func (h *RedactingHandler) Handle(ctx context.Context, record slog.Record) error {
+	redactedRecord := slog.NewRecord(record.Time, record.Level, h.secrets.Redact(record.Message), record.PC)
+
+	record.Attrs(func(attr slog.Attr) bool {
+		redactedRecord.AddAttrs(h.redactAttr(attr))
+
+		return true
+	})
+
+	return h.handler.Handle(ctx, redactedRecord)
+}
+
+func (h *RedactingHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	redactedAttrs := make([]slog.Attr, 0, len(attrs))
+	for _, attr := range attrs {
+		redactedAttrs = append(redactedAttrs, h.redactAttr(attr))
+	}
+
+	return NewRedactingHandler(h.handler.WithAttrs(redactedAttrs), h.secrets)
+}
+
+func (h *RedactingHandler) WithGroup(name string) slog.Handler {
+	return NewRedactingHandler(h.handler.WithGroup(name), h.secrets)
+}
+
+func (h *RedactingHandler) redactAttr(attr slog.Attr) slog.Attr {
+	value := attr.Value.Resolve()
+
+	if value.Kind() == slog.KindGroup {
+		attrs := value.Group()
+		redactedAttrs := make([]any, 0, len(attrs))
+
+		for _, attr := range attrs {
+			redactedAttrs = append(redactedAttrs, h.redactAttr(attr))
+		}
+
+		return slog.Group(attr.Key, redactedAttrs...)
+	}
+
+	if value.Kind() == slog.KindString {
+		return slog.String(attr.Key, h.secrets.Redact(value.String()))
+	}
+
+	return attr
+}
+
+// parseDotEnv parses a .env file (KEY=VALUE lines, comments and optional export prefixes are supported).
+func parseDotEnv(r io.Reader) (map[string]string, error) {
+	values := map[string]string{}
+
+	scanner := bufio.NewScanner(r)
+
+	lineNumber := 0
+
+	for scanner.Scan() {
+		lineNumber++
+
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		line = strings.TrimPrefix(line, "export ")
+
+		name, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected NAME=VALUE", lineNumber)
+		}
+
+		name = strings.TrimSpace(name)
+		value = strings.TrimSpace(value)
+
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+			value = value[1 : len(value)-1]
+		}
+
+		values[name] = value
+	}
+
+	return values, scanner.Err()
+}
@@ -0,0 +1,133 @@
+package labx
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sagikazarmark/labx/extended"
+)
+
+func TestParseDotEnv(t *testing.T) {
+	values, err := parseDotEnv(strings.NewReader(`
+# comment
+TOKEN=abc123
+export REGISTRY_PASSWORD="p@ss word"
+SINGLE='quoted'
+EMPTY=
+`))
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]string{
+		"TOKEN":             "abc123",
+		"REGISTRY_PASSWORD": "p@ss word",
+		"SINGLE":            "quoted",
+		"EMPTY":             "",
+	}, values)
+
+	_, err = parseDotEnv(strings.NewReader("INVALID"))
+	require.ErrorContains(t, err, "line 1")
+}
+
+func TestSecrets_Get(t *testing.T) {
+	t.Setenv("LABX_TEST_OVERRIDE", "from-env")
+
+	secrets, err := NewSecrets(strings.NewReader("LABX_TEST_OVERRIDE=from-file\nLABX_TEST_FILE=from-file\n"))
+	require.NoError(t, err)
+
+	value, err := secrets.Get("LABX_TEST_OVERRIDE")
+	require.NoError(t, err)
+	assert.Equal(t, "from-env", value)
+
+	value, err = secrets.Get("LABX_TEST_FILE")
+	require.NoError(t, err)
+	assert.Equal(t, "from-file", value)
+
+	_, err = secrets.Get("LABX_TEST_MISSING")
+	require.ErrorContains(t, err, "secret LABX_TEST_MISSING is not set")
+}
+
+func TestSecrets_InterpolateAll(t *testing.T) {
+	secrets, err := NewSecrets(strings.NewReader("REGISTRY_PASSWORD=hunter2\nGREETING=hello\n"))
+	require.NoError(t, err)
+
+	manifest := extended.PlaygroundManifest{
+		Playground: extended.PlaygroundSpec{
+			Machines: extended.PlaygroundMachines{
+				{
+					Name: "dev",
+					StartupFiles: []extended.MachineStartupFile{
+						{Path: "/etc/greeting", Content: "${env:GREETING}, ${env:GREETING}!"},
+						{Path: "/root/.password", Content: "${env:REGISTRY_PASSWORD}"},
+					},
+				},
+			},
+		},
+	}
+
+	err = secrets.InterpolateAll(&manifest)
+	require.NoError(t, err)
+
+	files := manifest.Playground.Machines[0].StartupFiles
+	assert.Equal(t, "hello, hello!", files[0].Content)
+	assert.Equal(t, "hunter2", files[1].Content)
+
+	manifest.Playground.Machines[0].Hostname = "${env:LABX_TEST_MISSING}"
+
+	err = secrets.InterpolateAll(&manifest)
+	require.ErrorContains(t, err, "secret LABX_TEST_MISSING is not set")
+}
+
+func TestSecrets_Redact(t *testing.T) {
+	secrets, err := NewSecrets(strings.NewReader("SHORT=abc\nLONG=abcdef\n"))
+	require.NoError(t, err)
+
+	// Values are only redacted once they are used
+	assert.Equal(t, "abcdef", secrets.Redact("abcdef"))
+
+	_, err = secrets.Get("SHORT")
+	require.NoError(t, err)
+
+	_, err = secrets.Get("LONG")
+	require.NoError(t, err)
+
+	assert.Equal(t, "token: [REDACTED], other: [REDACTED]", secrets.Redact("token: abcdef, other: abc"))
+}
+
+func TestRedactingHandler(t *testing.T) {
+	secrets, err := NewSecrets(strings.NewReader("TOKEN=s3cr3t\n"))
+	require.NoError(t, err)
+
+	_, err = secrets.Get("TOKEN")
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+
+	logger := slog.New(NewRedactingHandler(slog.NewTextHandler(&buf, nil), secrets))
+	logger.With("header", "Bearer s3cr3t").Info("using s3cr3t", "token", "s3cr3t", "size", 6)
+
+	assert.NotContains(t, buf.String(), "s3cr3t")
+	assert.Contains(t, buf.String(), "[REDACTED]")
+	assert.Contains(t, buf.String(), "size=6")
+}
+
+func TestSecretTemplateFunc(t *testing.T) {
+	secrets, err := NewSecrets(strings.NewReader("API_TOKEN=t0ken\n"))
+	require.NoError(t, err)
+
+	renderer := TemplateRenderer{
+		Funcs: createTemplateFuncs(fstest.MapFS{}, secrets),
+	}
+
+	content, err := renderer.Render("token", `TOKEN={{ secret "API_TOKEN" }}`, extended.PlaygroundMachine{}, extended.MachineUser{})
+	require.NoError(t, err)
+	assert.Equal(t, "TOKEN=t0ken", content)
+
+	_, err = renderer.Render("missing", `{{ secret "LABX_TEST_MISSING" }}`, extended.PlaygroundMachine{}, extended.MachineUser{})
+	require.ErrorContains(t, err, "secret LABX_TEST_MISSING is not set")
+}
@@ -0,0 +1,184 @@
+package labx
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"os"
+	"path"
+	"slices"
+	"strings"
+)
+
+// GeneratedManifest is the file in the output directory listing the generated files,
+// so files that are no longer generated can be removed by [SyncOutput].
+const GeneratedManifest = ".labx-generated"
+
+// SyncOpts contains options for the SyncOutput function
+type SyncOpts struct {
+	// Prune removes files that were not generated (e.g. created by hand) from the output directory.
+	Prune bool
+}
+
+// SyncReport lists the files changed by [SyncOutput].
+type SyncReport struct {
+	Written   []string
+	Removed   []string
+	Unchanged int
+}
+
+// SyncOutput updates an output directory to match generated output:
+// it only writes files that changed and removes files that are no longer generated.
+//
+// Files that were not generated are left alone unless [SyncOpts.Prune] is set.
+func SyncOutput(output *os.Root, generated fs.FS, opts SyncOpts) (SyncReport, error) {
+	var report SyncReport
+
+	generatedFiles, err := listFiles(generated)
+	if err != nil {
+		return report, fmt.Errorf("list generated output: %w", err)
+	}
+
+	previousFiles, err := readGeneratedManifest(output)
+	if err != nil {
+		return report, fmt.Errorf("read %s: %w", GeneratedManifest, err)
+	}
+
+	if opts.Prune {
+		previousFiles, err = listFiles(output.FS())
+		if err != nil {
+			return report, fmt.Errorf("list output: %w", err)
+		}
+
+		delete(previousFiles, GeneratedManifest)
+	}
+
+	// Create directories first (including empty ones)
+	err = fs.WalkDir(generated, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || !d.IsDir() || name == "." {
+			return err
+		}
+
+		return output.MkdirAll(name, 0o755)
+	})
+	if err != nil {
+		return report, err
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(generatedFiles)) {
+		data, err := fs.ReadFile(generated, name)
+		if err != nil {
+			return report, err
+		}
+
+		existing, err := output.ReadFile(name)
+		if err == nil && bytes.Equal(existing, data) {
+			report.Unchanged++
+
+			continue
+		} else if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return report, err
+		}
+
+		err = output.WriteFile(name, data, 0o644)
+		if err != nil {
+			return report, err
+		}
+
+		report.Written = append(report.Written, name)
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(previousFiles)) {
+		if generatedFiles[name] {
+			continue
+		}
+
+		err := output.Remove(name)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		} else if err != nil {
+			return report, err
+		}
+
+		report.Removed = append(report.Removed, name)
+
+		err = removeEmptyParents(output, name)
+		if err != nil {
+			return report, err
+		}
+	}
+
+	err = writeGeneratedManifest(output, generatedFiles)
+	if err != nil {
+		return report, fmt.Errorf("write %s: %w", GeneratedManifest, err)
+	}
+
+	return report, nil
+}
+
+// removeEmptyParents removes the parent directories of a removed file that became empty.
+func removeEmptyParents(output *os.Root, name string) error {
+	for dir := path.Dir(name); dir != "."; dir = path.Dir(dir) {
+		entries, err := fs.ReadDir(output.FS(), dir)
+		if err != nil {
+			return err
+		}
+
+		if len(entries) > 0 {
+			return nil
+		}
+
+		err = output.Remove(dir)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// readGeneratedManifest reads the files generated previously (none if there is no manifest).
+func readGeneratedManifest(output *os.Root) (map[string]bool, error) {
+	files := map[string]bool{}
+
+	data, err := output.ReadFile(GeneratedManifest)
+	if errors.Is(err, fs.ErrNotExist) {
+		return files, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	for line := range strings.Lines(string(data)) {
+		name := strings.TrimSpace(line)
+		if name == "" {
+			continue
+		}
+
+		// Never remove files outside the output directory
+		if !fs.ValidPath(name) || name == GeneratedManifest {
+			return nil, fmt.Errorf("invalid path: %q", name)
+		}
+
+		files[name] = true
+	}
+
+	return files, nil
+}
+
+func writeGeneratedManifest(output *os.Root, files map[string]bool) error {
+	var b strings.Builder
+
+	for _, name := range slices.Sorted(maps.Keys(files)) {
+		b.WriteString(name)
+		b.WriteString("\n")
+	}
+
+	existing, err := output.ReadFile(GeneratedManifest)
+	if err == nil && string(existing) == b.String() {
+		return nil
+	}
+
+	return output.WriteFile(GeneratedManifest, []byte(b.String()), 0o644)
+}
@@ -0,0 +1,83 @@
+package labx
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSyncOutput(t *testing.T) {
+	dir := t.TempDir()
+
+	output, err := os.OpenRoot(dir)
+	require.NoError(t, err)
+	defer output.Close()
+
+	generated := fstest.MapFS{
+		"index.md":            {Data: []byte("index\n")},
+		"lesson/00-index.md":  {Data: []byte("lesson\n")},
+		"__static__/logo.svg": {Data: []byte("<svg/>\n")},
+	}
+
+	report, err := SyncOutput(output, generated, SyncOpts{})
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"__static__/logo.svg", "index.md", "lesson/00-index.md"}, report.Written)
+	assert.Empty(t, report.Removed)
+
+	manifest, err := os.ReadFile(filepath.Join(dir, GeneratedManifest))
+	require.NoError(t, err)
+	assert.Equal(t, "__static__/logo.svg\nindex.md\nlesson/00-index.md\n", string(manifest))
+
+	// Unchanged files are not rewritten
+	old := time.Now().Add(-time.Hour)
+	require.NoError(t, os.Chtimes(filepath.Join(dir, "index.md"), old, old))
+
+	// Not generated by labx
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "notes.md"), []byte("notes\n"), 0o644))
+
+	generated = fstest.MapFS{
+		"index.md":            {Data: []byte("index\n")},
+		"__static__/logo.svg": {Data: []byte("<svg></svg>\n")},
+	}
+
+	report, err = SyncOutput(output, generated, SyncOpts{})
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"__static__/logo.svg"}, report.Written)
+	assert.Equal(t, []string{"lesson/00-index.md"}, report.Removed)
+	assert.Equal(t, 1, report.Unchanged)
+
+	info, err := os.Stat(filepath.Join(dir, "index.md"))
+	require.NoError(t, err)
+	assert.True(t, info.ModTime().Equal(old))
+
+	assert.NoDirExists(t, filepath.Join(dir, "lesson"))
+	assert.FileExists(t, filepath.Join(dir, "notes.md"))
+
+	report, err = SyncOutput(output, generated, SyncOpts{Prune: true})
+	require.NoError(t, err)
+
+	assert.Empty(t, report.Written)
+	assert.Equal(t, []string{"notes.md"}, report.Removed)
+	assert.NoFileExists(t, filepath.Join(dir, "notes.md"))
+	assert.FileExists(t, filepath.Join(dir, GeneratedManifest))
+}
+
+func TestSyncOutput_InvalidManifest(t *testing.T) {
+	dir := t.TempDir()
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, GeneratedManifest), []byte("../outside.md\n"), 0o644))
+
+	output, err := os.OpenRoot(dir)
+	require.NoError(t, err)
+	defer output.Close()
+
+	_, err = SyncOutput(output, fstest.MapFS{}, SyncOpts{})
+	assert.Error(t, err)
+}
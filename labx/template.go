@@ -3,12 +3,14 @@ package labx
 import (
 	"fmt"
 	"io/fs"
-	"os"
+	"strings"
 	"text/template"
 
 	"github.com/go-sprout/sprout"
 	"github.com/go-sprout/sprout/group/all"
 
+	"github.com/sagikazarmark/labx/extended"
+	"github.com/sagikazarmark/labx/pkg/fsx"
 	"github.com/sagikazarmark/labx/pkg/sproutx"
 )
 
@@ -23,7 +25,7 @@ func renderRootTemplate(ctx renderContext, tpl *template.Template, name string)
 }
 
 func renderTemplate(
-	output *os.Root,
+	output fsx.WriteFS,
 	outputPath string,
 	tpl *template.Template,
 	name string,
@@ -38,8 +40,8 @@ func renderTemplate(
 	return tpl.ExecuteTemplate(outputFile, name, data)
 }
 
-func createBaseTemplate(rootFS fs.FS, templateFSs []fs.FS) (*template.Template, error) {
-	tplFuncs := createTemplateFuncs(rootFS)
+func createBaseTemplate(rootFS fs.FS, templateFSs []fs.FS, secrets *Secrets) (*template.Template, error) {
+	tplFuncs := createTemplateFuncs(rootFS, secrets)
 	tpl := template.New("").Funcs(tplFuncs)
 
 	for _, templateFS := range templateFSs {
@@ -58,15 +60,92 @@ func createBaseTemplate(rootFS fs.FS, templateFSs []fs.FS) (*template.Template,
 	return tpl, nil
 }
 
+// TemplateRenderer renders files marked as templates (startup files and welcome files).
+type TemplateRenderer struct {
+	Funcs   template.FuncMap
+	Channel string
+	Extra   map[string]any
+
+	// Addresses of machines by machine and network name.
+	Addresses map[string]map[string]string
+}
+
+// machineTemplateData holds the data passed to startup file and welcome file templates
+type machineTemplateData struct {
+	// Index (starting from 1, 0 if the machine is not a replica) and name of the machine.
+	Index int
+	Name  string
+
+	Channel   string
+	Extra     map[string]any
+	Machine   extended.PlaygroundMachine
+	User      extended.MachineUser
+	Addresses map[string]map[string]string
+}
+
+// Render renders a template with the machine (and user) context.
+func (r TemplateRenderer) Render(
+	name string,
+	text string,
+	machine extended.PlaygroundMachine,
+	user extended.MachineUser,
+) (string, error) {
+	tpl, err := template.New(name).Funcs(r.Funcs).Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("parse template %s: %w", name, err)
+	}
+
+	data := machineTemplateData{
+		Index:     machine.ReplicaIndex,
+		Name:      machine.Name,
+		Channel:   r.Channel,
+		Extra:     r.Extra,
+		Machine:   machine,
+		User:      user,
+		Addresses: r.Addresses,
+	}
+
+	var buf strings.Builder
+
+	err = tpl.Execute(&buf, data)
+	if err != nil {
+		return "", fmt.Errorf("execute template %s: %w", name, err)
+	}
+
+	return buf.String(), nil
+}
+
+// withAddresses returns a copy of the renderer with the given machine addresses (nil renderers stay nil).
+func (r *TemplateRenderer) withAddresses(addresses map[string]map[string]string) *TemplateRenderer {
+	if r == nil || addresses == nil {
+		return r
+	}
+
+	renderer := *r
+	renderer.Addresses = addresses
+
+	return &renderer
+}
+
 // createTemplateFuncs creates template functions for the given filesystem
-func createTemplateFuncs(fsys fs.FS) template.FuncMap {
-	return sprout.New(
+func createTemplateFuncs(fsys fs.FS, secrets *Secrets) template.FuncMap {
+	funcs := sprout.New(
 		sprout.WithRegistries(
 			sproutx.NewFSRegistry(fsys),
 			sproutx.NewStringsRegistry(),
 		),
 		sprout.WithGroups(all.RegistryGroup()),
 	).Build()
+
+	funcs["secret"] = func(name string) (string, error) {
+		if secrets == nil {
+			return "", fmt.Errorf("secret %s: secrets are not available", name)
+		}
+
+		return secrets.Get(name)
+	}
+
+	return funcs
 }
 
 // parseTemplatePatterns parses template patterns from a filesystem into a template
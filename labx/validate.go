@@ -0,0 +1,132 @@
+package labx
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/goccy/go-yaml"
+)
+
+// ValidateOpts contains options for the Validate function
+type ValidateOpts struct {
+	Root    *os.Root
+	Channel string
+
+	// Directories to load templates, extra data, machine presets and local playgrounds from (see [GenerateOpts]).
+	TemplateDirs   []fs.FS
+	DataDirs       []fs.FS
+	LibraryDirs    []fs.FS
+	PlaygroundDirs []fs.FS
+	RemoteBases    bool
+
+	// Secrets referenced by the manifest (see [GenerateOpts]).
+	Secrets *Secrets
+
+	// Context cancels processing (defaults to context.Background()).
+	Context context.Context
+}
+
+func (o ValidateOpts) loadOptions() loadOptions {
+	return loadOptions{
+		Channel:        o.Channel,
+		TemplateDirs:   o.TemplateDirs,
+		DataDirs:       o.DataDirs,
+		LibraryDirs:    o.LibraryDirs,
+		PlaygroundDirs: o.PlaygroundDirs,
+		RemoteBases:    o.RemoteBases,
+		Secrets:        o.Secrets,
+		Context:        o.Context,
+	}
+}
+
+// ValidationReport is the result of validating content.
+type ValidationReport struct {
+	// Images contains a report for every OCI drive image.
+	Images []ImageReport
+}
+
+// Passed reports whether no problems were found.
+func (r ValidationReport) Passed() bool {
+	return !slices.ContainsFunc(r.Images, func(image ImageReport) bool {
+		return len(image.Problems) > 0
+	})
+}
+
+// Validate processes the manifests (including lessons) like Generate does, without writing output,
+// and verifies that drive images are compatible with the platform.
+func Validate(opts ValidateOpts) (ValidationReport, error) {
+	return validateFS(opts.Root.FS(), opts)
+}
+
+func validateFS(fsys fs.FS, opts ValidateOpts) (ValidationReport, error) {
+	manifestFile, err := fsys.Open("manifest.yaml")
+	if err != nil {
+		return ValidationReport{}, err
+	}
+	defer manifestFile.Close()
+
+	var kind manifestKind
+
+	err = yaml.NewDecoder(manifestFile).Decode(&kind)
+	if err != nil {
+		return ValidationReport{}, err
+	}
+
+	manifestOpts, err := loadManifestOptions(fsys, opts.loadOptions())
+	if err != nil {
+		return ValidationReport{}, err
+	}
+
+	images := NewImageVerifier(defaultDriveSourceConfig().Override(manifestOpts.DriveSources).Registries)
+	manifestOpts.Images = images
+
+	if kind.Kind == "playground" {
+		_, err = convertPlaygroundManifest(fsys, manifestOpts)
+		if err != nil {
+			return ValidationReport{}, err
+		}
+	} else {
+		extendedManifest, err := loadContentManifest(fsys, manifestOpts)
+		if err != nil {
+			return ValidationReport{}, err
+		}
+
+		course := contentName(extendedManifest, opts.Channel)
+
+		// Lessons carry their own playgrounds
+		for _, pattern := range []string{"lessons/*/manifest.yaml", "modules/*/*/manifest.yaml"} {
+			matches, err := fs.Glob(fsys, pattern)
+			if err != nil {
+				return ValidationReport{}, err
+			}
+
+			for _, match := range matches {
+				lessonFS, err := fs.Sub(fsys, path.Dir(match))
+				if err != nil {
+					return ValidationReport{}, err
+				}
+
+				lessonPath := strings.SplitN(path.Dir(match), "/", 2)[1]
+
+				_, err = convertContentManifest(lessonFS, lessonOptions(manifestOpts, course, lessonPath))
+				if err != nil {
+					return ValidationReport{}, fmt.Errorf("validate %s: %w", match, err)
+				}
+			}
+		}
+	}
+
+	reports := images.Reports()
+
+	slices.SortStableFunc(reports, func(a, b ImageReport) int {
+		return cmp.Or(cmp.Compare(a.Machine, b.Machine), cmp.Compare(a.Mount, b.Mount))
+	})
+
+	return ValidationReport{Images: reports}, nil
+}
@@ -1,8 +1,12 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/iximiuz/labctl/api"
 	"github.com/spf13/cobra"
@@ -15,7 +19,7 @@ func main() {
 	var client *api.Client
 
 	cmd := &cobra.Command{
-		Use:     "labx <generate>",
+		Use:     "labx <generate|lint|test|explain|validate>",
 		Short:   "labx - opinionated tools for iximiuz Labs content",
 		Version: version,
 		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
@@ -43,11 +47,26 @@ func main() {
 
 	cmd.AddCommand(
 		xcmd.NewGenerateCommand(),
+		xcmd.NewLintCommand(),
+		xcmd.NewTestCommand(),
+		xcmd.NewExplainCommand(),
+		xcmd.NewValidateCommand(),
 	)
 
-	err := cmd.Execute()
+	// Interrupting cancels running commands (e.g. image lookups) cleanly
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+
+	err := cmd.ExecuteContext(ctx)
+	stop()
+
 	if err != nil {
 		fmt.Println(err)
+
+		var exitErr *xcmd.ExitError
+		if errors.As(err, &exitErr) {
+			os.Exit(exitErr.Code)
+		}
+
 		os.Exit(1)
 	}
 }
@@ -0,0 +1,278 @@
+package fsx
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"maps"
+	"os"
+	"path"
+	"slices"
+	"sync"
+	"testing/fstest"
+	"time"
+)
+
+// WriteFS is a filesystem files can be written to.
+//
+// Implementations must be safe for concurrent use.
+type WriteFS interface {
+	// Create creates (or truncates) a file.
+	Create(name string) (io.WriteCloser, error)
+
+	// Mkdir creates a directory. It returns an error matching [fs.ErrExist] if the directory already exists.
+	Mkdir(name string, perm fs.FileMode) error
+
+	// WriteFile writes a file (creating or truncating it).
+	WriteFile(name string, data []byte, perm fs.FileMode) error
+}
+
+// RootFS is a [WriteFS] writing to a directory.
+type RootFS struct {
+	Root *os.Root
+}
+
+// NewRootFS returns a [WriteFS] writing to a directory opened as an [os.Root].
+func NewRootFS(root *os.Root) RootFS {
+	return RootFS{Root: root}
+}
+
+func (r RootFS) Create(name string) (io.WriteCloser, error) {
+	return r.Root.Create(name)
+}
+
+func (r RootFS) Mkdir(name string, perm fs.FileMode) error {
+	return r.Root.Mkdir(name, perm)
+}
+
+func (r RootFS) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	return r.Root.WriteFile(name, data, perm)
+}
+
+// MemFS is an in-memory [WriteFS]. Like [RootFS], it requires parent directories to exist.
+type MemFS struct {
+	mu    sync.Mutex
+	files map[string]*fstest.MapFile
+}
+
+// NewMemFS returns an empty [MemFS].
+func NewMemFS() *MemFS {
+	return &MemFS{
+		files: map[string]*fstest.MapFile{},
+	}
+}
+
+func (m *MemFS) Create(name string) (io.WriteCloser, error) {
+	err := m.WriteFile(name, nil, 0o644)
+	if err != nil {
+		return nil, err
+	}
+
+	return &memFile{fsys: m, name: name}, nil
+}
+
+func (m *MemFS) Mkdir(name string, perm fs.FileMode) error {
+	if !fs.ValidPath(name) {
+		return &fs.PathError{Op: "mkdir", Path: name, Err: fs.ErrInvalid}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.files[name]; ok {
+		return &fs.PathError{Op: "mkdir", Path: name, Err: fs.ErrExist}
+	}
+
+	if !m.parentExists(name) {
+		return &fs.PathError{Op: "mkdir", Path: name, Err: fs.ErrNotExist}
+	}
+
+	m.files[name] = &fstest.MapFile{Mode: fs.ModeDir | perm.Perm(), ModTime: time.Now()}
+
+	return nil
+}
+
+func (m *MemFS) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	if !fs.ValidPath(name) {
+		return &fs.PathError{Op: "write", Path: name, Err: fs.ErrInvalid}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if file, ok := m.files[name]; ok && file.Mode.IsDir() {
+		return &fs.PathError{Op: "write", Path: name, Err: errors.New("is a directory")}
+	}
+
+	if !m.parentExists(name) {
+		return &fs.PathError{Op: "write", Path: name, Err: fs.ErrNotExist}
+	}
+
+	m.files[name] = &fstest.MapFile{Data: bytes.Clone(data), Mode: perm.Perm(), ModTime: time.Now()}
+
+	return nil
+}
+
+// parentExists reports whether the parent directory of a file exists (the caller must hold the lock).
+func (m *MemFS) parentExists(name string) bool {
+	dir := path.Dir(name)
+	if dir == "." {
+		return true
+	}
+
+	file, ok := m.files[dir]
+
+	return ok && file.Mode.IsDir()
+}
+
+// MapFS returns a copy of the written files and directories.
+func (m *MemFS) MapFS() fstest.MapFS {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	files := make(fstest.MapFS, len(m.files))
+
+	for name, file := range m.files {
+		clone := *file
+		clone.Data = bytes.Clone(file.Data)
+		files[name] = &clone
+	}
+
+	return files
+}
+
+// memFile appends writes to a file in a [MemFS].
+type memFile struct {
+	fsys *MemFS
+	name string
+}
+
+func (f *memFile) Write(p []byte) (int, error) {
+	f.fsys.mu.Lock()
+	defer f.fsys.mu.Unlock()
+
+	file, ok := f.fsys.files[f.name]
+	if !ok || file.Mode.IsDir() {
+		return 0, &fs.PathError{Op: "write", Path: f.name, Err: fs.ErrNotExist}
+	}
+
+	file.Data = append(file.Data, p...)
+	file.ModTime = time.Now()
+
+	return len(p), nil
+}
+
+func (f *memFile) Close() error {
+	return nil
+}
+
+// ArchiveModTime is the modification time of every archive entry.
+//
+// Archives don't record when (or by whom) they were generated, so the same input always produces the same archive.
+// It's the earliest time zip archives can represent.
+var ArchiveModTime = time.Date(1980, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// TarFS is a [WriteFS] writing a tar archive.
+//
+// Files are buffered in memory and written (in lexical order) when the archive is closed.
+// Timestamps are normalized (see [ArchiveModTime]) and entries are owned by root (0:0) without user and group names.
+type TarFS struct {
+	*MemFS
+
+	w io.Writer
+}
+
+// NewTarFS returns a [TarFS] writing to w.
+func NewTarFS(w io.Writer) *TarFS {
+	return &TarFS{MemFS: NewMemFS(), w: w}
+}
+
+// Close writes the archive.
+func (t *TarFS) Close() error {
+	tw := tar.NewWriter(t.w)
+
+	files := t.MapFS()
+
+	for _, name := range slices.Sorted(maps.Keys(files)) {
+		file := files[name]
+
+		header := &tar.Header{
+			Name:    name,
+			Mode:    int64(file.Mode.Perm()),
+			ModTime: ArchiveModTime,
+		}
+
+		if file.Mode.IsDir() {
+			header.Typeflag = tar.TypeDir
+			header.Name += "/"
+		} else {
+			header.Typeflag = tar.TypeReg
+			header.Size = int64(len(file.Data))
+		}
+
+		err := tw.WriteHeader(header)
+		if err != nil {
+			return err
+		}
+
+		_, err = tw.Write(file.Data)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tw.Close()
+}
+
+// ZipFS is a [WriteFS] writing a zip archive.
+//
+// Files are buffered in memory and written (in lexical order) when the archive is closed.
+// Timestamps are normalized (see [ArchiveModTime]).
+type ZipFS struct {
+	*MemFS
+
+	w io.Writer
+}
+
+// NewZipFS returns a [ZipFS] writing to w.
+func NewZipFS(w io.Writer) *ZipFS {
+	return &ZipFS{MemFS: NewMemFS(), w: w}
+}
+
+// Close writes the archive.
+func (z *ZipFS) Close() error {
+	zw := zip.NewWriter(z.w)
+
+	files := z.MapFS()
+
+	for _, name := range slices.Sorted(maps.Keys(files)) {
+		file := files[name]
+
+		header := &zip.FileHeader{
+			Name:     name,
+			Method:   zip.Deflate,
+			Modified: ArchiveModTime,
+		}
+		header.SetMode(file.Mode)
+
+		if file.Mode.IsDir() {
+			header.Name += "/"
+			header.Method = zip.Store
+		}
+
+		w, err := zw.CreateHeader(header)
+		if err != nil {
+			return err
+		}
+
+		_, err = w.Write(file.Data)
+		if err != nil {
+			return err
+		}
+	}
+
+	return zw.Close()
+}
@@ -0,0 +1,148 @@
+package fsx
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeSample writes the same files to any WriteFS.
+func writeSample(t *testing.T, fsys WriteFS) {
+	t.Helper()
+
+	require.NoError(t, fsys.Mkdir("dir", 0o755))
+
+	err := fsys.Mkdir("dir", 0o755)
+	require.True(t, errors.Is(err, fs.ErrExist), "expected ErrExist, got %v", err)
+
+	f, err := fsys.Create("dir/a.md")
+	require.NoError(t, err)
+
+	_, err = io.WriteString(f, "hello ")
+	require.NoError(t, err)
+	_, err = io.WriteString(f, "world")
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	require.NoError(t, fsys.WriteFile("b.md", []byte("b"), 0o644))
+
+	// Parent directories must exist
+	err = fsys.WriteFile("missing/c.md", []byte("c"), 0o644)
+	require.True(t, errors.Is(err, fs.ErrNotExist), "expected ErrNotExist, got %v", err)
+
+	err = fsys.Mkdir("missing/dir", 0o755)
+	require.True(t, errors.Is(err, fs.ErrNotExist), "expected ErrNotExist, got %v", err)
+}
+
+func TestRootFS(t *testing.T) {
+	root, err := os.OpenRoot(t.TempDir())
+	require.NoError(t, err)
+	defer root.Close()
+
+	writeSample(t, NewRootFS(root))
+
+	data, err := fs.ReadFile(root.FS(), "dir/a.md")
+	require.NoError(t, err)
+	assert.Equal(t, "hello world", string(data))
+}
+
+func TestMemFS(t *testing.T) {
+	fsys := NewMemFS()
+
+	writeSample(t, fsys)
+
+	files := fsys.MapFS()
+
+	data, err := fs.ReadFile(files, "dir/a.md")
+	require.NoError(t, err)
+	assert.Equal(t, "hello world", string(data))
+
+	assert.True(t, files["dir"].Mode.IsDir())
+
+	_, err = fsys.Create("dir")
+	assert.Error(t, err)
+
+	_, err = fsys.Create("../escape.md")
+	assert.ErrorIs(t, err, fs.ErrInvalid)
+}
+
+func TestTarFS(t *testing.T) {
+	var buf bytes.Buffer
+
+	fsys := NewTarFS(&buf)
+	writeSample(t, fsys)
+	require.NoError(t, fsys.Close())
+
+	tr := tar.NewReader(&buf)
+
+	var names []string
+
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		require.NoError(t, err)
+
+		names = append(names, header.Name)
+
+		assert.True(t, header.ModTime.Equal(ArchiveModTime), header.Name)
+		assert.Zero(t, header.Uid)
+		assert.Zero(t, header.Gid)
+		assert.Empty(t, header.Uname)
+
+		if header.Name == "dir/a.md" {
+			data, err := io.ReadAll(tr)
+			require.NoError(t, err)
+			assert.Equal(t, "hello world", string(data))
+		}
+	}
+
+	assert.Equal(t, []string{"b.md", "dir/", "dir/a.md"}, names)
+}
+
+func TestZipFS(t *testing.T) {
+	var buf bytes.Buffer
+
+	fsys := NewZipFS(&buf)
+	writeSample(t, fsys)
+	require.NoError(t, fsys.Close())
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	require.NoError(t, err)
+
+	var names []string
+	for _, file := range zr.File {
+		names = append(names, file.Name)
+
+		assert.True(t, file.Modified.Equal(ArchiveModTime), file.Name)
+	}
+
+	assert.Equal(t, []string{"b.md", "dir/", "dir/a.md"}, names)
+
+	data, err := fs.ReadFile(zr, "dir/a.md")
+	require.NoError(t, err)
+	assert.Equal(t, "hello world", string(data))
+}
+
+func TestTarFS_Reproducible(t *testing.T) {
+	archive := func() []byte {
+		var buf bytes.Buffer
+
+		fsys := NewTarFS(&buf)
+		writeSample(t, fsys)
+		require.NoError(t, fsys.Close())
+
+		return buf.Bytes()
+	}
+
+	assert.Equal(t, archive(), archive())
+}